@@ -0,0 +1,138 @@
+package apiErrors
+
+import "net/http"
+
+// Error codes. Clients can rely on these values, so existing codes must never be
+// renamed or reused for a different problem.
+const (
+	InvalidRequestBody = "invalid_request_body"
+	InvalidInput       = "invalid_input"
+	InvalidNonce       = "invalid_nonce"
+	InvalidCredentials = "invalid_credentials"
+	Unauthenticated    = "unauthenticated"
+	InvalidToken       = "invalid_token"
+	ExpiredToken       = "expired_token"
+	CSRFFailed         = "csrf_failed"
+	Forbidden          = "forbidden"
+	ClientCertRequired = "client_certificate_required"
+	NotFound           = "not_found"
+	DuplicateEntry     = "duplicate_entry"
+	DatabaseError      = "database_error"
+	CryptoKeyError     = "crypto_key_error"
+	HashError          = "hash_error"
+	InternalError      = "internal_error"
+	ServiceUnavailable = "service_unavailable"
+)
+
+type CatalogEntry struct {
+	Code        string `json:"code"`
+	Title       string `json:"title"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// Catalog describes every error code
+var Catalog = map[string]CatalogEntry{
+	InvalidRequestBody: {
+		Code:        InvalidRequestBody,
+		Title:       "Invalid request body",
+		Status:      http.StatusBadRequest,
+		Description: "The request body could not be parsed or is missing required values.",
+	},
+	InvalidInput: {
+		Code:        InvalidInput,
+		Title:       "Invalid input",
+		Status:      http.StatusBadRequest,
+		Description: "A value in the request is not valid, e.g. an unknown user id or attribute. The detail explains which value.",
+	},
+	InvalidNonce: {
+		Code:        InvalidNonce,
+		Title:       "Invalid nonce",
+		Status:      http.StatusBadRequest,
+		Description: "The nonce is malformed, expired, was already used or was issued to a different address. Request a new nonce from /nonce.",
+	},
+	InvalidCredentials: {
+		Code:        InvalidCredentials,
+		Title:       "Invalid username or password",
+		Status:      http.StatusUnauthorized,
+		Description: "The username doesn't exist or the password doesn't match.",
+	},
+	Unauthenticated: {
+		Code:        Unauthenticated,
+		Title:       "Authentication required",
+		Status:      http.StatusUnauthorized,
+		Description: "The request requires authentication, but no credentials were provided.",
+	},
+	InvalidToken: {
+		Code:        InvalidToken,
+		Title:       "Invalid authorization token",
+		Status:      http.StatusUnauthorized,
+		Description: "The authorization token is missing, malformed, has an invalid signature or wasn't issued for this service.",
+	},
+	ExpiredToken: {
+		Code:        ExpiredToken,
+		Title:       "Expired authorization token",
+		Status:      http.StatusUnauthorized,
+		Description: "The authorization token has expired. Log in again to get a new token.",
+	},
+	CSRFFailed: {
+		Code:        CSRFFailed,
+		Title:       "Invalid CSRF token",
+		Status:      http.StatusForbidden,
+		Description: "The request was authenticated with a cookie, but the CSRF header is missing or doesn't match the CSRF cookie.",
+	},
+	Forbidden: {
+		Code:        Forbidden,
+		Title:       "Not authorized to perform this action",
+		Status:      http.StatusForbidden,
+		Description: "The authenticated user is not allowed to perform this action.",
+	},
+	ClientCertRequired: {
+		Code:        ClientCertRequired,
+		Title:       "Client certificate required",
+		Status:      http.StatusForbidden,
+		Description: "The action requires a client certificate issued by a trusted CA. Connect to the service over TLS and present one.",
+	},
+	NotFound: {
+		Code:        NotFound,
+		Title:       "Not found",
+		Status:      http.StatusNotFound,
+		Description: "The requested resource doesn't exist.",
+	},
+	DuplicateEntry: {
+		Code:        DuplicateEntry,
+		Title:       "Duplicate entry",
+		Status:      http.StatusConflict,
+		Description: "A user with the same username or email already exists. The detail explains which value.",
+	},
+	DatabaseError: {
+		Code:        DatabaseError,
+		Title:       "Server error",
+		Status:      http.StatusInternalServerError,
+		Description: "The database could not complete the request.",
+	},
+	CryptoKeyError: {
+		Code:        CryptoKeyError,
+		Title:       "Server error",
+		Status:      http.StatusInternalServerError,
+		Description: "The service's signing keys could not be read or used.",
+	},
+	HashError: {
+		Code:        HashError,
+		Title:       "Server error",
+		Status:      http.StatusInternalServerError,
+		Description: "The password could not be hashed.",
+	},
+	InternalError: {
+		Code:        InternalError,
+		Title:       "Server error",
+		Status:      http.StatusInternalServerError,
+		Description: "An unexpected error occurred.",
+	},
+	ServiceUnavailable: {
+		Code:        ServiceUnavailable,
+		Title:       "Service unavailable",
+		Status:      http.StatusServiceUnavailable,
+		Description: "The authorization token couldn't be checked, e.g. because the database is unavailable. Try again later.",
+	},
+}
@@ -0,0 +1,68 @@
+// Package apiErrors writes error responses in the RFC 7807 problem details
+// format. Every problem has a stable, machine readable code. The codes are listed
+// in Catalog and documented in docs/error-codes.md.
+package apiErrors
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentType = "application/problem+json"
+
+// The type of a problem is a relative URI built from this prefix and the
+// problem's code. GET /problems/:code describes the problem type.
+const TypePrefix = "/problems/"
+
+// Problem is an RFC 7807 problem details object. Code is an extension member
+// holding the problem's stable error code.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// New returns the Problem for an error code. detail is an optional, human
+// readable explanation of this specific occurrence of the problem. Unknown codes
+// return an internal_error problem.
+func New(code string, detail string) Problem {
+	entry, ok := Catalog[code]
+	if !ok {
+		code = InternalError
+		entry = Catalog[InternalError]
+	}
+
+	return Problem{
+		Type:   TypePrefix + code,
+		Title:  entry.Title,
+		Status: entry.Status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write writes a problem to the response and aborts the request. The request's
+// path is used as the problem's instance.
+func Write(ctx *gin.Context, problem Problem) {
+	if len(problem.Instance) == 0 && ctx.Request != nil {
+		problem.Instance = ctx.Request.URL.Path
+	}
+
+	problemBytes, marshalErr := json.Marshal(problem)
+	if marshalErr != nil {
+		ctx.AbortWithStatus(problem.Status)
+		return
+	}
+
+	ctx.Abort()
+	ctx.Data(problem.Status, ContentType, problemBytes)
+}
+
+// WriteCode is a shortcut for Write(ctx, New(code, detail))
+func WriteCode(ctx *gin.Context, code string, detail string) {
+	Write(ctx, New(code, detail))
+}
@@ -0,0 +1,188 @@
+package authServer
+
+import (
+	"bufio"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// Every audit event whose sequence number is a multiple of this is signed
+const AuditCheckpointInterval = 100
+
+/****************************************************************************************
+* AuditChainError
+****************************************************************************************/
+
+// AuditChainError describes the first broken link found in an audit chain
+type AuditChainError struct {
+	Sequence int64
+	ErrMsg   string
+}
+
+func (err AuditChainError) Error() string {
+	return fmt.Sprintf("audit event %d: %s", err.Sequence, err.ErrMsg)
+}
+func NewAuditChainError(sequence int64, msg string) error { return AuditChainError{sequence, msg} }
+
+/****************************************************************************************
+* Audit Chain
+****************************************************************************************/
+
+// auditChain holds the end of the audit chain. The mutex is held while an event
+// is linked and stored, so that events are stored in the order of the chain.
+//
+// The end of the chain is kept per process. When several replicas share a
+// database, another replica may store an event with the same sequence number,
+// which the unique index on sequence rejects. The chain is then reloaded from
+// the database and the event linked again.
+type auditChain struct {
+	mutex        sync.Mutex
+	loaded       bool
+	sequence     int64
+	previousHash string
+}
+
+// load reads the end of the chain from the newest stored event. Until it
+// succeeds, load is attempted again for every event.
+func (chain *auditChain) load(dbc dbController.DatabaseController) error {
+	if chain.loaded {
+		return nil
+	}
+
+	events, eventsErr := dbc.GetAuditEvents(dbController.AuditEventFilter{Limit: 1})
+	if eventsErr != nil {
+		return eventsErr
+	}
+
+	chain.sequence = 0
+	chain.previousHash = ""
+
+	if len(events) > 0 {
+		chain.sequence = events[0].Sequence
+		chain.previousHash = events[0].Hash
+	}
+
+	chain.loaded = true
+
+	return nil
+}
+
+// link numbers the event, links it to the end of the chain and computes its
+// hash. Checkpoint events are signed with the service's private key. The chain
+// isn't advanced until advance is called, once the event is stored.
+func (chain *auditChain) link(event *authUtils.AuditEvent) error {
+	event.Sequence = chain.sequence + 1
+	event.PreviousHash = chain.previousHash
+	event.Checkpoint = ""
+	event.Hash = event.ComputeHash()
+
+	if event.Sequence%AuditCheckpointInterval != 0 {
+		return nil
+	}
+
+	signature, signErr := authCrypto.SignString(event.Hash)
+	if signErr != nil {
+		return signErr
+	}
+
+	event.Checkpoint = signature
+
+	return nil
+}
+
+// advance moves the end of the chain to a stored event
+func (chain *auditChain) advance(event *authUtils.AuditEvent) {
+	chain.sequence = event.Sequence
+	chain.previousHash = event.Hash
+}
+
+/****************************************************************************************
+* Verification
+****************************************************************************************/
+
+// VerifyAuditChain checks a list of audit events, sorted by sequence number,
+// and returns an AuditChainError describing the first broken link. Every hash is
+// recomputed, every event must link to the one before it and every checkpoint
+// must be signed by the public key. Events before the first one passed can't be
+// checked. If requireGenesis is true, the list must start at sequence 1, as it
+// does when the whole chain is verified. Otherwise it may start anywhere, e.g.
+// in a log file written after older ones were removed.
+func VerifyAuditChain(events []authUtils.AuditEvent, publicKey *rsa.PublicKey, requireGenesis bool) error {
+	if requireGenesis && len(events) > 0 && events[0].Sequence != 1 {
+		return NewAuditChainError(1, "event is missing")
+	}
+
+	for i, event := range events {
+		if event.Hash != event.ComputeHash() {
+			return NewAuditChainError(event.Sequence, "hash does not match the event")
+		}
+
+		if i == 0 {
+			if event.Sequence == 1 && len(event.PreviousHash) > 0 {
+				return NewAuditChainError(event.Sequence, "the first event has a previous hash")
+			}
+		} else {
+			previous := events[i-1]
+
+			if event.Sequence != previous.Sequence+1 {
+				return NewAuditChainError(previous.Sequence+1, "event is missing")
+			}
+			if event.PreviousHash != previous.Hash {
+				return NewAuditChainError(event.Sequence, "previous hash does not match the previous event")
+			}
+		}
+
+		if event.Sequence%AuditCheckpointInterval == 0 && len(event.Checkpoint) == 0 {
+			return NewAuditChainError(event.Sequence, "checkpoint is missing")
+		}
+
+		if len(event.Checkpoint) > 0 {
+			if verifyErr := authCrypto.VerifyStringSignature(event.Hash, event.Checkpoint, publicKey); verifyErr != nil {
+				return NewAuditChainError(event.Sequence, "checkpoint "+verifyErr.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadAuditLog reads the audit events from a FileLogger log file. Other log
+// lines are skipped.
+func ReadAuditLog(reader io.Reader) ([]authUtils.AuditEvent, error) {
+	events := make([]authUtils.AuditEvent, 0)
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		// Audit lines look like: {"time": ..., "type": "audit", "event": {...}}
+		var line struct {
+			Type  string                `json:"type"`
+			Event *authUtils.AuditEvent `json:"event"`
+		}
+
+		if unmarshalErr := json.Unmarshal(scanner.Bytes(), &line); unmarshalErr != nil || line.Type != authUtils.AuditLogType {
+			continue
+		}
+
+		if line.Event == nil {
+			return nil, fmt.Errorf("line %d is an audit log without an event", lineNumber)
+		}
+
+		events = append(events, *line.Event)
+	}
+
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, scanErr
+	}
+
+	return events, nil
+}
@@ -0,0 +1,140 @@
+package authServer
+
+import (
+	"log/slog"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// The number of audit events returned when a query doesn't set a limit, and
+// the most that a query can request
+const defaultAuditLimit = 100
+const maxAuditLimit = 1000
+
+// How many times storing an audit event is attempted
+const auditStoreAttempts = 3
+
+// newAuditEvent returns an AuditEvent for an action performed by the owner of
+// the claims. Claims can be nil for actions performed before a user is
+// authenticated. A nil err is recorded as a success. Otherwise the error's
+// message is recorded as the reason for the failure.
+func newAuditEvent(action string, claims *authCrypto.JWTClaims, targetId string, targetUsername string, err error, ctx RequestContext) *authUtils.AuditEvent {
+	event := authUtils.AuditEvent{
+		// Stored times only have millisecond precision. The timestamp is
+		// truncated so that the hash of a stored event can be recomputed.
+		Timestamp:      time.Now().UTC().Truncate(time.Millisecond),
+		Action:         action,
+		Outcome:        authUtils.AuditSuccess,
+		TargetId:       targetId,
+		TargetUsername: targetUsername,
+		ClientIP:       ctx.ClientIP(),
+		UserAgent:      ctx.GetHeader("User-Agent"),
+	}
+
+	if claims != nil {
+		event.ActorId = claims.Subject
+		event.ActorUsername = claims.Username
+	}
+
+	if err != nil {
+		event.Outcome = authUtils.AuditFailure
+		event.Reason = err.Error()
+	}
+
+	return &event
+}
+
+// AddAuditEvent links an audit event to the audit chain, stores it in the
+// database and writes it to the loggers. Failing to store the event doesn't
+// fail the action that was audited, so the failure is written to the loggers
+// instead.
+func (ac *AuthController) AddAuditEvent(event *authUtils.AuditEvent) {
+	ac.addAuditEvent(event, nil)
+}
+
+// addAuditEvent adds an audit event as part of a request, so that storing it is
+// traced as part of the request
+func (ac *AuthController) addAuditEvent(event *authUtils.AuditEvent, ctx RequestContext) {
+	stored, errs := ac.storeAuditEvent(event, ctx)
+
+	// Audit events are written regardless of LOG_LEVEL, so that the chain in
+	// the log files has no gaps. They're written outside the chain's mutex, so
+	// that a slow logger doesn't hold up other audited actions. Events that
+	// couldn't be stored aren't part of the chain, so they're written with
+	// another type that ReadAuditLog skips.
+	logType := authUtils.AuditLogType
+	if !stored {
+		logType = authUtils.AuditUnstoredLogType
+	}
+
+	ac.AddInfoLog(&authUtils.InfoLogData{
+		Timestamp:  event.Timestamp,
+		Level:      slog.LevelInfo.String(),
+		Type:       logType,
+		Message:    "audit event",
+		Attributes: map[string]interface{}{"event": *event},
+	})
+
+	for _, err := range errs {
+		ac.Logger().Error("error storing audit event", "sequence", event.Sequence, "error", err)
+	}
+}
+
+// storeAuditEvent links the event to the audit chain and stores it in the
+// database. If the event can't be stored, e.g. because another replica stored an
+// event with the same sequence number, the chain is reloaded and the event is
+// linked and stored again, up to auditStoreAttempts times. It returns whether
+// the event was stored and the errors of the failed attempts.
+func (ac *AuthController) storeAuditEvent(event *authUtils.AuditEvent, ctx RequestContext) (bool, []error) {
+	ac.auditChain.mutex.Lock()
+	defer ac.auditChain.mutex.Unlock()
+
+	errs := make([]error, 0)
+	db := ac.db(ctx)
+
+	for attempt := 0; attempt < auditStoreAttempts; attempt++ {
+		// Without the end of the chain, the event would start a new chain
+		if loadErr := ac.auditChain.load(db); loadErr != nil {
+			return false, append(errs, loadErr)
+		}
+
+		if linkErr := ac.auditChain.link(event); linkErr != nil {
+			return false, append(errs, linkErr)
+		}
+
+		addErr := db.AddAuditEvent(event)
+		if addErr == nil {
+			ac.auditChain.advance(event)
+			return true, errs
+		}
+
+		errs = append(errs, addErr)
+		ac.auditChain.loaded = false
+	}
+
+	return false, errs
+}
+
+// GetAuditEvents returns the audit events matching the filter. Only admins can
+// read the audit log.
+func (ac *AuthController) GetAuditEvents(filter dbController.AuditEventFilter, claims *authCrypto.JWTClaims, ctx RequestContext) (events []authUtils.AuditEvent, eventsErr error) {
+	ctx, span := ac.startSpan(ctx, "GetAuditEvents")
+	defer func() { authTracing.EndSpan(span, eventsErr) }()
+
+	if !claims.Admin || claims.Impersonated() {
+		return nil, NewUnauthorizedError("Not authorized to perform this action")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	return ac.db(ctx).GetAuditEvents(filter)
+}
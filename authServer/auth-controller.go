@@ -1,11 +1,15 @@
 package authServer
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
 	"time"
 
-	"github.com/gin-gonic/gin"
-
 	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authMetrics"
+	"methompson.com/auth-microservice/authServer/authTracing"
 	"methompson.com/auth-microservice/authServer/authUtils"
 	"methompson.com/auth-microservice/authServer/dbController"
 )
@@ -16,6 +20,15 @@ import (
 type AuthController struct {
 	DBController *dbController.DatabaseController
 	Loggers      []*authUtils.AuthLogger
+	auditChain   *auditChain
+}
+
+// RequestContext is the part of a request that the AuthController needs. Gin's
+// *gin.Context implements it for HTTP requests and grpcRequestContext
+// implements it for gRPC requests.
+type RequestContext interface {
+	ClientIP() string
+	GetHeader(key string) string
 }
 
 // The DatabaseController should already be initialized before getting
@@ -24,35 +37,77 @@ func InitController(dbc *dbController.DatabaseController) AuthController {
 	ac := AuthController{
 		DBController: dbc,
 		Loggers:      make([]*authUtils.AuthLogger, 0),
+		auditChain:   &auditChain{},
 	}
 
 	return ac
 }
 
-func (ac *AuthController) LogUserIn(body LoginBody, ctx *gin.Context) (string, error) {
+// LogUserIn checks a user's credentials and returns a token for a new session.
+// Every attempt is written to the audit log and counted in the login metrics.
+func (ac *AuthController) LogUserIn(body LoginBody, ctx RequestContext) (token string, err error) {
+	ctx, span := ac.startSpan(ctx, "LogUserIn")
+	defer func() { authTracing.EndSpan(span, err) }()
+
+	userDoc, loginErr := ac.checkLogin(body, ctx)
+	authMetrics.Logins.WithLabelValues(loginOutcome(loginErr)).Inc()
+
+	// The user is only known if their password was checked
+	targetUsername := authUtils.NormalizeUsername(body.Username)
+	ac.addAuditEvent(newAuditEvent(authUtils.AuditLogin, nil, userDoc.Id, targetUsername, loginErr, ctx), ctx)
+
+	if loginErr != nil {
+		return "", loginErr
+	}
+
+	session, sessionErr := ac.createSession(userDoc.Id, authCrypto.GetJWTExpirationTime(), ctx)
+	if sessionErr != nil {
+		return "", sessionErr
+	}
+
+	return authCrypto.GenerateSessionJWT(userDoc.GetUserDocument(), body.Audience, body.Scopes, session.Id)
+}
+
+// checkLogin checks the nonce, token request and credentials of a login
+func (ac *AuthController) checkLogin(body LoginBody, ctx RequestContext) (dbController.FullUserDocument, error) {
 	// If we're not in debug mode OR we're in debug mode and we're NOT ignoring nonces
 	if !DebugMode() || (!authUtils.IgnoringNonce() && DebugMode()) {
 		nonceErr := ac.CheckNonceValidity(body.Nonce, ctx)
 
 		if nonceErr != nil {
-			return "", nonceErr
+			return dbController.FullUserDocument{}, nonceErr
 		}
 	}
 
-	userDoc, userDocErr := (*ac.DBController).GetUserByUsername(body.Username)
+	tokenRequestErr := authCrypto.CheckTokenRequest(body.Audience, body.Scopes)
+	if tokenRequestErr != nil {
+		return dbController.FullUserDocument{}, tokenRequestErr
+	}
+
+	username := authUtils.NormalizeUsername(body.Username)
+
+	userDoc, userDocErr := ac.db(ctx).GetUserByUsername(username)
 	if userDocErr != nil {
-		return "", userDocErr
+		return dbController.FullUserDocument{}, userDocErr
 	}
 
-	verify := authUtils.CheckPasswordHash(body.Password, userDoc.PasswordHash)
+	verify := checkPasswordHash(ctx, body.Password, userDoc.PasswordHash)
 	if !verify {
-		return "", NewLoginError("Password does not match")
+		return dbController.FullUserDocument{}, NewLoginError("Password does not match")
 	}
 
-	return authCrypto.GenerateJWT(userDoc.GetUserDocument())
+	return userDoc, nil
 }
 
-func (ac *AuthController) AddNewUser(body AddUserBody, ctx *gin.Context) error {
+// AddNewUser adds a user. The attempt is written to the audit log.
+func (ac *AuthController) AddNewUser(body AddUserBody, claims *authCrypto.JWTClaims, ctx RequestContext) (addErr error) {
+	ctx, span := ac.startSpan(ctx, "AddNewUser")
+	defer func() { authTracing.EndSpan(span, addErr) }()
+
+	defer func() {
+		ac.addAuditEvent(newAuditEvent(authUtils.AuditAddUser, claims, "", authUtils.NormalizeUsername(body.Username), addErr, ctx), ctx)
+	}()
+
 	// If we're not in debug mode OR we're in debug mode and we're NOT ignoring nonces
 	if !DebugMode() || (!authUtils.IgnoringNonce() && DebugMode()) {
 		nonceErr := ac.CheckNonceValidity(body.Nonce, ctx)
@@ -62,25 +117,38 @@ func (ac *AuthController) AddNewUser(body AddUserBody, ctx *gin.Context) error {
 		}
 	}
 
-	hash, hashErr := authUtils.HashPassword(body.Password)
+	attributesErr := ValidateUserAttributes(body.Attributes, false)
+	if attributesErr != nil {
+		return attributesErr
+	}
+
+	hash, hashErr := hashPassword(ctx, body.Password)
 	if hashErr != nil {
 		return NewHashError(hashErr.Error())
 	}
 
 	doc := dbController.FullUserDocument{
-		Username:     body.Username,
-		Email:        body.Email,
+		Username:     authUtils.NormalizeUsername(body.Username),
+		Email:        authUtils.NormalizeEmail(body.Email),
 		Enabled:      body.Enabled,
 		Admin:        body.Admin,
+		Attributes:   body.Attributes,
 		PasswordHash: hash,
 	}
 
-	addErr := (*ac.DBController).AddUser(doc)
-
-	return addErr
+	return ac.db(ctx).AddUser(doc)
 }
 
-func (ac *AuthController) EditUser(body *EditUserBody, claims *authCrypto.JWTClaims, ctx *gin.Context) error {
+// EditUser edits a user's data. Disabling a user revokes their sessions. The
+// attempt is written to the audit log.
+func (ac *AuthController) EditUser(body *EditUserBody, claims *authCrypto.JWTClaims, ctx RequestContext) (editErr error) {
+	ctx, span := ac.startSpan(ctx, "EditUser")
+	defer func() { authTracing.EndSpan(span, editErr) }()
+
+	defer func() {
+		ac.addAuditEvent(newAuditEvent(authUtils.AuditEditUser, claims, body.Id, "", editErr, ctx), ctx)
+	}()
+
 	// If we're not in debug mode OR we're in debug mode and we're NOT ignoring nonces
 	if !DebugMode() || (!authUtils.IgnoringNonce() && DebugMode()) {
 		nonceErr := ac.CheckNonceValidity(body.Nonce, ctx)
@@ -97,20 +165,56 @@ func (ac *AuthController) EditUser(body *EditUserBody, claims *authCrypto.JWTCla
 		return NewUnauthorizedError("Not authorized to perform this action")
 	}
 
+	// Only admins can change a user's access, including their own. Impersonation
+	// tokens can't change it either.
+	if (body.Enabled != nil || body.Admin != nil) && (!claims.Admin || claims.Impersonated()) {
+		return NewUnauthorizedError("Only admins can change enabled or admin")
+	}
+
+	attributesErr := ValidateUserAttributes(body.Attributes, true)
+	if attributesErr != nil {
+		return attributesErr
+	}
+
 	doc := dbController.EditUserDocument{
-		Id:       body.Id,
-		Username: body.Username,
-		Email:    body.Email,
-		Enabled:  body.Enabled,
-		Admin:    body.Admin,
+		Id:         body.Id,
+		Enabled:    body.Enabled,
+		Admin:      body.Admin,
+		Attributes: body.Attributes,
+	}
+
+	// Usernames and emails are always stored in their normalized form
+	if body.Username != nil {
+		username := authUtils.NormalizeUsername(*body.Username)
+		doc.Username = &username
+	}
+	if body.Email != nil {
+		email := authUtils.NormalizeEmail(*body.Email)
+		doc.Email = &email
+	}
+
+	if editErr := ac.db(ctx).EditUser(doc); editErr != nil {
+		return editErr
 	}
 
-	editErr := (*ac.DBController).EditUser(doc)
+	// A disabled user's tokens stop working right away
+	if body.Enabled != nil && !*body.Enabled {
+		return ac.db(ctx).RevokeUserSessions(body.Id, time.Now().Unix())
+	}
 
-	return editErr
+	return nil
 }
 
-func (ac *AuthController) EditUserPassword(body *EditPasswordBody, claims *authCrypto.JWTClaims, ctx *gin.Context) error {
+// EditUserPassword changes a user's password. The attempt is written to the
+// audit log.
+func (ac *AuthController) EditUserPassword(body *EditPasswordBody, claims *authCrypto.JWTClaims, ctx RequestContext) (editErr error) {
+	ctx, span := ac.startSpan(ctx, "EditUserPassword")
+	defer func() { authTracing.EndSpan(span, editErr) }()
+
+	defer func() {
+		ac.addAuditEvent(newAuditEvent(authUtils.AuditEditPassword, claims, body.Id, "", editErr, ctx), ctx)
+	}()
+
 	// If we're not in debug mode OR we're in debug mode and we're NOT ignoring nonces
 	if !DebugMode() || (!authUtils.IgnoringNonce() && DebugMode()) {
 		nonceErr := ac.CheckNonceValidity(body.Nonce, ctx)
@@ -120,6 +224,12 @@ func (ac *AuthController) EditUserPassword(body *EditPasswordBody, claims *authC
 		}
 	}
 
+	// Passwords can only be changed by the users themselves or by admins, not by
+	// an admin impersonating a user
+	if claims.Impersonated() {
+		return NewUnauthorizedError("Impersonation tokens cannot change passwords")
+	}
+
 	// If the user is not an admin, we need to perform additional checks.
 	if !claims.Admin {
 		// We need to check the user's id against the token id. A non-admin can only edit
@@ -130,12 +240,12 @@ func (ac *AuthController) EditUserPassword(body *EditPasswordBody, claims *authC
 
 		// Now, we need to fetch the user data, and compare the old password passed
 		// to their current password.
-		userDoc, userDocErr := (*ac.DBController).GetUserById(body.Id)
+		userDoc, userDocErr := ac.db(ctx).GetUserById(body.Id)
 		if userDocErr != nil {
 			return userDocErr
 		}
 
-		verify := authUtils.CheckPasswordHash(body.OldPassword, userDoc.PasswordHash)
+		verify := checkPasswordHash(ctx, body.OldPassword, userDoc.PasswordHash)
 		if !verify {
 			return NewLoginError("Password does not match")
 		}
@@ -143,24 +253,77 @@ func (ac *AuthController) EditUserPassword(body *EditPasswordBody, claims *authC
 
 	// If we made it this far, we've passed all the checks. We can generated the password's
 	// hash and save it.
-	hashPass, hashErr := authUtils.HashPassword(body.NewPassword)
+	hashPass, hashErr := hashPassword(ctx, body.NewPassword)
 	if hashErr != nil {
 		return NewHashError(hashErr.Error())
 	}
 
-	editPassErr := (*ac.DBController).EditUserPassword(body.Id, hashPass)
+	return ac.db(ctx).EditUserPassword(body.Id, hashPass)
+}
+
+// GetUser returns a user's data. Admins can get any user's data. Other users
+// can only get their own data.
+func (ac *AuthController) GetUser(id string, claims *authCrypto.JWTClaims, ctx RequestContext) (user dbController.UserDocument, getErr error) {
+	ctx, span := ac.startSpan(ctx, "GetUser")
+	defer func() { authTracing.EndSpan(span, getErr) }()
+
+	if !claims.Admin && id != claims.Subject {
+		return dbController.UserDocument{}, NewUnauthorizedError("Not authorized to perform this action")
+	}
+
+	userDoc, userDocErr := ac.db(ctx).GetUserById(id)
+	if userDocErr != nil {
+		return dbController.UserDocument{}, userDocErr
+	}
 
-	return editPassErr
+	return userDoc.GetUserDocument(), nil
+}
+
+// DeleteUser removes a user and revokes their sessions. Only admins can delete
+// users, and admins can't delete themselves, so that there's always at least
+// one admin left. The attempt is written to the audit log.
+func (ac *AuthController) DeleteUser(id string, nonce string, claims *authCrypto.JWTClaims, ctx RequestContext) (deleteErr error) {
+	ctx, span := ac.startSpan(ctx, "DeleteUser")
+	defer func() { authTracing.EndSpan(span, deleteErr) }()
+
+	defer func() {
+		ac.addAuditEvent(newAuditEvent(authUtils.AuditDeleteUser, claims, id, "", deleteErr, ctx), ctx)
+	}()
+
+	// If we're not in debug mode OR we're in debug mode and we're NOT ignoring nonces
+	if !DebugMode() || (!authUtils.IgnoringNonce() && DebugMode()) {
+		nonceErr := ac.CheckNonceValidity(nonce, ctx)
+
+		if nonceErr != nil {
+			return nonceErr
+		}
+	}
+
+	if !claims.Admin {
+		return NewUnauthorizedError("Not authorized to perform this action")
+	}
+
+	if id == claims.Subject {
+		return dbController.NewInvalidInputError("Admins cannot delete themselves")
+	}
+
+	// The sessions are revoked first, so that a failure can't leave tokens
+	// working for a user that no longer exists
+	if revokeErr := ac.db(ctx).RevokeUserSessions(id, time.Now().Unix()); revokeErr != nil {
+		return revokeErr
+	}
+
+	return ac.db(ctx).DeleteUser(id)
 }
 
 // This function receives a calculated hash of a nonce in string form. It performs
 // a query of the list of hashed nonces in the database to determine if the combination
 // of hashed nonce and remote address exists.
-func (ac *AuthController) CheckNonceHash(hashedNonce string, ctx *gin.Context) error {
+func (ac *AuthController) CheckNonceHash(hashedNonce string, ctx RequestContext) error {
 	// remoteAddress := authUtils.GetRemoteAddressIP(ctx.Request.RemoteAddr)
 	remoteAddress := authUtils.GetRemoteAddressIP(ctx.ClientIP())
 
-	_, nonceDocErr := (*ac.DBController).GetNonce(hashedNonce, remoteAddress, authUtils.GetNonceExpirationTime())
+	_, nonceDocErr := ac.db(ctx).GetNonce(hashedNonce, remoteAddress, authUtils.GetNonceExpirationTime())
 
 	if nonceDocErr != nil {
 		return nonceDocErr
@@ -169,7 +332,10 @@ func (ac *AuthController) CheckNonceHash(hashedNonce string, ctx *gin.Context) e
 	return nil
 }
 
-func (ac *AuthController) CheckNonceValidity(nonce string, ctx *gin.Context) error {
+func (ac *AuthController) CheckNonceValidity(nonce string, ctx RequestContext) (nonceErr error) {
+	ctx, span := ac.startSpan(ctx, "CheckNonceValidity")
+	defer func() { authTracing.EndSpan(span, nonceErr) }()
+
 	// We get the hashed value of the byte array represented by the base64 encoded nonce.
 	hashedNonce, hashedNonceErr := authUtils.GetHashedNonce(nonce)
 
@@ -187,7 +353,10 @@ func (ac *AuthController) CheckNonceValidity(nonce string, ctx *gin.Context) err
 	return nil
 }
 
-func (ac *AuthController) GenerateNonce(ctx *gin.Context) (string, error) {
+func (ac *AuthController) GenerateNonce(ctx RequestContext) (nonce string, nonceErr error) {
+	ctx, span := ac.startSpan(ctx, "GenerateNonce")
+	defer func() { authTracing.EndSpan(span, nonceErr) }()
+
 	// remoteAddress := authUtils.GetRemoteAddressIP(ctx.Request.RemoteAddr)
 	remoteAddress := authUtils.GetRemoteAddressIP(ctx.ClientIP())
 	// clientIP := ctx.ClientIP()
@@ -202,32 +371,115 @@ func (ac *AuthController) GenerateNonce(ctx *gin.Context) (string, error) {
 
 	hash := authUtils.HashBytes(bytes)
 
-	addNonceErr := (*ac.DBController).AddNonce(hash, remoteAddress, time.Now().Unix())
+	addNonceErr := ac.db(ctx).AddNonce(hash, remoteAddress, time.Now().Unix())
 
 	if addNonceErr != nil {
 		return "", addNonceErr
 	}
 
+	authMetrics.NoncesIssued.Inc()
+
 	return nonce, nil
 }
 
+// RemoveOldNonces removes the expired nonces. The result and the number of
+// nonces left are recorded in the nonce metrics.
 func (ac *AuthController) RemoveOldNonces() error {
-	return (*ac.DBController).RemoveOldNonces(authUtils.GetNonceExpirationTime())
+	ctx, span := authTracing.Tracer().Start(context.Background(), "AuthController.RemoveOldNonces")
+
+	removeErr := ac.dbWithContext(ctx).RemoveOldNonces(authUtils.GetNonceExpirationTime())
+	authMetrics.ObserveNonceCleanout(removeErr)
+
+	if count, countErr := ac.dbWithContext(ctx).CountNonces(); countErr == nil {
+		authMetrics.Nonces.Set(float64(count))
+	}
+
+	authTracing.EndSpan(span, removeErr)
+
+	return removeErr
+}
+
+// IntrospectToken determines whether a token is active and returns the
+// introspection response described in RFC 7662. Inactive tokens only return
+// {"active": false}, so that callers can't learn anything about tokens that
+// failed validation.
+func (ac *AuthController) IntrospectToken(token string) map[string]interface{} {
+	inactive := map[string]interface{}{"active": false}
+
+	// Tokens from revoked sessions are inactive
+	claims, claimsErr := ac.ValidateToken(token)
+	if claimsErr != nil {
+		return inactive
+	}
+
+	// The claims are converted to a map by round tripping them through JSON,
+	// so that the response uses the same names as the token itself.
+	claimBytes, marshalErr := json.Marshal(claims)
+	if marshalErr != nil {
+		return inactive
+	}
+
+	response := make(map[string]interface{})
+	if unmarshalErr := json.Unmarshal(claimBytes, &response); unmarshalErr != nil {
+		return inactive
+	}
+
+	response["active"] = true
+	response["token_type"] = "Bearer"
+
+	return response
 }
 
 func (ac *AuthController) AddLogger(logger *authUtils.AuthLogger) {
 	ac.Loggers = append(ac.Loggers, logger)
 }
 
+// Logger returns a slog.Logger that writes to the controller's loggers. Without
+// any loggers, e.g. in debug mode, it returns slog's default logger.
+func (ac *AuthController) Logger() *slog.Logger {
+	if len(ac.Loggers) == 0 {
+		return slog.Default()
+	}
+
+	return slog.New(authUtils.NewLogHandler(ac.Loggers, authUtils.GetLogLevel()))
+}
+
 func (ac *AuthController) AddRequestLog(log *authUtils.RequestLogData) {
 	for _, logger := range ac.Loggers {
-		(*logger).AddRequestLog(log)
+		if logErr := (*logger).AddRequestLog(log); logErr != nil {
+			authUtils.ReportLoggerError(logErr)
+		}
 	}
 }
 
 func (ac *AuthController) AddInfoLog(log *authUtils.InfoLogData) {
 	for _, logger := range ac.Loggers {
-		(*logger).AddInfoLog(log)
+		if logErr := (*logger).AddInfoLog(log); logErr != nil {
+			authUtils.ReportLoggerError(logErr)
+		}
+	}
+}
+
+// ReopenLoggers reopens the files written by the controller's loggers
+func (ac *AuthController) ReopenLoggers() {
+	for _, logger := range ac.Loggers {
+		if reopenable, ok := (*logger).(authUtils.ReopenableLogger); ok {
+			if reopenErr := reopenable.Reopen(); reopenErr != nil {
+				authUtils.ReportLoggerError(reopenErr)
+			}
+		}
+	}
+}
+
+// CloseLoggers closes the loggers that hold open files. Nothing can be logged
+// to them afterwards.
+func (ac *AuthController) CloseLoggers() {
+	for _, logger := range ac.Loggers {
+		if closer, ok := (*logger).(io.Closer); ok {
+			if closeErr := closer.Close(); closeErr != nil {
+				authUtils.ReportLoggerError(closeErr)
+			}
+		}
 	}
 }
 
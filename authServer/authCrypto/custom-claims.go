@@ -0,0 +1,85 @@
+package authCrypto
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/jwtVerifier"
+)
+
+/****************************************************************************************
+* Custom Claims Mapping
+****************************************************************************************/
+
+// ParseCustomClaimsMapping parses the value of the JWT_CUSTOM_CLAIMS environment
+// variable. The value is a comma separated list of user attributes that should be
+// copied into a JWT. Each entry is either an attribute name, e.g. "locale", or an
+// attribute name and the claim it's copied to separated by a colon, e.g.
+// "displayName:name". The returned map uses the attribute name as the key and the
+// claim name as the value. Claims that collide with the standard claims are rejected.
+func ParseCustomClaimsMapping(str string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	reserved := jwtVerifier.ReservedClaimNames()
+
+	for _, entry := range strings.Split(str, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) > 2 {
+			return nil, NewJWTError(fmt.Sprintf("invalid custom claim mapping '%s'", entry))
+		}
+
+		attribute := strings.TrimSpace(parts[0])
+		claim := attribute
+		if len(parts) == 2 {
+			claim = strings.TrimSpace(parts[1])
+		}
+
+		if len(attribute) == 0 || len(claim) == 0 {
+			return nil, NewJWTError(fmt.Sprintf("invalid custom claim mapping '%s'", entry))
+		}
+
+		if reserved[claim] {
+			return nil, NewJWTError(fmt.Sprintf("custom claim '%s' conflicts with a reserved claim", claim))
+		}
+
+		mapping[attribute] = claim
+	}
+
+	return mapping, nil
+}
+
+// GetCustomClaimsMapping returns the mapping defined in the JWT_CUSTOM_CLAIMS
+// environment variable. An invalid mapping is treated as an empty mapping. The
+// variable is checked on startup, so this should only happen in tests.
+func GetCustomClaimsMapping() map[string]string {
+	mapping, mappingErr := ParseCustomClaimsMapping(os.Getenv(constants.JWT_CUSTOM_CLAIMS))
+
+	if mappingErr != nil {
+		return make(map[string]string)
+	}
+
+	return mapping
+}
+
+// GetCustomClaims copies the user attributes selected by the mapping into a new
+// map of claims. Attributes that the user doesn't have are skipped.
+func GetCustomClaims(attributes map[string]interface{}, mapping map[string]string) map[string]interface{} {
+	claims := make(map[string]interface{})
+
+	for attribute, claim := range mapping {
+		value, ok := attributes[attribute]
+		if !ok || value == nil {
+			continue
+		}
+
+		claims[claim] = value
+	}
+
+	return claims
+}
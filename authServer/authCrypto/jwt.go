@@ -2,65 +2,203 @@ package authCrypto
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 
 	"methompson.com/auth-microservice/authServer/constants"
 	dbc "methompson.com/auth-microservice/authServer/dbController"
+	"methompson.com/auth-microservice/authServer/jwtVerifier"
 )
 
 /****************************************************************************************
 * JWT Errors
 ****************************************************************************************/
 
+// The JWT errors and claims are defined in the jwtVerifier package so that other
+// services can use them without importing the rest of the auth service.
+
 // Used for when there's a generic issue reading or writing JWTs
-type JWTError struct{ ErrMsg string }
+type JWTError = jwtVerifier.JWTError
 
-func (err JWTError) Error() string { return err.ErrMsg }
-func NewJWTError(msg string) error { return JWTError{msg} }
+func NewJWTError(msg string) error { return jwtVerifier.NewJWTError(msg) }
 
 // Used for when the JWT expires
-type ExpiredJWTError struct{ ErrMsg string }
+type ExpiredJWTError = jwtVerifier.ExpiredJWTError
 
-func (err ExpiredJWTError) Error() string { return err.ErrMsg }
-func NewExpiredJWTError(msg string) error { return ExpiredJWTError{msg} }
+func NewExpiredJWTError(msg string) error { return jwtVerifier.NewExpiredJWTError(msg) }
 
 /****************************************************************************************
 * JWT Claims Struct
 ****************************************************************************************/
 
-type JWTClaims struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Admin    bool   `json:"admin"`
-	jwt.StandardClaims
+type JWTClaims = jwtVerifier.JWTClaims
+
+type ActorClaim = jwtVerifier.ActorClaim
+
+/****************************************************************************************
+* JWT Configuration
+****************************************************************************************/
+
+func GetJWTExpirationTime() int64 {
+	return time.Now().Add(constants.JWT_EXPIRATION).Unix()
+}
+
+// GetJWTIssuer returns the value of the iss claim. An empty issuer means tokens
+// are generated without an iss claim and the issuer isn't checked.
+func GetJWTIssuer() string {
+	return os.Getenv(constants.JWT_ISSUER)
 }
 
-func (jc JWTClaims) Valid() error {
-	return nil
+// GetJWTAudiences returns the audiences a token can be issued for. The first
+// audience is used when the client doesn't request one. An empty list means
+// tokens are generated without an aud claim and the audience isn't checked.
+func GetJWTAudiences() []string {
+	return splitList(os.Getenv(constants.JWT_AUDIENCES))
+}
+
+// GetJWTScopes returns the scopes a client is allowed to request.
+func GetJWTScopes() []string {
+	return splitList(os.Getenv(constants.JWT_SCOPES))
+}
+
+// GetJWTClockSkew returns the leeway used when checking the time based claims
+// of a token. If JWT_CLOCK_SKEW isn't a valid duration, the default is used.
+func GetJWTClockSkew() time.Duration {
+	skew, skewErr := ParseClockSkew(os.Getenv(constants.JWT_CLOCK_SKEW))
+	if skewErr != nil {
+		return constants.JWT_DEFAULT_CLOCK_SKEW
+	}
+
+	return skew
+}
+
+// ParseClockSkew parses a clock skew duration, e.g. "30s". An empty string
+// returns the default clock skew.
+func ParseClockSkew(str string) (time.Duration, error) {
+	if len(str) == 0 {
+		return constants.JWT_DEFAULT_CLOCK_SKEW, nil
+	}
+
+	skew, parseErr := time.ParseDuration(str)
+	if parseErr != nil || skew < 0 {
+		return 0, NewJWTError(fmt.Sprintf("invalid clock skew '%s'", str))
+	}
+
+	return skew, nil
+}
+
+func splitList(str string) []string {
+	list := make([]string, 0)
+
+	for _, value := range strings.Split(str, ",") {
+		value = strings.TrimSpace(value)
+		if len(value) > 0 {
+			list = append(list, value)
+		}
+	}
+
+	return list
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
 
 /****************************************************************************************
 * Generating and Validating JWTs
 ****************************************************************************************/
 
-func GetJWTExpirationTime() int64 {
-	return time.Now().Add(constants.JWT_EXPIRATION).Unix()
+// CheckTokenRequest checks that a client may request a token for the audience
+// and scopes passed to the function. An empty audience selects the default
+// audience. It returns an InvalidInputError if the request isn't allowed.
+func CheckTokenRequest(audience string, scopes []string) error {
+	if len(audience) > 0 && !contains(GetJWTAudiences(), audience) {
+		return dbc.NewInvalidInputError(fmt.Sprintf("Invalid audience '%s'", audience))
+	}
+
+	allowedScopes := GetJWTScopes()
+	for _, scope := range scopes {
+		if !contains(allowedScopes, scope) {
+			return dbc.NewInvalidInputError(fmt.Sprintf("Invalid scope '%s'", scope))
+		}
+	}
+
+	return nil
 }
 
-// Returns a JWT
+// Returns a JWT for the default audience without any scopes
 func GenerateJWT(userDocument dbc.UserDocument) (string, error) {
-	claims := JWTClaims{
+	return GenerateScopedJWT(userDocument, "", nil)
+}
+
+// GenerateScopedJWT returns a JWT for a specific audience and set of scopes. An
+// empty audience selects the default audience. The audience and scopes should be
+// checked with CheckTokenRequest first.
+func GenerateScopedJWT(userDocument dbc.UserDocument, audience string, scopes []string) (string, error) {
+	return GenerateSessionJWT(userDocument, audience, scopes, "")
+}
+
+// GenerateSessionJWT returns a scoped JWT for a login session. The session id is
+// stored in the sid claim, so that the token can be revoked with its session.
+func GenerateSessionJWT(userDocument dbc.UserDocument, audience string, scopes []string, sessionId string) (string, error) {
+	claims := makeJWTClaims(userDocument, audience, time.Now().Add(constants.JWT_EXPIRATION))
+	claims.Scope = strings.Join(scopes, " ")
+	claims.SessionId = sessionId
+
+	return signJWT(claims)
+}
+
+// GenerateImpersonationJWT returns a short lived JWT for the default audience
+// that lets the actor act as the user. The actor is stored in the act claim and
+// the impersonation's session id in the sid claim. The token never has admin
+// rights, even if the user is an admin.
+func GenerateImpersonationJWT(userDocument dbc.UserDocument, actor ActorClaim, sessionId string) (string, error) {
+	claims := makeJWTClaims(userDocument, "", time.Now().Add(constants.IMPERSONATION_JWT_EXPIRATION))
+	claims.Admin = false
+	claims.Actor = &actor
+	claims.SessionId = sessionId
+
+	return signJWT(claims)
+}
+
+// makeJWTClaims returns the claims shared by every token issued for a user. An
+// empty audience selects the default audience.
+func makeJWTClaims(userDocument dbc.UserDocument, audience string, expiresAt time.Time) JWTClaims {
+	if len(audience) == 0 {
+		if audiences := GetJWTAudiences(); len(audiences) > 0 {
+			audience = audiences[0]
+		}
+	}
+
+	now := time.Now()
+
+	return JWTClaims{
 		Username: userDocument.Username,
 		Email:    userDocument.Email,
 		Admin:    userDocument.Admin,
+		Custom:   GetCustomClaims(userDocument.Attributes, GetCustomClaimsMapping()),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: GetJWTExpirationTime(),
+			Audience:  audience,
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  now.Unix(),
+			Issuer:    GetJWTIssuer(),
+			NotBefore: now.Unix(),
 			Subject:   userDocument.Id,
 		},
 	}
+}
 
+// signJWT signs the claims with the service's private key
+func signJWT(claims JWTClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 
 	privateKey, privateKeyErr := GetRSAPrivateKey()
@@ -72,35 +210,39 @@ func GenerateJWT(userDocument dbc.UserDocument) (string, error) {
 
 	signedString, tokenStringErr := token.SignedString(privateKey)
 
+	// Signing only fails if there's a problem with the private key
 	if tokenStringErr != nil {
 		msg := fmt.Sprintln("error making JWT", tokenStringErr)
-		return "", NewJWTError(msg)
+		return "", NewCryptoKeyError(msg)
 	}
 
 	return signedString, nil
 }
 
-func ValidateJWT(tokenString string) (*JWTClaims, error) {
-	var jwtClaims *JWTClaims = &JWTClaims{}
-	// token, parseErr := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-	token, parseErr := jwt.ParseWithClaims(tokenString, jwtClaims, func(token *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			// return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-			return nil, NewJWTError(fmt.Sprintf("invalid signing method: %v", token.Header["alg"]))
-		}
+// GetJWTVerifier returns a jwtVerifier.Verifier configured with the service's
+// public key, issuer, audiences and clock skew.
+func GetJWTVerifier() (*jwtVerifier.Verifier, error) {
+	publicKey, publicKeyErr := GetRSAPublicKey()
+	if publicKeyErr != nil {
+		return nil, publicKeyErr
+	}
 
-		// hmacSampleSecret is a []byte containing your secret, e.g. []byte("my_secret_key")
-		return GetRSAPublicKey()
+	return jwtVerifier.NewVerifier(jwtVerifier.Options{
+		PublicKey: publicKey,
+		Issuer:    GetJWTIssuer(),
+		Audiences: GetJWTAudiences(),
+		ClockSkew: GetJWTClockSkew(),
 	})
+}
 
-	if parseErr != nil {
-		return nil, parseErr
-	}
-
-	if !token.Valid {
-		return nil, NewJWTError("invalid claims")
+// ValidateJWT verifies the signature of a JWT, as well as its iss, aud, exp, nbf
+// and iat claims. It returns an ExpiredJWTError for expired tokens and a JWTError
+// for any other invalid token.
+func ValidateJWT(tokenString string) (*JWTClaims, error) {
+	verifier, verifierErr := GetJWTVerifier()
+	if verifierErr != nil {
+		return nil, verifierErr
 	}
 
-	return jwtClaims, nil
+	return verifier.Verify(tokenString)
 }
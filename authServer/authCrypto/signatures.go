@@ -0,0 +1,44 @@
+package authCrypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// SignString signs the string with the service's private key. The signature is
+// an RSA PKCS #1 v1.5 signature of the string's sha256 hash, encoded in base64.
+func SignString(str string) (string, error) {
+	privateKey, privateKeyErr := GetRSAPrivateKey()
+	if privateKeyErr != nil {
+		return "", privateKeyErr
+	}
+
+	hashed := sha256.Sum256([]byte(str))
+
+	signature, signErr := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+	if signErr != nil {
+		return "", NewCryptoKeyError("error signing string: " + signErr.Error())
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyStringSignature checks a signature made by SignString against the
+// public key
+func VerifyStringSignature(str string, signature string, publicKey *rsa.PublicKey) error {
+	signatureBytes, decodeErr := base64.StdEncoding.DecodeString(signature)
+	if decodeErr != nil {
+		return NewCryptoKeyError("signature is not valid base64")
+	}
+
+	hashed := sha256.Sum256([]byte(str))
+
+	if verifyErr := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signatureBytes); verifyErr != nil {
+		return NewCryptoKeyError("signature does not match")
+	}
+
+	return nil
+}
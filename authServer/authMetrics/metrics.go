@@ -0,0 +1,202 @@
+// Package authMetrics holds the service's Prometheus metrics. Every metric is
+// registered with Registry, which is served by the GET /metrics route.
+package authMetrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the service's metrics, along with the Go runtime and process
+// metrics
+var Registry = prometheus.NewRegistry()
+
+/****************************************************************************************
+* Requests
+****************************************************************************************/
+
+// HTTPRequests counts the handled requests by method, route and status code
+var HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_http_requests_total",
+	Help: "The number of HTTP requests handled, by method, route and status code.",
+}, []string{"method", "route", "status"})
+
+// HTTPRequestDuration observes how long requests take by method, route and
+// status code
+var HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "auth_http_request_duration_seconds",
+	Help:    "How long HTTP requests take, by method, route and status code.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// The route label of requests that didn't match a route, so that unknown paths
+// can't create new series
+const UnmatchedRoute = "unmatched"
+
+// ObserveRequest records a handled request. route is the route's pattern, e.g.
+// /users/:id/sessions, or an empty string if the request matched no route.
+func ObserveRequest(method string, route string, status int, duration time.Duration) {
+	if len(route) == 0 {
+		route = UnmatchedRoute
+	}
+
+	statusStr := strconv.Itoa(status)
+
+	HTTPRequests.WithLabelValues(method, route, statusStr).Inc()
+	HTTPRequestDuration.WithLabelValues(method, route, statusStr).Observe(duration.Seconds())
+}
+
+/****************************************************************************************
+* Logins
+****************************************************************************************/
+
+// The outcome label of successful logins. Failed logins use the error code of
+// the failure, e.g. invalid_credentials.
+const LoginSuccess = "success"
+
+// Logins counts login attempts by outcome
+var Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_logins_total",
+	Help: "The number of login attempts, by outcome. Failed attempts are labelled with their error code.",
+}, []string{"outcome"})
+
+/****************************************************************************************
+* Passwords
+****************************************************************************************/
+
+// Operation labels of PasswordHashDuration
+const HashOperation = "hash"
+const CompareOperation = "compare"
+
+// PasswordHashDuration observes how long bcrypt takes to hash a password or to
+// compare a password to a hash
+var PasswordHashDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "auth_password_hash_duration_seconds",
+	Help:    "How long bcrypt takes to hash or compare a password, by operation.",
+	Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+}, []string{"operation"})
+
+/****************************************************************************************
+* Database
+****************************************************************************************/
+
+// DBOperationDuration observes how long DatabaseController operations take
+var DBOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "auth_db_operation_duration_seconds",
+	Help:    "How long DatabaseController operations take, by operation.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"operation"})
+
+// DBOperationErrors counts the DatabaseController operations that failed
+var DBOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_db_operation_errors_total",
+	Help: "The number of DatabaseController operations that failed, by operation.",
+}, []string{"operation"})
+
+// ObserveDBOperation is called when a DatabaseController operation starts. The
+// function it returns records the operation's duration and error when it
+// finishes. It can be passed to dbController.NewInstrumentedController.
+func ObserveDBOperation(operation string) func(err error) {
+	start := time.Now()
+
+	return func(err error) {
+		DBOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+		if err != nil {
+			DBOperationErrors.WithLabelValues(operation).Inc()
+		}
+	}
+}
+
+/****************************************************************************************
+* Nonces
+****************************************************************************************/
+
+// NoncesIssued counts the nonces generated
+var NoncesIssued = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "auth_nonces_issued_total",
+	Help: "The number of nonces issued.",
+})
+
+// Nonces is the number of stored nonces, measured after every nonce cleanout
+var Nonces = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "auth_nonces",
+	Help: "The number of stored nonces, measured after every nonce cleanout.",
+})
+
+// Result labels of NonceCleanouts
+const CleanoutSuccess = "success"
+const CleanoutFailure = "failure"
+
+// NonceCleanouts counts the nonce cleanout runs by result
+var NonceCleanouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_nonce_cleanouts_total",
+	Help: "The number of nonce cleanout runs, by result.",
+}, []string{"result"})
+
+// NonceCleanoutLastSuccess is the time of the last successful nonce cleanout
+var NonceCleanoutLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "auth_nonce_cleanout_last_success_timestamp_seconds",
+	Help: "The Unix time of the last successful nonce cleanout.",
+})
+
+// ObserveNonceCleanout records the result of a nonce cleanout run
+func ObserveNonceCleanout(err error) {
+	if err != nil {
+		NonceCleanouts.WithLabelValues(CleanoutFailure).Inc()
+		return
+	}
+
+	NonceCleanouts.WithLabelValues(CleanoutSuccess).Inc()
+	NonceCleanoutLastSuccess.SetToCurrentTime()
+}
+
+/****************************************************************************************
+* Logging
+****************************************************************************************/
+
+// LogsDropped counts the logs dropped because a logger's queue was full, by
+// logger
+var LogsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_logs_dropped_total",
+	Help: "The number of logs dropped because a logger's queue was full, by logger.",
+}, []string{"logger"})
+
+// LogsFailed counts the logs that a logger failed to write, by logger
+var LogsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "auth_logs_failed_total",
+	Help: "The number of logs a logger failed to write, by logger.",
+}, []string{"logger"})
+
+/****************************************************************************************
+* Exposition
+****************************************************************************************/
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		Logins,
+		PasswordHashDuration,
+		DBOperationDuration,
+		DBOperationErrors,
+		NoncesIssued,
+		Nonces,
+		NonceCleanouts,
+		NonceCleanoutLastSuccess,
+		LogsDropped,
+		LogsFailed,
+	)
+}
+
+// Handler serves the metrics in Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
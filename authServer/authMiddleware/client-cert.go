@@ -0,0 +1,53 @@
+package authMiddleware
+
+import (
+	"crypto/tls"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+)
+
+// Used for when a request requires a client certificate, but the connection
+// didn't present a verified one
+type ClientCertError struct{ ErrMsg string }
+
+func (err ClientCertError) Error() string { return err.ErrMsg }
+func NewClientCertError(msg string) error { return ClientCertError{msg} }
+
+// HasClientCert reports whether the connection presented a client certificate
+// that was verified against the client CAs. Certificates are only verified when
+// the service terminates TLS itself, so requests forwarded by a proxy never have
+// one.
+func HasClientCert(state *tls.ConnectionState) bool {
+	return state != nil && len(state.VerifiedChains) > 0
+}
+
+// RequireClientCert returns middleware that rejects requests without a verified
+// client certificate with a 403 response
+func RequireClientCert() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !HasClientCert(ctx.Request.TLS) {
+			apiErrors.WriteCode(ctx, apiErrors.ClientCertRequired, "")
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireAdminWithClientCert returns middleware that rejects requests without a
+// verified client certificate, like RequireClientCert, and then requests from
+// non-admin users, like RequireAdmin. It must run after RequireAuth.
+func RequireAdminWithClientCert() gin.HandlerFunc {
+	requireAdmin := RequireAdmin()
+
+	return func(ctx *gin.Context) {
+		if !HasClientCert(ctx.Request.TLS) {
+			apiErrors.WriteCode(ctx, apiErrors.ClientCertRequired, "")
+			return
+		}
+
+		requireAdmin(ctx)
+	}
+}
@@ -0,0 +1,22 @@
+package authMiddleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/authMetrics"
+)
+
+// Metrics returns middleware that counts every request and observes its
+// duration. Requests are labelled with the route's pattern rather than the
+// request's path, so that ids in paths don't create a series per id.
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		authMetrics.ObserveRequest(ctx.Request.Method, ctx.FullPath(), ctx.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,175 @@
+// Package authMiddleware contains Gin middleware that authenticates requests
+// using the JWTs issued by the auth service. It's used by the auth service's own
+// routes and can be imported by other Gin services. Those services can create
+// a jwtVerifier.Verifier using the auth service's public key and pass it to
+// RequireAuth.
+package authMiddleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/jwtVerifier"
+)
+
+// ClaimsKey is the key used to store a request's JWT claims in the gin context
+const ClaimsKey = "authClaims"
+
+// A TokenValidator validates a JWT and returns its claims. jwtVerifier.Verifier
+// implements TokenValidator.
+type TokenValidator interface {
+	Verify(tokenString string) (*jwtVerifier.JWTClaims, error)
+}
+
+// ValidatorFunc allows a plain function to be used as a TokenValidator
+type ValidatorFunc func(tokenString string) (*jwtVerifier.JWTClaims, error)
+
+func (vf ValidatorFunc) Verify(tokenString string) (*jwtVerifier.JWTClaims, error) {
+	return vf(tokenString)
+}
+
+// An Authenticator reads JWTs from the token sources in its options and
+// validates them with its TokenValidator.
+type Authenticator struct {
+	validator TokenValidator
+	options   Options
+}
+
+// NewAuthenticator returns an Authenticator. Any empty option is replaced by its
+// default value.
+func NewAuthenticator(validator TokenValidator, options Options) *Authenticator {
+	defaults := DefaultOptions()
+
+	if len(options.Sources) == 0 {
+		options.Sources = defaults.Sources
+	}
+	if len(options.CookieName) == 0 {
+		options.CookieName = defaults.CookieName
+	}
+	if options.CookieSameSite == 0 {
+		options.CookieSameSite = defaults.CookieSameSite
+	}
+	if len(options.CSRFCookieName) == 0 {
+		options.CSRFCookieName = defaults.CSRFCookieName
+	}
+	if len(options.CSRFHeaderName) == 0 {
+		options.CSRFHeaderName = defaults.CSRFHeaderName
+	}
+
+	return &Authenticator{validator, options}
+}
+
+// Options returns the Authenticator's options
+func (a *Authenticator) Options() Options {
+	return a.options
+}
+
+// ExtractClaims reads the JWT from the request and validates it. Expired tokens
+// return an ExpiredJWTError and requests that fail the CSRF check return a
+// CSRFError. A missing or otherwise invalid token returns a JWTError. Any other
+// error means the token couldn't be checked, e.g. because the validator's
+// database is unavailable, and is returned as is.
+func (a *Authenticator) ExtractClaims(ctx *gin.Context) (*jwtVerifier.JWTClaims, error) {
+	token, tokenErr := a.ExtractToken(ctx)
+	if tokenErr != nil {
+		return nil, tokenErr
+	}
+
+	return a.validator.Verify(token)
+}
+
+// RequireAuth returns middleware that rejects requests without a valid JWT with
+// a 401 response, or a 403 response if the request fails the CSRF check. If the
+// token couldn't be checked, the request is rejected with a 503 response. The
+// claims of a valid token are stored in the gin context and can be retrieved
+// with GetClaims.
+func (a *Authenticator) RequireAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, claimsErr := a.ExtractClaims(ctx)
+
+		if claimsErr != nil {
+			switch claimsErr.(type) {
+			case CSRFError:
+				apiErrors.WriteCode(ctx, apiErrors.CSRFFailed, "")
+			case jwtVerifier.ExpiredJWTError:
+				ctx.Header("WWW-Authenticate", `Bearer error="invalid_token", error_description="The token is expired"`)
+				apiErrors.WriteCode(ctx, apiErrors.ExpiredToken, "")
+			case jwtVerifier.JWTError:
+				ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+				apiErrors.WriteCode(ctx, apiErrors.InvalidToken, claimsErr.Error())
+			default:
+				// The error's message may come from the database, so it isn't sent
+				apiErrors.WriteCode(ctx, apiErrors.ServiceUnavailable, "")
+			}
+			return
+		}
+
+		ctx.Set(ClaimsKey, claims)
+		ctx.Next()
+	}
+}
+
+// ExtractClaims reads the JWT from the request's Authorization header, using
+// the default options, and validates it.
+func ExtractClaims(ctx *gin.Context, validator TokenValidator) (*jwtVerifier.JWTClaims, error) {
+	return NewAuthenticator(validator, DefaultOptions()).ExtractClaims(ctx)
+}
+
+// RequireAuth returns middleware that authenticates requests using the default
+// options. See Authenticator.RequireAuth.
+func RequireAuth(validator TokenValidator) gin.HandlerFunc {
+	return NewAuthenticator(validator, DefaultOptions()).RequireAuth()
+}
+
+// RequireAdmin returns middleware that rejects requests from non-admin users with
+// a 403 response. Impersonation tokens are never treated as admin tokens. It
+// must run after RequireAuth.
+func RequireAdmin() gin.HandlerFunc {
+	return RequireClaims(func(claims *jwtVerifier.JWTClaims) bool {
+		return claims.Admin && !claims.Impersonated()
+	})
+}
+
+// RequirePermission returns middleware that rejects requests whose token was not
+// granted the permission, i.e. the scope, passed to the function. Admin tokens
+// have every permission. It must run after RequireAuth.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return RequireClaims(func(claims *jwtVerifier.JWTClaims) bool {
+		return (claims.Admin && !claims.Impersonated()) || claims.HasScope(permission)
+	})
+}
+
+// RequireClaims returns middleware that rejects requests with a 403 response if
+// check returns false for the request's claims. Requests without claims are
+// rejected with a 401 response. It must run after RequireAuth.
+func RequireClaims(check func(claims *jwtVerifier.JWTClaims) bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := GetClaims(ctx)
+
+		if !ok {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			apiErrors.WriteCode(ctx, apiErrors.Unauthenticated, "")
+			return
+		}
+
+		if !check(claims) {
+			ctx.Header("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			apiErrors.WriteCode(ctx, apiErrors.Forbidden, "")
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// GetClaims returns the claims stored in the gin context by RequireAuth
+func GetClaims(ctx *gin.Context) (*jwtVerifier.JWTClaims, bool) {
+	value, exists := ctx.Get(ClaimsKey)
+	if !exists {
+		return nil, false
+	}
+
+	claims, ok := value.(*jwtVerifier.JWTClaims)
+
+	return claims, ok && claims != nil
+}
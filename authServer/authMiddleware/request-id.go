@@ -0,0 +1,66 @@
+package authMiddleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIdHeader is the header that carries a request's id
+const RequestIdHeader = "X-Request-Id"
+
+// RequestIdKey is the key used to store a request's id in the gin context
+const RequestIdKey = "requestId"
+
+// Request ids sent by clients or proxies are only kept if they're at most this
+// long and only contain printable ASCII characters
+const maxRequestIdLength = 128
+
+// RequestId returns middleware that gives every request an id. The id sent in
+// the X-Request-Id header is kept, so that a request can be followed through
+// several services. Otherwise a random id is generated. The id is stored in the
+// gin context, where it can be retrieved with GetRequestId, and is returned in
+// the response's X-Request-Id header.
+func RequestId() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestId := ctx.GetHeader(RequestIdHeader)
+
+		if !validRequestId(requestId) {
+			requestId = newRequestId()
+		}
+
+		ctx.Set(RequestIdKey, requestId)
+		ctx.Header(RequestIdHeader, requestId)
+
+		ctx.Next()
+	}
+}
+
+// GetRequestId returns the id stored in the gin context by RequestId
+func GetRequestId(ctx *gin.Context) string {
+	return ctx.GetString(RequestIdKey)
+}
+
+func validRequestId(requestId string) bool {
+	if len(requestId) == 0 || len(requestId) > maxRequestIdLength {
+		return false
+	}
+
+	for _, char := range requestId {
+		if char < ' ' || char > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestId() string {
+	idBytes := make([]byte, 16)
+
+	// crypto/rand only fails if the system's random source is unavailable
+	rand.Read(idBytes)
+
+	return hex.EncodeToString(idBytes)
+}
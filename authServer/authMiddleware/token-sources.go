@@ -0,0 +1,225 @@
+package authMiddleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/jwtVerifier"
+)
+
+/****************************************************************************************
+* CSRFError
+****************************************************************************************/
+
+// Used for when a request authenticated with a cookie fails the CSRF check
+type CSRFError struct{ ErrMsg string }
+
+func (err CSRFError) Error() string { return err.ErrMsg }
+func NewCSRFError(msg string) error { return CSRFError{msg} }
+
+/****************************************************************************************
+* Token Sources
+****************************************************************************************/
+
+// A TokenSource is a place in a request that a JWT can be read from.
+//
+// HeaderSource reads the token from an Authorization header using the Bearer
+// scheme described in RFC 6750, e.g. "Authorization: Bearer <token>".
+// LegacyHeaderSource reads a raw token, without a scheme, from the Authorization
+// header. It exists for clients written before the Bearer scheme was supported.
+// CookieSource reads the token from a cookie set by SetTokenCookies. Requests
+// that aren't GET, HEAD or OPTIONS must also pass the CSRF check.
+type TokenSource string
+
+const (
+	HeaderSource       TokenSource = "header"
+	LegacyHeaderSource TokenSource = "legacy-header"
+	CookieSource       TokenSource = "cookie"
+)
+
+// ParseTokenSources parses a comma separated list of token sources, e.g.
+// "header,cookie". An empty string returns the default sources.
+func ParseTokenSources(str string) ([]TokenSource, error) {
+	sources := make([]TokenSource, 0)
+
+	for _, value := range strings.Split(str, ",") {
+		source := TokenSource(strings.ToLower(strings.TrimSpace(value)))
+
+		switch source {
+		case "":
+			continue
+		case HeaderSource, LegacyHeaderSource, CookieSource:
+			sources = append(sources, source)
+		default:
+			return nil, fmt.Errorf("invalid token source '%s'", value)
+		}
+	}
+
+	if len(sources) == 0 {
+		return DefaultOptions().Sources, nil
+	}
+
+	return sources, nil
+}
+
+// Options configures where an Authenticator looks for tokens. Sources are
+// checked in order and the first token found is used. The cookie options are
+// only used if Sources contains CookieSource.
+type Options struct {
+	Sources        []TokenSource
+	CookieName     string
+	CookieSecure   bool
+	CookieSameSite http.SameSite
+	CSRFCookieName string
+	CSRFHeaderName string
+}
+
+// DefaultOptions accepts Bearer tokens and legacy raw tokens from the
+// Authorization header.
+func DefaultOptions() Options {
+	return Options{
+		Sources:        []TokenSource{HeaderSource, LegacyHeaderSource},
+		CookieName:     "auth_token",
+		CookieSecure:   true,
+		CookieSameSite: http.SameSiteStrictMode,
+		CSRFCookieName: "csrf_token",
+		CSRFHeaderName: "X-CSRF-Token",
+	}
+}
+
+// UsesSource returns true if the options include the token source
+func (o Options) UsesSource(source TokenSource) bool {
+	for _, s := range o.Sources {
+		if s == source {
+			return true
+		}
+	}
+
+	return false
+}
+
+/****************************************************************************************
+* Bearer Tokens
+****************************************************************************************/
+
+// b64token is the token syntax defined in RFC 6750, section 2.1
+var b64token = regexp.MustCompile(`^[A-Za-z0-9\-._~+/]+=*$`)
+
+// ParseBearerToken extracts the token from an Authorization header value that
+// uses the Bearer scheme. The scheme is case insensitive. The function returns
+// false if the value doesn't use the Bearer scheme or the token is malformed.
+func ParseBearerToken(header string) (string, bool) {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(parts[1])
+	if !b64token.MatchString(token) {
+		return "", false
+	}
+
+	return token, true
+}
+
+/****************************************************************************************
+* Cookies
+****************************************************************************************/
+
+// safeMethods don't change state, so they aren't subject to the CSRF check
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// checkCSRF implements the double submit cookie pattern. The value of the CSRF
+// header must match the value of the CSRF cookie. Other sites can make a browser
+// send the cookie, but can't read it in order to set the header.
+func (a *Authenticator) checkCSRF(ctx *gin.Context) error {
+	if safeMethods[ctx.Request.Method] {
+		return nil
+	}
+
+	cookieToken, cookieErr := ctx.Cookie(a.options.CSRFCookieName)
+	headerToken := ctx.GetHeader(a.options.CSRFHeaderName)
+
+	if cookieErr != nil || len(cookieToken) == 0 || len(headerToken) == 0 {
+		return NewCSRFError("missing csrf token")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) != 1 {
+		return NewCSRFError("invalid csrf token")
+	}
+
+	return nil
+}
+
+// SetTokenCookies stores a token in an HttpOnly cookie and sets a new CSRF
+// cookie for browser clients. The CSRF cookie can be read by JavaScript, so that
+// clients can copy it into the CSRF header. maxAge is in seconds.
+func (a *Authenticator) SetTokenCookies(ctx *gin.Context, token string, maxAge int) error {
+	csrfBytes := make([]byte, 32)
+	if _, randErr := rand.Read(csrfBytes); randErr != nil {
+		return randErr
+	}
+
+	csrfToken := base64.RawURLEncoding.EncodeToString(csrfBytes)
+
+	ctx.SetSameSite(a.options.CookieSameSite)
+	ctx.SetCookie(a.options.CookieName, token, maxAge, "/", "", a.options.CookieSecure, true)
+	ctx.SetCookie(a.options.CSRFCookieName, csrfToken, maxAge, "/", "", a.options.CookieSecure, false)
+
+	return nil
+}
+
+// ClearTokenCookies removes the cookies set by SetTokenCookies
+func (a *Authenticator) ClearTokenCookies(ctx *gin.Context) {
+	ctx.SetSameSite(a.options.CookieSameSite)
+	ctx.SetCookie(a.options.CookieName, "", -1, "/", "", a.options.CookieSecure, true)
+	ctx.SetCookie(a.options.CSRFCookieName, "", -1, "/", "", a.options.CookieSecure, false)
+}
+
+/****************************************************************************************
+* Token Extraction
+****************************************************************************************/
+
+// ExtractToken returns the first token found in the request's configured token
+// sources. It returns a JWTError if no token is found and a CSRFError if a
+// cookie token fails the CSRF check.
+func (a *Authenticator) ExtractToken(ctx *gin.Context) (string, error) {
+	authorization := strings.TrimSpace(ctx.GetHeader("Authorization"))
+
+	for _, source := range a.options.Sources {
+		switch source {
+		case HeaderSource:
+			if token, ok := ParseBearerToken(authorization); ok {
+				return token, nil
+			}
+		case LegacyHeaderSource:
+			if len(authorization) > 0 && !strings.Contains(authorization, " ") {
+				return authorization, nil
+			}
+		case CookieSource:
+			token, cookieErr := ctx.Cookie(a.options.CookieName)
+			if cookieErr != nil || len(token) == 0 {
+				continue
+			}
+
+			if csrfErr := a.checkCSRF(ctx); csrfErr != nil {
+				return "", csrfErr
+			}
+
+			return token, nil
+		}
+	}
+
+	return "", jwtVerifier.NewJWTError("missing jwt from request")
+}
@@ -0,0 +1,52 @@
+package authMiddleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"methompson.com/auth-microservice/authServer/authTracing"
+)
+
+// Tracing returns middleware that starts a span for every request. A trace
+// started by the client or a proxy is continued from the request's traceparent
+// header. The span is stored in the context of Gin's request, where the
+// AuthController picks it up, and is named after the route's pattern.
+func Tracing() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		parent := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		route := ctx.FullPath()
+		name := ctx.Request.Method
+		if len(route) > 0 {
+			name += " " + route
+		}
+
+		spanCtx, span := authTracing.Tracer().Start(parent, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", ctx.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", ctx.Request.URL.Path),
+				attribute.String("client.address", ctx.ClientIP()),
+				attribute.String("user_agent.original", ctx.Request.UserAgent()),
+				attribute.String("request.id", GetRequestId(ctx)),
+			),
+		)
+		defer span.End()
+
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+}
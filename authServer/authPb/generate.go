@@ -0,0 +1,5 @@
+// Package authPb contains the protobuf messages and gRPC service generated from
+// auth.proto. Run go generate after changing auth.proto.
+package authPb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative auth.proto
@@ -0,0 +1,270 @@
+// Package authTLS serves the service over TLS. Certificates are read from the
+// files set in TLS_CERT_FILE and TLS_KEY_FILE and reloaded when the files change.
+// Client certificates are verified against TLS_CLIENT_CA_FILE, if it's set.
+package authTLS
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// Used for when the TLS options are invalid or the certificates can't be loaded
+type TLSError struct{ ErrMsg string }
+
+func (err TLSError) Error() string { return err.ErrMsg }
+func NewTLSError(msg string) error { return TLSError{msg} }
+
+/****************************************************************************************
+* TLSOptions
+****************************************************************************************/
+
+// Client authentication modes that can be set in TLS_CLIENT_AUTH
+const ClientAuthOptional = "optional"
+const ClientAuthRequire = "require"
+
+const defaultReloadInterval = 10 * time.Second
+
+// TLSOptions configures the certificates and client authentication of the
+// servers
+type TLSOptions struct {
+	// The PEM encoded certificate chain and private key. TLS is disabled if
+	// they're empty.
+	CertFile string
+	KeyFile  string
+	// The PEM encoded CAs client certificates are verified against. Clients
+	// aren't asked for a certificate if it's empty.
+	ClientCAFile string
+	// ClientAuthOptional verifies client certificates that are sent.
+	// ClientAuthRequire rejects connections without a valid client certificate.
+	ClientAuth string
+	// Admin routes require a verified client certificate along with an admin
+	// token
+	RequireAdminClientCert bool
+	// How often the files are checked for changes
+	ReloadInterval time.Duration
+}
+
+// Enabled reports whether the servers are served over TLS
+func (options TLSOptions) Enabled() bool {
+	return len(options.CertFile) > 0
+}
+
+// GetTLSOptions reads the TLSOptions from the environment. TLS_CLIENT_AUTH
+// defaults to optional and TLS_RELOAD_INTERVAL to 10 seconds.
+func GetTLSOptions() (TLSOptions, error) {
+	options := TLSOptions{
+		CertFile:               os.Getenv(constants.TLS_CERT_FILE),
+		KeyFile:                os.Getenv(constants.TLS_KEY_FILE),
+		ClientCAFile:           os.Getenv(constants.TLS_CLIENT_CA_FILE),
+		ClientAuth:             os.Getenv(constants.TLS_CLIENT_AUTH),
+		RequireAdminClientCert: os.Getenv(constants.TLS_ADMIN_CLIENT_CERT) == "true",
+		ReloadInterval:         defaultReloadInterval,
+	}
+
+	if len(options.ClientAuth) == 0 {
+		options.ClientAuth = ClientAuthOptional
+	}
+
+	if (len(options.CertFile) == 0) != (len(options.KeyFile) == 0) {
+		return options, NewTLSError("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	if !options.Enabled() && len(options.ClientCAFile) > 0 {
+		return options, NewTLSError("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	if options.ClientAuth != ClientAuthOptional && options.ClientAuth != ClientAuthRequire {
+		return options, NewTLSError("TLS_CLIENT_AUTH must be optional or require")
+	}
+
+	if options.RequireAdminClientCert && len(options.ClientCAFile) == 0 {
+		return options, NewTLSError("TLS_ADMIN_CLIENT_CERT requires TLS_CLIENT_CA_FILE")
+	}
+
+	if interval := os.Getenv(constants.TLS_RELOAD_INTERVAL); len(interval) > 0 {
+		parsed, parseErr := time.ParseDuration(interval)
+		if parseErr != nil || parsed <= 0 {
+			return options, NewTLSError("TLS_RELOAD_INTERVAL must be a positive duration, e.g. 10s")
+		}
+
+		options.ReloadInterval = parsed
+	}
+
+	return options, nil
+}
+
+/****************************************************************************************
+* CertificateReloader
+****************************************************************************************/
+
+// CertificateReloader holds the certificate and client CAs read from the files
+// in its TLSOptions. Connections use the files' contents at the time of their
+// handshake, so certificates can be renewed without restarting the service.
+type CertificateReloader struct {
+	options     TLSOptions
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	// The modification time of each file when it was last loaded
+	modTimes map[string]time.Time
+}
+
+// NewCertificateReloader loads the certificate and client CAs. It returns an
+// error if they can't be loaded.
+func NewCertificateReloader(options TLSOptions) (*CertificateReloader, error) {
+	cr := &CertificateReloader{options: options}
+
+	if reloadErr := cr.Reload(); reloadErr != nil {
+		return nil, reloadErr
+	}
+
+	return cr, nil
+}
+
+// Reload reads the files again. If they can't be loaded, e.g. because they're
+// being replaced, the previous certificate and client CAs are kept.
+func (cr *CertificateReloader) Reload() error {
+	modTimes := cr.currentModTimes()
+
+	certificate, certErr := tls.LoadX509KeyPair(cr.options.CertFile, cr.options.KeyFile)
+	if certErr != nil {
+		return NewTLSError("error loading the TLS certificate: " + certErr.Error())
+	}
+
+	var clientCAs *x509.CertPool
+
+	if len(cr.options.ClientCAFile) > 0 {
+		caBytes, readErr := os.ReadFile(cr.options.ClientCAFile)
+		if readErr != nil {
+			return NewTLSError("error reading the client CA file: " + readErr.Error())
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return NewTLSError("the client CA file contains no PEM encoded certificates")
+		}
+	}
+
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	cr.certificate = &certificate
+	cr.clientCAs = clientCAs
+	cr.modTimes = modTimes
+
+	return nil
+}
+
+// Watch checks the files for changes every ReloadInterval until ctx is done and
+// reloads them when they change. onReload is called with the result of every
+// reload. A failed reload is retried at the next check.
+func (cr *CertificateReloader) Watch(ctx context.Context, onReload func(err error)) {
+	ticker := time.NewTicker(cr.options.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if cr.changed() {
+			onReload(cr.Reload())
+		}
+	}
+}
+
+// Certificate returns the certificate served to clients
+func (cr *CertificateReloader) Certificate() *tls.Certificate {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	return cr.certificate
+}
+
+// TLSConfig returns the configuration of the servers. Each handshake uses the
+// certificate and client CAs loaded at that time.
+func (cr *CertificateReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cr.Certificate(), nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return cr.handshakeConfig(), nil
+		},
+	}
+}
+
+func (cr *CertificateReloader) handshakeConfig() *tls.Config {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{*cr.certificate},
+	}
+
+	if cr.clientCAs != nil {
+		config.ClientCAs = cr.clientCAs
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+
+		if cr.options.ClientAuth == ClientAuthRequire {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return config
+}
+
+func (cr *CertificateReloader) files() []string {
+	files := []string{cr.options.CertFile, cr.options.KeyFile}
+
+	if len(cr.options.ClientCAFile) > 0 {
+		files = append(files, cr.options.ClientCAFile)
+	}
+
+	return files
+}
+
+func (cr *CertificateReloader) currentModTimes() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+
+	for _, file := range cr.files() {
+		if info, statErr := os.Stat(file); statErr == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+// changed reports whether any file was modified, added or removed since it was
+// last loaded
+func (cr *CertificateReloader) changed() bool {
+	current := cr.currentModTimes()
+
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	if len(current) != len(cr.modTimes) {
+		return true
+	}
+
+	for file, modTime := range current {
+		if !modTime.Equal(cr.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,220 @@
+// Package authTracing configures OpenTelemetry tracing. Spans are started with
+// Tracer() and exported by the exporter set in TRACING_EXPORTER. Trace context
+// is read from and written to W3C traceparent headers.
+package authTracing
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// Used for when tracing can't be configured
+type TracingError struct{ ErrMsg string }
+
+func (err TracingError) Error() string { return err.ErrMsg }
+func NewTracingError(msg string) error { return TracingError{msg} }
+
+// The name of the service's tracer
+const TracerName = "methompson.com/auth-microservice"
+
+// Tracer returns the service's tracer. Until tracing is configured, its spans
+// aren't recorded.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// EndSpan records the error, if any, on the span and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+/****************************************************************************************
+* TracingOptions
+****************************************************************************************/
+
+// Exporters that can be set in TRACING_EXPORTER
+const ExporterNone = "none"
+const ExporterStdout = "stdout"
+const ExporterFile = "file"
+const ExporterOTLP = "otlp"
+
+const defaultTracingServiceName = "auth-microservice"
+
+// TracingOptions configures the exporter and sampling of spans
+type TracingOptions struct {
+	// ExporterNone, ExporterStdout, ExporterFile or ExporterOTLP
+	Exporter string
+	// The file spans are appended to by ExporterFile
+	FilePath string
+	// The OTLP/HTTP endpoint spans are sent to by ExporterOTLP, e.g.
+	// http://localhost:4318. The OTEL_EXPORTER_OTLP_* variables are used if it's
+	// empty.
+	Endpoint string
+	// The fraction of traces recorded, from 0 to 1. Traces started by another
+	// service follow that service's decision.
+	SampleRatio float64
+	// The service.name of the spans
+	ServiceName string
+}
+
+// GetTracingOptions reads the TracingOptions from the environment. Tracing is
+// disabled unless TRACING_EXPORTER is set. TRACING_SAMPLE_RATIO defaults to 1
+// and TRACING_SERVICE_NAME to auth-microservice.
+func GetTracingOptions() (TracingOptions, error) {
+	options := TracingOptions{
+		Exporter:    os.Getenv(constants.TRACING_EXPORTER),
+		FilePath:    os.Getenv(constants.TRACING_FILE),
+		Endpoint:    os.Getenv(constants.TRACING_OTLP_ENDPOINT),
+		SampleRatio: 1,
+		ServiceName: os.Getenv(constants.TRACING_SERVICE_NAME),
+	}
+
+	if len(options.Exporter) == 0 {
+		options.Exporter = ExporterNone
+	}
+
+	if len(options.ServiceName) == 0 {
+		options.ServiceName = defaultTracingServiceName
+	}
+
+	switch options.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+	case ExporterFile:
+		if len(options.FilePath) == 0 {
+			return options, NewTracingError("TRACING_FILE must be set to use the file exporter")
+		}
+	default:
+		return options, NewTracingError("TRACING_EXPORTER must be none, stdout, file or otlp")
+	}
+
+	if ratio := os.Getenv(constants.TRACING_SAMPLE_RATIO); len(ratio) > 0 {
+		parsed, parseErr := strconv.ParseFloat(ratio, 64)
+		if parseErr != nil || parsed < 0 || parsed > 1 {
+			return options, NewTracingError("TRACING_SAMPLE_RATIO must be a number from 0 to 1")
+		}
+
+		options.SampleRatio = parsed
+	}
+
+	return options, nil
+}
+
+/****************************************************************************************
+* Configuration
+****************************************************************************************/
+
+// ConfigureTracing sets the global propagator to W3C trace context and baggage
+// and, unless the exporter is ExporterNone, sets a global TracerProvider that
+// exports spans in batches. The function returned flushes the queued spans and
+// closes the exporter.
+func ConfigureTracing(options TracingOptions) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	noop := func(ctx context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var file io.Closer
+
+	switch options.Exporter {
+	case ExporterNone, "":
+		return noop, nil
+	case ExporterStdout:
+		stdoutExporter, exporterErr := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if exporterErr != nil {
+			return noop, exporterErr
+		}
+		exporter = stdoutExporter
+	case ExporterFile:
+		handle, openErr := os.OpenFile(options.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if openErr != nil {
+			return noop, openErr
+		}
+
+		fileExporter, exporterErr := stdouttrace.New(stdouttrace.WithWriter(handle))
+		if exporterErr != nil {
+			handle.Close()
+			return noop, exporterErr
+		}
+		exporter, file = fileExporter, handle
+	case ExporterOTLP:
+		exporterOptions := []otlptracehttp.Option{}
+		if len(options.Endpoint) > 0 {
+			exporterOptions = append(exporterOptions, otlptracehttp.WithEndpointURL(options.Endpoint))
+		}
+
+		otlpExporter, exporterErr := otlptracehttp.New(context.Background(), exporterOptions...)
+		if exporterErr != nil {
+			return noop, exporterErr
+		}
+		exporter = otlpExporter
+	default:
+		return noop, NewTracingError("unknown tracing exporter: " + options.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", options.ServiceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	shutdown := func(ctx context.Context) error {
+		shutdownErr := provider.Shutdown(ctx)
+
+		if file != nil {
+			if closeErr := file.Close(); shutdownErr == nil {
+				shutdownErr = closeErr
+			}
+		}
+
+		return shutdownErr
+	}
+
+	return shutdown, nil
+}
+
+/****************************************************************************************
+* Database Operations
+****************************************************************************************/
+
+// StartDBOperation starts a span for a DatabaseController operation and returns
+// the function that ends it with the operation's error. Operations are only
+// traced as part of a trace that's already started, e.g. by a request, so that
+// an operation run outside of a request doesn't start a trace of its own.
+func StartDBOperation(ctx context.Context, operation string) func(err error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return func(err error) {}
+	}
+
+	_, span := Tracer().Start(ctx, "DatabaseController."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation", operation)),
+	)
+
+	return func(err error) {
+		EndSpan(span, err)
+	}
+}
@@ -0,0 +1,476 @@
+package authUtils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authMetrics"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+/****************************************************************************************
+* LogEntry
+****************************************************************************************/
+
+// LogEntry is a queued log. Exactly one of Request and Info is set.
+type LogEntry struct {
+	Request *RequestLogData
+	Info    *InfoLogData
+}
+
+// BatchLogger is an AuthLogger that can write several logs at once, e.g. with a
+// single database insert
+type BatchLogger interface {
+	AddLogs(entries []LogEntry) error
+}
+
+/****************************************************************************************
+* AsyncLoggerOptions
+****************************************************************************************/
+
+// Drop policies decide which log is dropped when an AsyncLogger's queue is full.
+// DropNewest drops the log being added and DropOldest drops the oldest queued log.
+const DropNewest = "newest"
+const DropOldest = "oldest"
+
+const defaultLogQueueSize = 1000
+const defaultLogWorkers = 1
+const defaultLogBatchSize = 100
+const defaultLogFlushTimeout = 5 * time.Second
+const defaultAuditLogWait = time.Second
+
+// AsyncLoggerOptions configures an AsyncLogger. Zero values use the defaults.
+type AsyncLoggerOptions struct {
+	// The number of logs that can wait to be written. Defaults to 1000.
+	QueueSize int
+	// The number of goroutines writing logs. Defaults to 1. With more than one
+	// worker, logs may be written out of order.
+	Workers int
+	// The most logs written to a BatchLogger at once. Defaults to 100.
+	BatchSize int
+	// DropNewest or DropOldest. Defaults to DropNewest.
+	DropPolicy string
+	// How long Close waits for queued logs to be written. Defaults to 5s.
+	FlushTimeout time.Duration
+	// How long adding an audit log waits for room in a full audit queue before
+	// the log is dropped. Defaults to 1s.
+	AuditWait time.Duration
+	// The logger label of the logger's metrics, e.g. file
+	Name string
+	// Skips the audit events that are part of the audit chain, e.g. for the
+	// database logger, since the database already stores them
+	SkipAudit bool
+}
+
+// GetAsyncLoggerOptions reads the AsyncLoggerOptions from the environment
+func GetAsyncLoggerOptions() (AsyncLoggerOptions, error) {
+	options := AsyncLoggerOptions{
+		DropPolicy: os.Getenv(constants.LOG_DROP_POLICY),
+	}
+
+	if len(options.DropPolicy) > 0 && options.DropPolicy != DropNewest && options.DropPolicy != DropOldest {
+		return options, NewLoggingError("LOG_DROP_POLICY must be newest or oldest")
+	}
+
+	for envName, value := range map[string]*int{
+		constants.LOG_QUEUE_SIZE:    &options.QueueSize,
+		constants.LOG_QUEUE_WORKERS: &options.Workers,
+		constants.LOG_BATCH_SIZE:    &options.BatchSize,
+	} {
+		str := os.Getenv(envName)
+		if len(str) == 0 {
+			continue
+		}
+
+		parsed, parseErr := strconv.Atoi(str)
+		if parseErr != nil || parsed < 1 {
+			return options, NewLoggingError(envName + " must be a positive number")
+		}
+
+		*value = parsed
+	}
+
+	return options, nil
+}
+
+func (options AsyncLoggerOptions) withDefaults() AsyncLoggerOptions {
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultLogQueueSize
+	}
+	if options.Workers <= 0 {
+		options.Workers = defaultLogWorkers
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultLogBatchSize
+	}
+	if len(options.DropPolicy) == 0 {
+		options.DropPolicy = DropNewest
+	}
+	if options.FlushTimeout <= 0 {
+		options.FlushTimeout = defaultLogFlushTimeout
+	}
+	if options.AuditWait <= 0 {
+		options.AuditWait = defaultAuditLogWait
+	}
+
+	return options
+}
+
+/****************************************************************************************
+* AsyncLogger
+****************************************************************************************/
+
+// AsyncLoggerStats counts what happened to the logs added to an AsyncLogger
+type AsyncLoggerStats struct {
+	// Logs waiting in the queue
+	Pending int
+	// Logs written to the wrapped logger
+	Written uint64
+	// Logs dropped because the queue was full, including audit logs that
+	// waited longer than AuditWait
+	Dropped uint64
+	// Logs the wrapped logger failed to write
+	Failed uint64
+}
+
+// AsyncLogger is an AuthLogger that queues logs and writes them to another
+// AuthLogger from background goroutines, so that adding a log doesn't wait on a
+// file or the database. Logs are written in batches when the wrapped logger is
+// a BatchLogger. When the queue is full, logs are dropped according to the
+// DropPolicy. Audit logs have a queue of their own, so that they're never
+// dropped to make room for other logs. When the audit queue is full, an audit
+// log waits up to AuditWait for room, so that a slow logger can't hold up the
+// actions being audited.
+type AsyncLogger struct {
+	logger     AuthLogger
+	options    AsyncLoggerOptions
+	queue      chan LogEntry
+	auditQueue chan LogEntry
+	workers    sync.WaitGroup
+
+	// The mutex is held for reading while logs are added, and for writing while
+	// the queue is closed
+	mutex  sync.RWMutex
+	closed bool
+
+	written atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+
+	// The error of the last write, or nil if it succeeded
+	writeErrMutex sync.Mutex
+	writeErr      error
+}
+
+// NewAsyncLogger wraps the logger and starts the workers that write to it
+func NewAsyncLogger(logger AuthLogger, options AsyncLoggerOptions) *AsyncLogger {
+	options = options.withDefaults()
+
+	al := &AsyncLogger{
+		logger:     logger,
+		options:    options,
+		queue:      make(chan LogEntry, options.QueueSize),
+		auditQueue: make(chan LogEntry, options.QueueSize),
+	}
+
+	al.workers.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go al.work()
+	}
+
+	return al
+}
+
+func (al *AsyncLogger) AddRequestLog(log *RequestLogData) error {
+	// The log is copied, so that the caller can reuse it
+	entry := *log
+
+	return al.enqueue(LogEntry{Request: &entry}, false)
+}
+
+func (al *AsyncLogger) AddInfoLog(log *InfoLogData) error {
+	if al.options.SkipAudit && log.Type == AuditLogType {
+		return nil
+	}
+
+	entry := *log
+
+	audit := log.Type == AuditLogType || log.Type == AuditUnstoredLogType
+
+	return al.enqueue(LogEntry{Info: &entry}, audit)
+}
+
+// Name returns the logger label of the logger's metrics
+func (al *AsyncLogger) Name() string {
+	return al.options.Name
+}
+
+// Stats returns the logger's counters
+func (al *AsyncLogger) Stats() AsyncLoggerStats {
+	return AsyncLoggerStats{
+		Pending: len(al.queue) + len(al.auditQueue),
+		Written: al.written.Load(),
+		Dropped: al.dropped.Load(),
+		Failed:  al.failed.Load(),
+	}
+}
+
+// enqueue adds the entry to the queue. Audit entries are added to the audit
+// queue, waiting up to AuditWait for room if it's full.
+func (al *AsyncLogger) enqueue(entry LogEntry, audit bool) error {
+	al.mutex.RLock()
+	defer al.mutex.RUnlock()
+
+	if al.closed {
+		return NewLoggingError("logger is closed")
+	}
+
+	if audit {
+		timer := time.NewTimer(al.options.AuditWait)
+		defer timer.Stop()
+
+		select {
+		case al.auditQueue <- entry:
+			return nil
+		case <-timer.C:
+			al.drop()
+			return NewLoggingError("audit log dropped because the audit log queue is full")
+		}
+	}
+
+	for {
+		select {
+		case al.queue <- entry:
+			return nil
+		default:
+		}
+
+		if al.options.DropPolicy == DropNewest {
+			al.drop()
+			return nil
+		}
+
+		// Only the queue of other logs is emptied, so audit logs are never
+		// dropped. A worker may empty the queue before the oldest log is
+		// dropped, in which case there's room on the next attempt.
+		select {
+		case <-al.queue:
+			al.drop()
+		default:
+		}
+	}
+}
+
+func (al *AsyncLogger) drop() {
+	al.dropped.Add(1)
+	authMetrics.LogsDropped.WithLabelValues(al.options.Name).Inc()
+}
+
+// work writes logs until both queues are closed and empty. It takes whatever is
+// queued, up to BatchSize logs, and writes it as a batch. Audit logs are taken
+// first.
+func (al *AsyncLogger) work() {
+	defer al.workers.Done()
+
+	// A closed queue is set to nil, so that it's no longer received from
+	queue, auditQueue := al.queue, al.auditQueue
+
+	// next takes a queued log. If wait is false, it returns right away when
+	// both queues are empty.
+	next := func(wait bool) (LogEntry, bool) {
+		for queue != nil || auditQueue != nil {
+			select {
+			case entry, ok := <-auditQueue:
+				if ok {
+					return entry, true
+				}
+				auditQueue = nil
+				continue
+			default:
+			}
+
+			if !wait {
+				select {
+				case entry, ok := <-queue:
+					if ok {
+						return entry, true
+					}
+					queue = nil
+					continue
+				default:
+					return LogEntry{}, false
+				}
+			}
+
+			select {
+			case entry, ok := <-auditQueue:
+				if ok {
+					return entry, true
+				}
+				auditQueue = nil
+			case entry, ok := <-queue:
+				if ok {
+					return entry, true
+				}
+				queue = nil
+			}
+		}
+
+		return LogEntry{}, false
+	}
+
+	for entry, ok := next(true); ok; entry, ok = next(true) {
+		batch := []LogEntry{entry}
+
+		for len(batch) < al.options.BatchSize {
+			entry, ok := next(false)
+			if !ok {
+				break
+			}
+			batch = append(batch, entry)
+		}
+
+		al.write(batch)
+	}
+}
+
+func (al *AsyncLogger) write(batch []LogEntry) {
+	if batchLogger, ok := al.logger.(BatchLogger); ok {
+		logErr := batchLogger.AddLogs(batch)
+		al.setWriteErr(logErr)
+
+		if logErr != nil {
+			al.fail(len(batch))
+			ReportLoggerError(logErr)
+			return
+		}
+
+		al.written.Add(uint64(len(batch)))
+		return
+	}
+
+	for _, entry := range batch {
+		var logErr error
+
+		if entry.Request != nil {
+			logErr = al.logger.AddRequestLog(entry.Request)
+		} else {
+			logErr = al.logger.AddInfoLog(entry.Info)
+		}
+
+		al.setWriteErr(logErr)
+
+		if logErr != nil {
+			al.fail(1)
+			ReportLoggerError(logErr)
+			continue
+		}
+
+		al.written.Add(1)
+	}
+}
+
+func (al *AsyncLogger) fail(count int) {
+	al.failed.Add(uint64(count))
+	authMetrics.LogsFailed.WithLabelValues(al.options.Name).Add(float64(count))
+}
+
+func (al *AsyncLogger) setWriteErr(err error) {
+	al.writeErrMutex.Lock()
+	defer al.writeErrMutex.Unlock()
+
+	al.writeErr = err
+}
+
+// CheckHealth returns an error if the logger is closed, its queue is full, its
+// last write failed or the wrapped logger reports that it can't write logs
+func (al *AsyncLogger) CheckHealth() error {
+	al.mutex.RLock()
+	closed := al.closed
+	al.mutex.RUnlock()
+
+	if closed {
+		return NewLoggingError("logger is closed")
+	}
+
+	if len(al.queue) >= cap(al.queue) {
+		return NewLoggingError("log queue is full")
+	}
+
+	if len(al.auditQueue) >= cap(al.auditQueue) {
+		return NewLoggingError("audit log queue is full")
+	}
+
+	al.writeErrMutex.Lock()
+	writeErr := al.writeErr
+	al.writeErrMutex.Unlock()
+
+	if writeErr != nil {
+		return NewLoggingError("last write failed: " + writeErr.Error())
+	}
+
+	if checked, ok := al.logger.(HealthCheckedLogger); ok {
+		return checked.CheckHealth()
+	}
+
+	return nil
+}
+
+// Reopen reopens the wrapped logger's file, if it has one
+func (al *AsyncLogger) Reopen() error {
+	if reopenable, ok := al.logger.(ReopenableLogger); ok {
+		return reopenable.Reopen()
+	}
+
+	return nil
+}
+
+// Close stops accepting logs, waits up to FlushTimeout for the queued logs to be
+// written and closes the wrapped logger, if it can be closed. The wrapped logger
+// is also closed if the logs aren't written in time, so that it stops a write in
+// progress, e.g. an HTTPLogger's retries.
+func (al *AsyncLogger) Close() error {
+	al.mutex.Lock()
+
+	if al.closed {
+		al.mutex.Unlock()
+		return nil
+	}
+
+	al.closed = true
+	close(al.queue)
+	close(al.auditQueue)
+
+	al.mutex.Unlock()
+
+	flushed := make(chan struct{})
+	go func() {
+		al.workers.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(al.options.FlushTimeout):
+		if closer, ok := al.logger.(io.Closer); ok {
+			closer.Close()
+		}
+
+		return NewLoggingError(fmt.Sprintf("%d logs were not written before the logger was closed", al.Stats().Pending))
+	}
+
+	if dropped := al.dropped.Load(); dropped > 0 {
+		ReportLoggerError(NewLoggingError(fmt.Sprintf("%d logs were dropped because the log queue was full", dropped)))
+	}
+
+	if closer, ok := al.logger.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -5,10 +5,12 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/sha3"
 
+	"methompson.com/auth-microservice/authServer/authMetrics"
 	"methompson.com/auth-microservice/authServer/constants"
 )
 
@@ -45,12 +47,18 @@ func HashBytes(bytes []byte) string {
 }
 
 func HashPassword(pass string) (string, error) {
+	start := time.Now()
 	bytes, err := bcrypt.GenerateFromPassword([]byte(pass), hashCost)
+	authMetrics.PasswordHashDuration.WithLabelValues(authMetrics.HashOperation).Observe(time.Since(start).Seconds())
+
 	return string(bytes), err
 }
 
 func CheckPasswordHash(password string, hash string) bool {
+	start := time.Now()
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	authMetrics.PasswordHashDuration.WithLabelValues(authMetrics.CompareOperation).Observe(time.Since(start).Seconds())
+
 	return err == nil
 }
 
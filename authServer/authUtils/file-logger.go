@@ -0,0 +1,400 @@
+package authUtils
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+/****************************************************************************************
+* FileLoggerOptions
+****************************************************************************************/
+
+// FileLoggerOptions configures log rotation and retention. Zero values disable
+// the option.
+type FileLoggerOptions struct {
+	// The log file is rotated once it reaches this many bytes
+	MaxSize int64
+	// The log file is rotated once it has been open this long
+	RotationInterval time.Duration
+	// Rotated files are compressed with gzip
+	Compress bool
+	// The number of rotated files kept. The oldest are removed.
+	MaxFiles int
+	// Rotated files older than this are removed
+	MaxAge time.Duration
+}
+
+// GetFileLoggerOptions reads the FileLoggerOptions from the environment.
+// FILE_LOGGING_MAX_SIZE is a number of megabytes. FILE_LOGGING_ROTATION_INTERVAL
+// and FILE_LOGGING_MAX_AGE are durations, e.g. 24h.
+func GetFileLoggerOptions() (FileLoggerOptions, error) {
+	options := FileLoggerOptions{}
+
+	if maxSize := os.Getenv(constants.FILE_LOGGING_MAX_SIZE); len(maxSize) > 0 {
+		megabytes, parseErr := strconv.ParseInt(maxSize, 10, 64)
+		if parseErr != nil || megabytes < 0 {
+			return options, NewLoggingError("FILE_LOGGING_MAX_SIZE must be a number of megabytes")
+		}
+
+		options.MaxSize = megabytes * 1024 * 1024
+	}
+
+	interval, intervalErr := parseLoggingDuration(constants.FILE_LOGGING_ROTATION_INTERVAL)
+	if intervalErr != nil {
+		return options, intervalErr
+	}
+	options.RotationInterval = interval
+
+	if compress := os.Getenv(constants.FILE_LOGGING_COMPRESS); len(compress) > 0 {
+		parsed, parseErr := strconv.ParseBool(compress)
+		if parseErr != nil {
+			return options, NewLoggingError("FILE_LOGGING_COMPRESS must be true or false")
+		}
+
+		options.Compress = parsed
+	}
+
+	if maxFiles := os.Getenv(constants.FILE_LOGGING_MAX_FILES); len(maxFiles) > 0 {
+		parsed, parseErr := strconv.Atoi(maxFiles)
+		if parseErr != nil || parsed < 0 {
+			return options, NewLoggingError("FILE_LOGGING_MAX_FILES must be a number of files")
+		}
+
+		options.MaxFiles = parsed
+	}
+
+	maxAge, maxAgeErr := parseLoggingDuration(constants.FILE_LOGGING_MAX_AGE)
+	if maxAgeErr != nil {
+		return options, maxAgeErr
+	}
+	options.MaxAge = maxAge
+
+	return options, nil
+}
+
+func parseLoggingDuration(envName string) (time.Duration, error) {
+	str := os.Getenv(envName)
+	if len(str) == 0 {
+		return 0, nil
+	}
+
+	duration, parseErr := time.ParseDuration(str)
+	if parseErr != nil || duration < 0 {
+		return 0, NewLoggingError(envName + " must be a duration, e.g. 24h")
+	}
+
+	return duration, nil
+}
+
+/****************************************************************************************
+* FileLogger
+****************************************************************************************/
+
+// The time format used in the names of rotated files. It sorts in time order.
+const rotatedFileTimeFormat = "20060102T150405.000"
+
+// FileLogger writes logs to a file as JSON lines. The file is rotated according
+// to its FileLoggerOptions. A rotated file is renamed to include the time it was
+// rotated, e.g. logs-20210601T120000.000.log, and is compressed and cleaned up
+// in the background.
+type FileLogger struct {
+	FilePath   string
+	FileName   string
+	FileHandle *os.File
+	Options    FileLoggerOptions
+
+	handler  slog.Handler
+	mutex    sync.Mutex
+	size     int64
+	openedAt time.Time
+
+	// Compressing and removing rotated files is done one rotation at a time
+	maintenanceMutex sync.Mutex
+	maintenance      sync.WaitGroup
+}
+
+// fileLogWriter is the io.Writer passed to the FileLogger's slog.Handler. The
+// FileLogger's mutex is held while the handler writes.
+type fileLogWriter struct{ fl *FileLogger }
+
+func (w fileLogWriter) Write(p []byte) (int, error) {
+	n, err := w.fl.FileHandle.Write(p)
+	w.fl.size += int64(n)
+
+	return n, err
+}
+
+func (fl *FileLogger) AddRequestLog(log *RequestLogData) error {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+
+	if rotateErr := fl.prepareWrite(); rotateErr != nil {
+		return rotateErr
+	}
+
+	return WriteRequestLog(fl.handler, log)
+}
+
+func (fl *FileLogger) AddInfoLog(log *InfoLogData) error {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+
+	if rotateErr := fl.prepareWrite(); rotateErr != nil {
+		return rotateErr
+	}
+
+	return WriteInfoLog(fl.handler, log)
+}
+
+// prepareWrite checks that the file is open and rotates it if it's due
+func (fl *FileLogger) prepareWrite() error {
+	if fl.FileHandle == nil {
+		return NewLoggingError("fileHandle is nil (no file handle exists)")
+	}
+
+	sizeExceeded := fl.Options.MaxSize > 0 && fl.size >= fl.Options.MaxSize
+	intervalPassed := fl.Options.RotationInterval > 0 && time.Since(fl.openedAt) >= fl.Options.RotationInterval
+
+	if sizeExceeded || intervalPassed {
+		return fl.rotate()
+	}
+
+	return nil
+}
+
+// rotate renames the current log file and opens a new one
+func (fl *FileLogger) rotate() error {
+	if closeErr := fl.FileHandle.Close(); closeErr != nil {
+		return closeErr
+	}
+	fl.FileHandle = nil
+
+	extension := filepath.Ext(fl.FileName)
+	rotatedName := strings.TrimSuffix(fl.FileName, extension) + "-" + time.Now().UTC().Format(rotatedFileTimeFormat) + extension
+	rotatedPath := filepath.Join(fl.FilePath, rotatedName)
+
+	renameErr := os.Rename(filepath.Join(fl.FilePath, fl.FileName), rotatedPath)
+
+	// A new file is opened even if the rename failed, so that logging continues
+	if openErr := fl.open(); openErr != nil {
+		return openErr
+	}
+
+	if renameErr != nil {
+		return renameErr
+	}
+
+	fl.maintenance.Add(1)
+	go func() {
+		defer fl.maintenance.Done()
+
+		fl.maintenanceMutex.Lock()
+		defer fl.maintenanceMutex.Unlock()
+
+		if fl.Options.Compress {
+			if compressErr := compressFile(rotatedPath); compressErr != nil {
+				ReportLoggerError(compressErr)
+			}
+		}
+
+		if removeErr := fl.removeOldFiles(); removeErr != nil {
+			ReportLoggerError(removeErr)
+		}
+	}()
+
+	return nil
+}
+
+// Reopen closes and reopens the log file. Send the service a SIGHUP after an
+// external tool like logrotate has moved the file.
+func (fl *FileLogger) Reopen() error {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+
+	if fl.FileHandle != nil {
+		if closeErr := fl.FileHandle.Close(); closeErr != nil {
+			return closeErr
+		}
+		fl.FileHandle = nil
+	}
+
+	return fl.open()
+}
+
+// CheckHealth returns an error if the log file isn't open
+func (fl *FileLogger) CheckHealth() error {
+	fl.mutex.Lock()
+	defer fl.mutex.Unlock()
+
+	if fl.FileHandle == nil {
+		return NewLoggingError("log file is not open")
+	}
+
+	return nil
+}
+
+// Close closes the log file and waits for rotated files to be compressed and
+// cleaned up. Logs written after Close return an error.
+func (fl *FileLogger) Close() error {
+	fl.mutex.Lock()
+
+	var closeErr error
+	if fl.FileHandle != nil {
+		closeErr = fl.FileHandle.Close()
+		fl.FileHandle = nil
+	}
+
+	fl.mutex.Unlock()
+
+	fl.maintenance.Wait()
+
+	return closeErr
+}
+
+func (fl *FileLogger) open() error {
+	handle, handleErr := os.OpenFile(filepath.Join(fl.FilePath, fl.FileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if handleErr != nil {
+		return handleErr
+	}
+
+	info, statErr := handle.Stat()
+	if statErr != nil {
+		handle.Close()
+		return statErr
+	}
+
+	fl.FileHandle = handle
+	fl.size = info.Size()
+	fl.openedAt = time.Now()
+
+	return nil
+}
+
+// RotatedFiles returns the paths of the rotated log files, newest first
+func (fl *FileLogger) RotatedFiles() ([]string, error) {
+	extension := filepath.Ext(fl.FileName)
+	pattern := filepath.Join(fl.FilePath, strings.TrimSuffix(fl.FileName, extension)+"-*"+extension)
+
+	files, globErr := filepath.Glob(pattern)
+	if globErr != nil {
+		return nil, globErr
+	}
+
+	compressed, globErr := filepath.Glob(pattern + ".gz")
+	if globErr != nil {
+		return nil, globErr
+	}
+
+	files = append(files, compressed...)
+
+	// The names start with the rotation time, so they sort in time order
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	return files, nil
+}
+
+// removeOldFiles removes the rotated files beyond MaxFiles and older than MaxAge
+func (fl *FileLogger) removeOldFiles() error {
+	if fl.Options.MaxFiles <= 0 && fl.Options.MaxAge <= 0 {
+		return nil
+	}
+
+	files, filesErr := fl.RotatedFiles()
+	if filesErr != nil {
+		return filesErr
+	}
+
+	for i, file := range files {
+		remove := fl.Options.MaxFiles > 0 && i >= fl.Options.MaxFiles
+
+		if !remove && fl.Options.MaxAge > 0 {
+			info, statErr := os.Stat(file)
+			remove = statErr == nil && time.Since(info.ModTime()) > fl.Options.MaxAge
+		}
+
+		if !remove {
+			continue
+		}
+
+		if removeErr := os.Remove(file); removeErr != nil {
+			return removeErr
+		}
+	}
+
+	return nil
+}
+
+// compressFile writes a gzip compressed copy of the file to path.gz and removes
+// the original
+func compressFile(path string) error {
+	source, openErr := os.Open(path)
+	if openErr != nil {
+		return openErr
+	}
+	defer source.Close()
+
+	destination, createErr := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if createErr != nil {
+		return createErr
+	}
+
+	writer := gzip.NewWriter(destination)
+
+	_, copyErr := io.Copy(writer, source)
+	if copyErr == nil {
+		copyErr = writer.Close()
+	}
+
+	if closeErr := destination.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+
+	if copyErr != nil {
+		os.Remove(path + ".gz")
+		return copyErr
+	}
+
+	source.Close()
+
+	return os.Remove(path)
+}
+
+// MakeNewFileLogger opens the log file at path/name, creating the path if it
+// doesn't exist. The FileLogger is returned even if the file couldn't be opened.
+// Its FileHandle is nil in that case.
+func MakeNewFileLogger(path string, name string, options FileLoggerOptions) (*FileLogger, error) {
+	fl := FileLogger{
+		FileName: name,
+		FilePath: path,
+		Options:  options,
+	}
+
+	fl.handler = NewJSONLogHandler(fileLogWriter{&fl})
+
+	var pathErr error
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		pathErr = os.MkdirAll(path, 0764)
+	}
+
+	// We return the FileLogger with FileHandle set to nil
+	if pathErr != nil {
+		return &fl, pathErr
+	}
+
+	if openErr := fl.open(); openErr != nil {
+		return &fl, openErr
+	}
+
+	return &fl, nil
+}
@@ -0,0 +1,201 @@
+package authUtils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+/****************************************************************************************
+* HTTPLoggerOptions
+****************************************************************************************/
+
+const defaultHTTPLoggerMaxRetries = 3
+const defaultHTTPLoggerInitialBackoff = 500 * time.Millisecond
+const defaultHTTPLoggerMaxBackoff = 30 * time.Second
+const defaultHTTPLoggerTimeout = 10 * time.Second
+
+// HTTPLoggerOptions configures an HTTPLogger. Zero durations use the defaults.
+type HTTPLoggerOptions struct {
+	// The URL logs are posted to
+	URL string
+	// Sent in the Authorization header, if set
+	Authorization string
+	// The number of times a failed request is retried
+	MaxRetries int
+	// The wait before the first retry, which doubles for every retry after it.
+	// Defaults to 500ms.
+	InitialBackoff time.Duration
+	// The longest wait between retries. Defaults to 30s.
+	MaxBackoff time.Duration
+	// The timeout of each request. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// GetHTTPLoggerOptions reads the HTTPLoggerOptions from the environment.
+// HTTP_LOGGING_URL is required. HTTP_LOGGING_MAX_RETRIES defaults to 3.
+func GetHTTPLoggerOptions() (HTTPLoggerOptions, error) {
+	options := HTTPLoggerOptions{
+		URL:           os.Getenv(constants.HTTP_LOGGING_URL),
+		Authorization: os.Getenv(constants.HTTP_LOGGING_AUTHORIZATION),
+		MaxRetries:    defaultHTTPLoggerMaxRetries,
+	}
+
+	parsed, parseErr := url.Parse(options.URL)
+	if parseErr != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return options, NewLoggingError("HTTP_LOGGING_URL must be an http or https URL")
+	}
+
+	if maxRetries := os.Getenv(constants.HTTP_LOGGING_MAX_RETRIES); len(maxRetries) > 0 {
+		parsedRetries, retriesErr := strconv.Atoi(maxRetries)
+		if retriesErr != nil || parsedRetries < 0 {
+			return options, NewLoggingError("HTTP_LOGGING_MAX_RETRIES must be a number of retries")
+		}
+
+		options.MaxRetries = parsedRetries
+	}
+
+	return options, nil
+}
+
+func (options HTTPLoggerOptions) withDefaults() HTTPLoggerOptions {
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = defaultHTTPLoggerInitialBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultHTTPLoggerMaxBackoff
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultHTTPLoggerTimeout
+	}
+
+	return options
+}
+
+/****************************************************************************************
+* HTTPLogger
+****************************************************************************************/
+
+// HTTPLogger posts logs to a URL as JSON lines, the same lines written by the
+// FileLogger. It's a BatchLogger, so an AsyncLogger posts queued logs together.
+// Requests that fail with a network error, a 429 or a 5xx status are retried with
+// exponential backoff. Other statuses aren't retried. Close cancels the request
+// and the retries in progress.
+type HTTPLogger struct {
+	Options HTTPLoggerOptions
+
+	client *http.Client
+	// Done once the logger is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func MakeNewHTTPLogger(options HTTPLoggerOptions) *HTTPLogger {
+	options = options.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &HTTPLogger{
+		Options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Close cancels the request and retries in progress. Logs added afterward
+// return an error.
+func (hl *HTTPLogger) Close() error {
+	hl.cancel()
+	return nil
+}
+
+func (hl *HTTPLogger) AddRequestLog(log *RequestLogData) error {
+	return hl.AddLogs([]LogEntry{{Request: log}})
+}
+
+func (hl *HTTPLogger) AddInfoLog(log *InfoLogData) error {
+	return hl.AddLogs([]LogEntry{{Info: log}})
+}
+
+// AddLogs posts the logs in a single request
+func (hl *HTTPLogger) AddLogs(entries []LogEntry) error {
+	var body bytes.Buffer
+
+	for _, entry := range entries {
+		line, _, lineErr := MarshalLogEntry(entry)
+		if lineErr != nil {
+			return lineErr
+		}
+
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	backoff := hl.Options.InitialBackoff
+
+	for attempt := 0; ; attempt++ {
+		retry, postErr := hl.post(body.Bytes())
+		if postErr == nil {
+			return nil
+		}
+
+		if !retry || attempt >= hl.Options.MaxRetries {
+			return postErr
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-timer.C:
+		case <-hl.ctx.Done():
+			timer.Stop()
+			return NewLoggingError("logger closed while retrying: " + postErr.Error())
+		}
+
+		backoff *= 2
+		if backoff > hl.Options.MaxBackoff {
+			backoff = hl.Options.MaxBackoff
+		}
+	}
+}
+
+// post sends the body once. It returns whether a failed request can be retried.
+func (hl *HTTPLogger) post(body []byte) (bool, error) {
+	req, reqErr := http.NewRequestWithContext(hl.ctx, http.MethodPost, hl.Options.URL, bytes.NewReader(body))
+	if reqErr != nil {
+		return false, reqErr
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if len(hl.Options.Authorization) > 0 {
+		req.Header.Set("Authorization", hl.Options.Authorization)
+	}
+
+	res, resErr := hl.client.Do(req)
+	if resErr != nil {
+		return hl.ctx.Err() == nil, resErr
+	}
+
+	// The body is read so that the connection can be reused
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+
+	return retry, NewLoggingError(fmt.Sprintf("log server responded with %d", res.StatusCode))
+}
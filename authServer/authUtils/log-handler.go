@@ -0,0 +1,245 @@
+package authUtils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+/****************************************************************************************
+* Log Levels
+****************************************************************************************/
+
+// ParseLogLevel parses a level name: debug, info, warn or error. An empty
+// string is the info level.
+func ParseLogLevel(str string) (slog.Level, error) {
+	if len(str) == 0 {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if unmarshalErr := level.UnmarshalText([]byte(str)); unmarshalErr != nil {
+		return slog.LevelInfo, NewLoggingError("invalid log level: " + str)
+	}
+
+	return level, nil
+}
+
+// GetLogLevel returns the level set in LOG_LEVEL. Messages below it aren't
+// logged.
+func GetLogLevel() slog.Level {
+	level, _ := ParseLogLevel(os.Getenv(constants.LOG_LEVEL))
+
+	return level
+}
+
+/****************************************************************************************
+* LogHandler
+****************************************************************************************/
+
+// LogHandler is a slog.Handler that writes records to AuthLoggers as
+// InfoLogData. A string "type" attribute sets the InfoLogData's Type, which is
+// "log" otherwise. The other attributes are kept in Attributes.
+type LogHandler struct {
+	loggers []*AuthLogger
+	level   slog.Leveler
+	attrs   []slog.Attr
+	groups  []string
+}
+
+func NewLogHandler(loggers []*AuthLogger, level slog.Leveler) *LogHandler {
+	return &LogHandler{loggers: loggers, level: level}
+}
+
+func (lh *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= lh.level.Level()
+}
+
+// Handle writes the record to every logger. A logger that fails can't be used
+// to report its own failure, so failures are written to stderr.
+func (lh *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	log := InfoLogData{
+		Timestamp:  record.Time,
+		Level:      record.Level.String(),
+		Type:       "log",
+		Message:    record.Message,
+		Attributes: make(map[string]interface{}),
+	}
+
+	addAttr := func(attr slog.Attr) {
+		if attr.Key == "type" && attr.Value.Kind() == slog.KindString {
+			log.Type = attr.Value.String()
+			return
+		}
+
+		log.Attributes[attr.Key] = attrValue(attr.Value)
+	}
+
+	for _, attr := range lh.attrs {
+		addAttr(attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addAttr(lh.groupAttr(attr))
+		return true
+	})
+
+	if len(log.Attributes) == 0 {
+		log.Attributes = nil
+	}
+
+	for _, logger := range lh.loggers {
+		if logErr := (*logger).AddInfoLog(&log); logErr != nil {
+			ReportLoggerError(logErr)
+		}
+	}
+
+	return nil
+}
+
+func (lh *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *lh
+	handler.attrs = append([]slog.Attr{}, lh.attrs...)
+
+	for _, attr := range attrs {
+		handler.attrs = append(handler.attrs, lh.groupAttr(attr))
+	}
+
+	return &handler
+}
+
+func (lh *LogHandler) WithGroup(name string) slog.Handler {
+	handler := *lh
+	handler.groups = append(append([]string{}, lh.groups...), name)
+
+	return &handler
+}
+
+// groupAttr prefixes an attribute's key with the handler's groups
+func (lh *LogHandler) groupAttr(attr slog.Attr) slog.Attr {
+	if len(lh.groups) == 0 {
+		return attr
+	}
+
+	return slog.Attr{Key: strings.Join(lh.groups, ".") + "." + attr.Key, Value: attr.Value}
+}
+
+// attrValue converts a slog.Value to a plain value. Groups become maps and
+// errors become their messages.
+func attrValue(value slog.Value) interface{} {
+	value = value.Resolve()
+
+	if err, ok := value.Any().(error); ok {
+		return err.Error()
+	}
+
+	if value.Kind() != slog.KindGroup {
+		return value.Any()
+	}
+
+	group := make(map[string]interface{})
+	for _, attr := range value.Group() {
+		group[attr.Key] = attrValue(attr.Value)
+	}
+
+	return group
+}
+
+// ReportLoggerError writes an error returned by an AuthLogger to stderr
+func ReportLoggerError(err error) {
+	fmt.Fprintf(os.Stderr, "error writing log: %s\n", err.Error())
+}
+
+/****************************************************************************************
+* JSON Output
+****************************************************************************************/
+
+// NewJSONLogHandler returns a slog JSON handler that writes every level to w.
+// Levels are filtered before records reach the AuthLoggers.
+func NewJSONLogHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+}
+
+// WriteInfoLog writes an InfoLogData with a slog.Handler, keeping its timestamp
+// and level. Attributes are written in alphabetical order.
+func WriteInfoLog(handler slog.Handler, log *InfoLogData) error {
+	level, _ := ParseLogLevel(log.Level)
+
+	record := slog.NewRecord(log.Timestamp, level, log.Message, 0)
+	record.AddAttrs(slog.String("type", log.Type))
+
+	keys := make([]string, 0, len(log.Attributes))
+	for key := range log.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		record.AddAttrs(slog.Any(key, log.Attributes[key]))
+	}
+
+	return handler.Handle(context.Background(), record)
+}
+
+// WriteRequestLog writes a RequestLogData with a slog.Handler. Server errors are
+// logged at the error level and client errors at the warn level.
+func WriteRequestLog(handler slog.Handler, log *RequestLogData) error {
+	record := slog.NewRecord(log.Timestamp, requestLogLevel(log), "request", 0)
+	record.AddAttrs(
+		slog.String("type", log.Type),
+		slog.String("requestId", log.RequestId),
+		slog.String("userId", log.UserId),
+		slog.String("clientIP", log.ClientIP),
+		slog.String("method", log.Method),
+		slog.String("path", log.Path),
+		slog.String("protocol", log.Protocol),
+		slog.Int("statusCode", log.StatusCode),
+		slog.Duration("latency", log.Latency),
+		slog.String("userAgent", log.UserAgent),
+		slog.String("errorMessage", log.ErrorMessage),
+	)
+
+	return handler.Handle(context.Background(), record)
+}
+
+func requestLogLevel(log *RequestLogData) slog.Level {
+	if log.StatusCode >= 500 {
+		return slog.LevelError
+	} else if log.StatusCode >= 400 {
+		return slog.LevelWarn
+	}
+
+	return slog.LevelInfo
+}
+
+// MarshalLogEntry returns the JSON line written for a queued log, without the
+// trailing newline, and the log's level
+func MarshalLogEntry(entry LogEntry) ([]byte, slog.Level, error) {
+	var buffer bytes.Buffer
+	handler := NewJSONLogHandler(&buffer)
+
+	var level slog.Level
+	var writeErr error
+
+	if entry.Request != nil {
+		level = requestLogLevel(entry.Request)
+		writeErr = WriteRequestLog(handler, entry.Request)
+	} else if entry.Info != nil {
+		level, _ = ParseLogLevel(entry.Info.Level)
+		writeErr = WriteInfoLog(handler, entry.Info)
+	} else {
+		writeErr = NewLoggingError("log entry is empty")
+	}
+
+	if writeErr != nil {
+		return nil, level, writeErr
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), level, nil
+}
@@ -1,9 +1,9 @@
 package authUtils
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"time"
 )
 
@@ -25,9 +25,13 @@ type LogData interface {
 /****************************************************************************************
 * RequestLogData
 ****************************************************************************************/
+// RequestLogData describes an HTTP request. RequestId is the request's
+// X-Request-Id and UserId is the subject of the request's token, if it had one.
 type RequestLogData struct {
 	Timestamp    time.Time     `bson:"timestamp"`
 	Type         string        `bson:"type"`
+	RequestId    string        `bson:"requestId"`
+	UserId       string        `bson:"userId"`
 	ClientIP     string        `bson:"clientIP"`
 	Method       string        `bson:"method"`
 	Path         string        `bson:"path"`
@@ -57,15 +61,20 @@ func (rld RequestLogData) PrettyString() string {
 /****************************************************************************************
 * InfoLogData
 ****************************************************************************************/
+// InfoLogData is a leveled log message. Level is the name of a slog.Level, e.g.
+// INFO or ERROR. Attributes holds the structured data logged with the message.
 type InfoLogData struct {
-	Timestamp time.Time `bson:"timestamp"`
-	Type      string    `bson:"type"`
-	Message   string    `bson:"message"`
+	Timestamp  time.Time              `bson:"timestamp"`
+	Level      string                 `bson:"level"`
+	Type       string                 `bson:"type"`
+	Message    string                 `bson:"message"`
+	Attributes map[string]interface{} `bson:"attributes,omitempty"`
 }
 
 func (ild InfoLogData) PrettyString() string {
-	msg := fmt.Sprintf("%s - [%s] \"%s\"",
+	msg := fmt.Sprintf("%s - %s [%s] \"%s\"",
 		ild.Timestamp.Format(time.RFC1123),
+		ild.Level,
 		ild.Type,
 		ild.Message,
 	)
@@ -74,85 +83,117 @@ func (ild InfoLogData) PrettyString() string {
 }
 
 /****************************************************************************************
-* AuthLogger
-****************************************************************************************/
-type AuthLogger interface {
-	AddRequestLog(log *RequestLogData) error
-	AddInfoLog(log *InfoLogData) error
-}
-
-/****************************************************************************************
-* FileLogger
+* AuditEvent
 ****************************************************************************************/
-type FileLogger struct {
-	FilePath   string
-	FileName   string
-	FileHandle *os.File
-}
-
-func (fl *FileLogger) AddRequestLog(log *RequestLogData) error {
-	err := fl.WriteLog(log)
-	return err
-}
 
-func (fl *FileLogger) AddInfoLog(log *InfoLogData) error {
-	err := fl.WriteLog(log)
-	return err
-}
+// Audit actions
+const (
+	AuditLogin         = "login"
+	AuditAddUser       = "add_user"
+	AuditEditUser      = "edit_user"
+	AuditEditPassword  = "edit_password"
+	AuditImpersonate   = "impersonate"
+	AuditDeleteUser    = "delete_user"
+	AuditRevokeSession = "revoke_session"
+)
 
-func (fl *FileLogger) WriteLog(log LogData) error {
-	if fl.FileHandle == nil {
-		return NewLoggingError("fileHandle is nil (no file handle exists)")
-	}
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
 
-	_, err := fl.FileHandle.WriteString(log.PrettyString() + "\n")
+// Log types of audit events written to the loggers. Unstored events couldn't be
+// stored in the database and aren't part of the audit chain.
+const (
+	AuditLogType         = "audit"
+	AuditUnstoredLogType = "audit_unstored"
+)
 
-	return err
+// AuditEvent records a security relevant action. The actor is the user that
+// performed the action and the target is the user it was performed on. Failed
+// logins have no actor. Reason explains why an action failed.
+//
+// Audit events form a hash chain. Sequence numbers the events from 1, Hash is
+// the hash of the event and PreviousHash is the hash of the event before it.
+// Periodically, Checkpoint holds a signature of Hash made with the service's
+// private key.
+type AuditEvent struct {
+	Sequence       int64     `bson:"sequence" json:"sequence"`
+	Timestamp      time.Time `bson:"timestamp" json:"timestamp"`
+	Action         string    `bson:"action" json:"action"`
+	Outcome        string    `bson:"outcome" json:"outcome"`
+	ActorId        string    `bson:"actorId" json:"actorId,omitempty"`
+	ActorUsername  string    `bson:"actorUsername" json:"actorUsername,omitempty"`
+	TargetId       string    `bson:"targetId" json:"targetId,omitempty"`
+	TargetUsername string    `bson:"targetUsername" json:"targetUsername,omitempty"`
+	ClientIP       string    `bson:"clientIP" json:"clientIP"`
+	UserAgent      string    `bson:"userAgent" json:"userAgent"`
+	Reason         string    `bson:"reason" json:"reason,omitempty"`
+	PreviousHash   string    `bson:"previousHash" json:"previousHash"`
+	Hash           string    `bson:"hash" json:"hash"`
+	Checkpoint     string    `bson:"checkpoint,omitempty" json:"checkpoint,omitempty"`
 }
 
-func MakeNewFileLogger(path string, name string) (*FileLogger, error) {
-	fl := FileLogger{
-		FileName: name,
-		FilePath: path,
-	}
+// ComputeHash returns the sha3-512 hash of the event's JSON encoding, without
+// its Hash and Checkpoint. The timestamp is hashed in UTC, so that the hash
+// doesn't depend on the time zone the event was decoded in.
+func (ae AuditEvent) ComputeHash() string {
+	ae.Hash = ""
+	ae.Checkpoint = ""
+	ae.Timestamp = ae.Timestamp.UTC()
 
-	fullPath := filepath.Join(path, name)
+	// Marshalling a struct of strings, numbers and a time can't fail
+	eventBytes, _ := json.Marshal(ae)
 
-	var pathErr error
+	return HashBytes(eventBytes)
+}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		pathErr = os.MkdirAll(path, 0764)
-	}
+// Message returns the event's JSON encoding
+func (ae AuditEvent) Message() string {
+	eventBytes, _ := json.Marshal(ae)
 
-	// We return the FileLogger with FileHandle set to nil
-	if pathErr != nil {
-		// do something
-		return &fl, pathErr
-	}
+	return string(eventBytes)
+}
 
-	handle, handleErr := os.OpenFile(fullPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func (ae AuditEvent) PrettyString() string {
+	return fmt.Sprintf("%s - [audit] \"%s\"", ae.Timestamp.Format(time.RFC1123), ae.Message())
+}
 
-	if handleErr != nil {
-		return &fl, handleErr
-	}
+/****************************************************************************************
+* AuthLogger
+****************************************************************************************/
+type AuthLogger interface {
+	AddRequestLog(log *RequestLogData) error
+	AddInfoLog(log *InfoLogData) error
+}
 
-	fl.FileHandle = handle
+// ReopenableLogger is an AuthLogger that writes to a file, which can be reopened
+// after it has been moved by an external tool
+type ReopenableLogger interface {
+	Reopen() error
+}
 
-	return &fl, nil
+// HealthCheckedLogger is an AuthLogger that can tell whether it's able to write
+// logs. CheckHealth returns the reason it can't.
+type HealthCheckedLogger interface {
+	CheckHealth() error
 }
 
 /****************************************************************************************
 * ConsoleLogger
 ****************************************************************************************/
+
+// ConsoleLogger writes logs to stdout as JSON lines
 type ConsoleLogger struct {
 }
 
+var consoleHandler = NewJSONLogHandler(os.Stdout)
+
 func (cl *ConsoleLogger) AddRequestLog(log *RequestLogData) error {
-	fmt.Println(log.PrettyString())
-	return nil
+	return WriteRequestLog(consoleHandler, log)
 }
 
 func (cl *ConsoleLogger) AddInfoLog(log *InfoLogData) error {
-	fmt.Println(log.PrettyString())
-	return nil
+	return WriteInfoLog(consoleHandler, log)
 }
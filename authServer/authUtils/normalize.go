@@ -0,0 +1,32 @@
+package authUtils
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeIdentifier converts a user supplied identifier into the canonical form
+// we use for storage and lookup. The value is trimmed, converted to Unicode NFKC
+// and case folded, so that values such as "Alice", " alice" and "ＡＬＩＣＥ" all
+// resolve to the same account.
+func NormalizeIdentifier(str string) string {
+	trimmed := strings.TrimSpace(str)
+	composed := norm.NFKC.String(trimmed)
+	folded := cases.Fold().String(composed)
+
+	// Case folding can produce a string that is no longer in NFKC, so we
+	// compose it one last time.
+	return norm.NFKC.String(folded)
+}
+
+// NormalizeUsername returns the canonical form of a username
+func NormalizeUsername(username string) string {
+	return NormalizeIdentifier(username)
+}
+
+// NormalizeEmail returns the canonical form of an email address
+func NormalizeEmail(email string) string {
+	return NormalizeIdentifier(email)
+}
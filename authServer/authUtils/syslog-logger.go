@@ -0,0 +1,318 @@
+package authUtils
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+/****************************************************************************************
+* SyslogOptions
+****************************************************************************************/
+
+const defaultSyslogAppName = "auth-microservice"
+const syslogDialTimeout = 5 * time.Second
+const syslogWriteTimeout = 5 * time.Second
+
+// The facilities that can be set in SYSLOG_FACILITY
+var syslogFacilities = map[string]int{
+	"user":     1,
+	"daemon":   3,
+	"auth":     4,
+	"authpriv": 10,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// SyslogOptions configures a SyslogLogger
+type SyslogOptions struct {
+	// udp, tcp or unix
+	Network string
+	// host:port for udp and tcp, or the socket's path for unix
+	Address string
+	// The facility code, e.g. 16 for local0
+	Facility int
+	// The APP-NAME field of every message
+	AppName string
+}
+
+// ParseSyslogAddress parses an address like udp://localhost:514,
+// tcp://logs.example.com:601 or unix:///dev/log
+func ParseSyslogAddress(address string) (network string, addr string, err error) {
+	parsed, parseErr := url.Parse(address)
+	if parseErr != nil {
+		return "", "", NewLoggingError("invalid syslog address: " + address)
+	}
+
+	switch parsed.Scheme {
+	case "udp", "tcp":
+		if len(parsed.Host) == 0 {
+			return "", "", NewLoggingError("syslog address has no host: " + address)
+		}
+		return parsed.Scheme, parsed.Host, nil
+	case "unix":
+		if len(parsed.Path) == 0 {
+			return "", "", NewLoggingError("syslog address has no path: " + address)
+		}
+		return parsed.Scheme, parsed.Path, nil
+	}
+
+	return "", "", NewLoggingError("syslog address must start with udp://, tcp:// or unix://")
+}
+
+// GetSyslogOptions reads the SyslogOptions from the environment. SYSLOG_ADDRESS
+// is required. SYSLOG_FACILITY defaults to local0 and SYSLOG_APP_NAME to
+// auth-microservice.
+func GetSyslogOptions() (SyslogOptions, error) {
+	options := SyslogOptions{
+		Facility: syslogFacilities["local0"],
+		AppName:  defaultSyslogAppName,
+	}
+
+	network, address, addressErr := ParseSyslogAddress(os.Getenv(constants.SYSLOG_ADDRESS))
+	if addressErr != nil {
+		return options, addressErr
+	}
+
+	options.Network = network
+	options.Address = address
+
+	if facilityName := os.Getenv(constants.SYSLOG_FACILITY); len(facilityName) > 0 {
+		facility, ok := syslogFacilities[strings.ToLower(facilityName)]
+		if !ok {
+			return options, NewLoggingError("invalid syslog facility: " + facilityName)
+		}
+
+		options.Facility = facility
+	}
+
+	if appName := os.Getenv(constants.SYSLOG_APP_NAME); len(appName) > 0 {
+		// APP-NAME is at most 48 printable characters without spaces
+		if len(appName) > 48 || strings.ContainsAny(appName, " \t\r\n") {
+			return options, NewLoggingError("invalid syslog app name: " + appName)
+		}
+
+		options.AppName = appName
+	}
+
+	return options, nil
+}
+
+/****************************************************************************************
+* SyslogLogger
+****************************************************************************************/
+
+// SyslogLogger sends logs to a syslog server as RFC 5424 messages. The message
+// is the JSON line written by the FileLogger and the MSGID is the log's type.
+// Messages sent over TCP or a stream socket are framed by octet counting, as
+// described in RFC 6587. The connection is opened again if it fails.
+type SyslogLogger struct {
+	Options SyslogOptions
+
+	mutex    sync.Mutex
+	conn     net.Conn
+	stream   bool
+	hostname string
+	pid      int
+}
+
+// MakeNewSyslogLogger connects to the syslog server. The SyslogLogger is
+// returned even if the connection fails, and connecting is attempted again for
+// the next log.
+func MakeNewSyslogLogger(options SyslogOptions) (*SyslogLogger, error) {
+	hostname, hostnameErr := os.Hostname()
+	if hostnameErr != nil || len(hostname) == 0 {
+		hostname = "-"
+	}
+
+	sl := &SyslogLogger{
+		Options:  options,
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}
+
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	return sl, sl.connect()
+}
+
+func (sl *SyslogLogger) AddRequestLog(log *RequestLogData) error {
+	return sl.send(LogEntry{Request: log})
+}
+
+func (sl *SyslogLogger) AddInfoLog(log *InfoLogData) error {
+	return sl.send(LogEntry{Info: log})
+}
+
+// CheckHealth returns an error if the syslog server can't be connected to. A
+// closed connection is opened again.
+func (sl *SyslogLogger) CheckHealth() error {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	if sl.conn != nil {
+		return nil
+	}
+
+	return sl.connect()
+}
+
+// Close closes the connection to the syslog server
+func (sl *SyslogLogger) Close() error {
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	if sl.conn == nil {
+		return nil
+	}
+
+	closeErr := sl.conn.Close()
+	sl.conn = nil
+
+	return closeErr
+}
+
+func (sl *SyslogLogger) connect() error {
+	if sl.Options.Network != "unix" {
+		conn, dialErr := net.DialTimeout(sl.Options.Network, sl.Options.Address, syslogDialTimeout)
+		if dialErr != nil {
+			return dialErr
+		}
+
+		sl.conn = conn
+		sl.stream = sl.Options.Network == "tcp"
+
+		return nil
+	}
+
+	// Local syslog sockets like /dev/log are usually datagram sockets
+	conn, dialErr := net.DialTimeout("unixgram", sl.Options.Address, syslogDialTimeout)
+	if dialErr == nil {
+		sl.conn = conn
+		sl.stream = false
+
+		return nil
+	}
+
+	conn, dialErr = net.DialTimeout("unix", sl.Options.Address, syslogDialTimeout)
+	if dialErr != nil {
+		return dialErr
+	}
+
+	sl.conn = conn
+	sl.stream = true
+
+	return nil
+}
+
+func (sl *SyslogLogger) send(entry LogEntry) error {
+	message, messageErr := sl.FormatMessage(entry)
+	if messageErr != nil {
+		return messageErr
+	}
+
+	sl.mutex.Lock()
+	defer sl.mutex.Unlock()
+
+	// A broken connection is usually only noticed when writing to it, so a
+	// failed write is attempted once more on a new connection
+	var writeErr error
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if sl.conn == nil {
+			if connectErr := sl.connect(); connectErr != nil {
+				return connectErr
+			}
+		}
+
+		if writeErr = sl.write(message); writeErr == nil {
+			return nil
+		}
+
+		sl.conn.Close()
+		sl.conn = nil
+	}
+
+	return writeErr
+}
+
+func (sl *SyslogLogger) write(message []byte) error {
+	if sl.stream {
+		message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+
+	// A TCP write blocks while the server isn't reading
+	if deadlineErr := sl.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout)); deadlineErr != nil {
+		return deadlineErr
+	}
+
+	_, writeErr := sl.conn.Write(message)
+
+	return writeErr
+}
+
+// FormatMessage returns the RFC 5424 message for a log, without framing
+func (sl *SyslogLogger) FormatMessage(entry LogEntry) ([]byte, error) {
+	line, level, lineErr := MarshalLogEntry(entry)
+	if lineErr != nil {
+		return nil, lineErr
+	}
+
+	var timestamp time.Time
+	var logType string
+
+	if entry.Request != nil {
+		timestamp, logType = entry.Request.Timestamp, entry.Request.Type
+	} else {
+		timestamp, logType = entry.Info.Timestamp, entry.Info.Type
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		sl.Options.Facility*8+syslogSeverity(level),
+		timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(sl.hostname),
+		sl.Options.AppName,
+		sl.pid,
+		syslogField(logType),
+	)
+
+	return append([]byte(header), line...), nil
+}
+
+// syslogSeverity converts a slog level to a syslog severity
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	}
+
+	return 7
+}
+
+// syslogField returns the value for a header field, which can't be empty or
+// contain spaces
+func syslogField(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+
+	return strings.ReplaceAll(value, " ", "_")
+}
@@ -13,18 +13,73 @@ const RSA_PUBLIC_KEY = "RSA_PUBLIC_KEY"
 
 const FILE_LOGGING = "FILE_LOGGING"
 const FILE_LOGGING_PATH = "FILE_LOGGING_PATH"
+const FILE_LOGGING_MAX_SIZE = "FILE_LOGGING_MAX_SIZE"
+const FILE_LOGGING_ROTATION_INTERVAL = "FILE_LOGGING_ROTATION_INTERVAL"
+const FILE_LOGGING_COMPRESS = "FILE_LOGGING_COMPRESS"
+const FILE_LOGGING_MAX_FILES = "FILE_LOGGING_MAX_FILES"
+const FILE_LOGGING_MAX_AGE = "FILE_LOGGING_MAX_AGE"
 const DB_LOGGING = "DB_LOGGING"
 const CONSOLE_LOGGING = "CONSOLE_LOGGING"
+const LOG_LEVEL = "LOG_LEVEL"
+const LOG_QUEUE_SIZE = "LOG_QUEUE_SIZE"
+const LOG_QUEUE_WORKERS = "LOG_QUEUE_WORKERS"
+const LOG_BATCH_SIZE = "LOG_BATCH_SIZE"
+const LOG_DROP_POLICY = "LOG_DROP_POLICY"
+const LOG_RETENTION = "LOG_RETENTION"
+
+const SYSLOG_LOGGING = "SYSLOG_LOGGING"
+const SYSLOG_ADDRESS = "SYSLOG_ADDRESS"
+const SYSLOG_FACILITY = "SYSLOG_FACILITY"
+const SYSLOG_APP_NAME = "SYSLOG_APP_NAME"
+
+const HTTP_LOGGING = "HTTP_LOGGING"
+const HTTP_LOGGING_URL = "HTTP_LOGGING_URL"
+const HTTP_LOGGING_AUTHORIZATION = "HTTP_LOGGING_AUTHORIZATION"
+const HTTP_LOGGING_MAX_RETRIES = "HTTP_LOGGING_MAX_RETRIES"
+
+const TRACING_EXPORTER = "TRACING_EXPORTER"
+const TRACING_FILE = "TRACING_FILE"
+const TRACING_OTLP_ENDPOINT = "TRACING_OTLP_ENDPOINT"
+const TRACING_SAMPLE_RATIO = "TRACING_SAMPLE_RATIO"
+const TRACING_SERVICE_NAME = "TRACING_SERVICE_NAME"
 
 const IGNORE_NONCE = "IGNORE_NONCE"
 
 const HASH_COST = "HASH_COST"
 
+const JWT_CUSTOM_CLAIMS = "JWT_CUSTOM_CLAIMS"
+const JWT_ISSUER = "JWT_ISSUER"
+const JWT_AUDIENCES = "JWT_AUDIENCES"
+const JWT_SCOPES = "JWT_SCOPES"
+const JWT_CLOCK_SKEW = "JWT_CLOCK_SKEW"
+
+const INTROSPECTION_CLIENTS = "INTROSPECTION_CLIENTS"
+
+const TOKEN_SOURCES = "TOKEN_SOURCES"
+const TOKEN_COOKIE_NAME = "TOKEN_COOKIE_NAME"
+const TOKEN_COOKIE_SECURE = "TOKEN_COOKIE_SECURE"
+const TOKEN_COOKIE_SAMESITE = "TOKEN_COOKIE_SAMESITE"
+
+const GRPC_PORT = "GRPC_PORT"
+
+const SHUTDOWN_TIMEOUT = "SHUTDOWN_TIMEOUT"
+
+const TLS_CERT_FILE = "TLS_CERT_FILE"
+const TLS_KEY_FILE = "TLS_KEY_FILE"
+const TLS_CLIENT_CA_FILE = "TLS_CLIENT_CA_FILE"
+const TLS_CLIENT_AUTH = "TLS_CLIENT_AUTH"
+const TLS_ADMIN_CLIENT_CERT = "TLS_ADMIN_CLIENT_CERT"
+const TLS_RELOAD_INTERVAL = "TLS_RELOAD_INTERVAL"
+
 const FIVE_MINUTES = time.Minute * 5
 const TEN_MINUTES = time.Minute * 10
+const FIFTEEN_MINUTES = time.Minute * 15
 
 const NONCE_EXPIRATION = -1 * FIVE_MINUTES
 
 const ONE_HOUR = time.Hour
 const FOUR_HOURS = time.Hour * 4
 const JWT_EXPIRATION = FOUR_HOURS
+const IMPERSONATION_JWT_EXPIRATION = FIFTEEN_MINUTES
+const JWT_DEFAULT_CLOCK_SKEW = time.Second * 30
+const DEFAULT_SHUTDOWN_TIMEOUT = time.Second * 30
@@ -4,17 +4,32 @@ import au "methompson.com/auth-microservice/authServer/authUtils"
 
 type DatabaseController interface {
 	InitDatabase() error
+	Ping() error
 
 	GetUserByUsername(username string) (FullUserDocument, error)
 	GetUserById(id string) (FullUserDocument, error)
 	AddUser(userDoc FullUserDocument) error
 	EditUser(userDoc EditUserDocument) error
 	EditUserPassword(userId string, passwordHash string) error
+	DeleteUser(userId string) error
 
 	GetNonce(hashedNonce string, remoteAddress string, exp int64) (NonceDocument, error)
 	AddNonce(hashedNonce string, remoteAddress string, time int64) error
 	RemoveOldNonces(exp int64) error
+	CountNonces() (int64, error)
+
+	AddSession(session SessionDocument) error
+	GetSession(sessionId string) (SessionDocument, error)
+	GetUserSessions(userId string) ([]SessionDocument, error)
+	UpdateSessionLastSeen(sessionId string, lastSeen int64) error
+	RevokeSession(sessionId string, revokedAt int64) error
+	RevokeUserSessions(userId string, revokedAt int64) error
+	RemoveExpiredSessions(now int64) error
+
+	AddAuditEvent(event *au.AuditEvent) error
+	GetAuditEvents(filter AuditEventFilter) ([]au.AuditEvent, error)
 
 	AddRequestLog(log *au.RequestLogData) error
 	AddInfoLog(log *au.InfoLogData) error
+	GetLogs(filter LogFilter) ([]LogDocument, error)
 }
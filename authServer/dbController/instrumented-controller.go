@@ -0,0 +1,200 @@
+package dbController
+
+import (
+	"context"
+
+	au "methompson.com/auth-microservice/authServer/authUtils"
+)
+
+// An OperationObserver is called when a DatabaseController operation starts,
+// with the controller's context and the name of the DatabaseController method.
+// The function it returns is called with the operation's error, or nil, when the
+// operation finishes.
+type OperationObserver func(ctx context.Context, operation string) func(err error)
+
+// InstrumentedController is a DatabaseController that reports every operation
+// of the DatabaseController it wraps to an OperationObserver, so that metrics
+// and traces can be collected for any database.
+type InstrumentedController struct {
+	controller DatabaseController
+	observe    OperationObserver
+	ctx        context.Context
+}
+
+func NewInstrumentedController(controller DatabaseController, observe OperationObserver) *InstrumentedController {
+	return &InstrumentedController{
+		controller: controller,
+		observe:    observe,
+		ctx:        context.Background(),
+	}
+}
+
+// WithContext returns a copy of the controller that passes ctx to the
+// OperationObserver, e.g. so that operations are traced as part of a request
+func (ic *InstrumentedController) WithContext(ctx context.Context) *InstrumentedController {
+	copied := *ic
+	copied.ctx = ctx
+
+	return &copied
+}
+
+// Controller returns the wrapped DatabaseController
+func (ic *InstrumentedController) Controller() DatabaseController {
+	return ic.controller
+}
+
+func (ic *InstrumentedController) InitDatabase() (err error) {
+	defer ic.observed("InitDatabase")(&err)
+	return ic.controller.InitDatabase()
+}
+
+func (ic *InstrumentedController) Ping() (err error) {
+	defer ic.observed("Ping")(&err)
+	return ic.controller.Ping()
+}
+
+func (ic *InstrumentedController) GetUserByUsername(username string) (user FullUserDocument, err error) {
+	defer ic.observed("GetUserByUsername")(&err)
+	return ic.controller.GetUserByUsername(username)
+}
+
+func (ic *InstrumentedController) GetUserById(id string) (user FullUserDocument, err error) {
+	defer ic.observed("GetUserById")(&err)
+	return ic.controller.GetUserById(id)
+}
+
+func (ic *InstrumentedController) AddUser(userDoc FullUserDocument) (err error) {
+	defer ic.observed("AddUser")(&err)
+	return ic.controller.AddUser(userDoc)
+}
+
+func (ic *InstrumentedController) EditUser(userDoc EditUserDocument) (err error) {
+	defer ic.observed("EditUser")(&err)
+	return ic.controller.EditUser(userDoc)
+}
+
+func (ic *InstrumentedController) EditUserPassword(userId string, passwordHash string) (err error) {
+	defer ic.observed("EditUserPassword")(&err)
+	return ic.controller.EditUserPassword(userId, passwordHash)
+}
+
+func (ic *InstrumentedController) DeleteUser(userId string) (err error) {
+	defer ic.observed("DeleteUser")(&err)
+	return ic.controller.DeleteUser(userId)
+}
+
+func (ic *InstrumentedController) GetNonce(hashedNonce string, remoteAddress string, exp int64) (nonce NonceDocument, err error) {
+	defer ic.observed("GetNonce")(&err)
+	return ic.controller.GetNonce(hashedNonce, remoteAddress, exp)
+}
+
+func (ic *InstrumentedController) AddNonce(hashedNonce string, remoteAddress string, time int64) (err error) {
+	defer ic.observed("AddNonce")(&err)
+	return ic.controller.AddNonce(hashedNonce, remoteAddress, time)
+}
+
+func (ic *InstrumentedController) RemoveOldNonces(exp int64) (err error) {
+	defer ic.observed("RemoveOldNonces")(&err)
+	return ic.controller.RemoveOldNonces(exp)
+}
+
+func (ic *InstrumentedController) CountNonces() (count int64, err error) {
+	defer ic.observed("CountNonces")(&err)
+	return ic.controller.CountNonces()
+}
+
+func (ic *InstrumentedController) AddSession(session SessionDocument) (err error) {
+	defer ic.observed("AddSession")(&err)
+	return ic.controller.AddSession(session)
+}
+
+func (ic *InstrumentedController) GetSession(sessionId string) (session SessionDocument, err error) {
+	defer ic.observed("GetSession")(&err)
+	return ic.controller.GetSession(sessionId)
+}
+
+func (ic *InstrumentedController) GetUserSessions(userId string) (sessions []SessionDocument, err error) {
+	defer ic.observed("GetUserSessions")(&err)
+	return ic.controller.GetUserSessions(userId)
+}
+
+func (ic *InstrumentedController) UpdateSessionLastSeen(sessionId string, lastSeen int64) (err error) {
+	defer ic.observed("UpdateSessionLastSeen")(&err)
+	return ic.controller.UpdateSessionLastSeen(sessionId, lastSeen)
+}
+
+func (ic *InstrumentedController) RevokeSession(sessionId string, revokedAt int64) (err error) {
+	defer ic.observed("RevokeSession")(&err)
+	return ic.controller.RevokeSession(sessionId, revokedAt)
+}
+
+func (ic *InstrumentedController) RevokeUserSessions(userId string, revokedAt int64) (err error) {
+	defer ic.observed("RevokeUserSessions")(&err)
+	return ic.controller.RevokeUserSessions(userId, revokedAt)
+}
+
+func (ic *InstrumentedController) RemoveExpiredSessions(now int64) (err error) {
+	defer ic.observed("RemoveExpiredSessions")(&err)
+	return ic.controller.RemoveExpiredSessions(now)
+}
+
+func (ic *InstrumentedController) AddAuditEvent(event *au.AuditEvent) (err error) {
+	defer ic.observed("AddAuditEvent")(&err)
+	return ic.controller.AddAuditEvent(event)
+}
+
+func (ic *InstrumentedController) GetAuditEvents(filter AuditEventFilter) (events []au.AuditEvent, err error) {
+	defer ic.observed("GetAuditEvents")(&err)
+	return ic.controller.GetAuditEvents(filter)
+}
+
+func (ic *InstrumentedController) AddRequestLog(log *au.RequestLogData) (err error) {
+	defer ic.observed("AddRequestLog")(&err)
+	return ic.controller.AddRequestLog(log)
+}
+
+func (ic *InstrumentedController) AddInfoLog(log *au.InfoLogData) (err error) {
+	defer ic.observed("AddInfoLog")(&err)
+	return ic.controller.AddInfoLog(log)
+}
+
+// AddLogs writes the logs with a single operation if the wrapped controller is
+// a BatchLogger, and one at a time otherwise
+func (ic *InstrumentedController) AddLogs(entries []au.LogEntry) (err error) {
+	if batchLogger, ok := ic.controller.(au.BatchLogger); ok {
+		defer ic.observed("AddLogs")(&err)
+		return batchLogger.AddLogs(entries)
+	}
+
+	for _, entry := range entries {
+		var logErr error
+
+		if entry.Request != nil {
+			logErr = ic.AddRequestLog(entry.Request)
+		} else {
+			logErr = ic.AddInfoLog(entry.Info)
+		}
+
+		if logErr != nil {
+			err = logErr
+		}
+	}
+
+	return err
+}
+
+func (ic *InstrumentedController) GetLogs(filter LogFilter) (logs []LogDocument, err error) {
+	defer ic.observed("GetLogs")(&err)
+	return ic.controller.GetLogs(filter)
+}
+
+// observed starts observing an operation. The function it returns is deferred
+// with a pointer to the operation's named error result, which is only read once
+// the operation has returned.
+func (ic *InstrumentedController) observed(operation string) func(err *error) {
+	done := ic.observe(ic.ctx, operation)
+
+	return func(err *error) {
+		done(*err)
+	}
+}
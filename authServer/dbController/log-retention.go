@@ -0,0 +1,56 @@
+package dbController
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultLogRetention is the key of the retention used for log types that
+// aren't listed
+const DefaultLogRetention = "default"
+
+// LogRetention maps log types to how long logs of the type are kept
+type LogRetention map[string]time.Duration
+
+// ParseLogRetention parses a comma separated list of type:duration pairs, e.g.
+// request:720h,audit_unstored:8760h,default:168h. Types without a retention, and without
+// a default, are kept forever.
+func ParseLogRetention(str string) (LogRetention, error) {
+	retention := make(LogRetention)
+
+	if len(strings.TrimSpace(str)) == 0 {
+		return retention, nil
+	}
+
+	for _, pair := range strings.Split(str, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, NewInvalidInputError("log retention must be a list of type:duration pairs")
+		}
+
+		duration, parseErr := time.ParseDuration(parts[1])
+		if parseErr != nil || duration <= 0 {
+			return nil, NewInvalidInputError("invalid log retention for " + parts[0] + ": " + parts[1])
+		}
+
+		retention[parts[0]] = duration
+	}
+
+	return retention, nil
+}
+
+// ExpiresAt returns when a log of the type written at the timestamp expires. It
+// returns false if logs of the type are kept forever.
+func (lr LogRetention) ExpiresAt(logType string, timestamp time.Time) (time.Time, bool) {
+	duration, ok := lr[logType]
+	if !ok {
+		duration, ok = lr[DefaultLogRetention]
+	}
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return timestamp.Add(duration), true
+}
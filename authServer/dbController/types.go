@@ -1,5 +1,7 @@
 package dbController
 
+import "time"
+
 type NonceDocument struct {
 	NonceHash     string `bson:"hash"`
 	RemoteAddress string `bson:"remoteAddress"`
@@ -12,31 +14,103 @@ type FullUserDocument struct {
 	Email        string
 	Enabled      bool
 	Admin        bool
+	Attributes   map[string]interface{}
 	PasswordHash string
 }
 
 func (fud *FullUserDocument) GetUserDocument() UserDocument {
 	return UserDocument{
-		Id:       fud.Id,
-		Username: fud.Username,
-		Email:    fud.Email,
-		Enabled:  fud.Enabled,
-		Admin:    fud.Admin,
+		Id:         fud.Id,
+		Username:   fud.Username,
+		Email:      fud.Email,
+		Enabled:    fud.Enabled,
+		Admin:      fud.Admin,
+		Attributes: fud.Attributes,
 	}
 }
 
 type UserDocument struct {
-	Id       string
-	Username string
-	Email    string
-	Enabled  bool
-	Admin    bool
+	Id         string
+	Username   string
+	Email      string
+	Enabled    bool
+	Admin      bool
+	Attributes map[string]interface{}
 }
 
+// Attributes only contains the attributes that should change. An attribute
+// with a nil value is removed from the user.
 type EditUserDocument struct {
-	Id       string
-	Username *string
-	Email    *string
-	Enabled  *bool
-	Admin    *bool
+	Id         string
+	Username   *string
+	Email      *string
+	Enabled    *bool
+	Admin      *bool
+	Attributes map[string]interface{}
+}
+
+// SessionDocument describes a login or an impersonation. Times are Unix
+// timestamps in seconds. Each session has a RefreshTokenFamily, which groups
+// the refresh tokens issued for the session. A session is revoked if RevokedAt
+// is greater than 0.
+type SessionDocument struct {
+	Id                 string `bson:"_id"`
+	UserId             string `bson:"userId"`
+	UserAgent          string `bson:"userAgent"`
+	IPAddress          string `bson:"ipAddress"`
+	CreatedAt          int64  `bson:"createdAt"`
+	LastSeenAt         int64  `bson:"lastSeenAt"`
+	ExpiresAt          int64  `bson:"expiresAt"`
+	RefreshTokenFamily string `bson:"refreshTokenFamily"`
+	RevokedAt          int64  `bson:"revokedAt"`
+}
+
+func (sd *SessionDocument) Revoked() bool {
+	return sd.RevokedAt > 0
+}
+
+// AuditEventFilter selects audit events. Empty fields match every event. Events
+// are returned newest first, up to Limit events.
+type AuditEventFilter struct {
+	ActorId  string
+	TargetId string
+	Action   string
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+	Limit    int64
+}
+
+// LogFilter selects logs from the logging collection. Empty fields match every
+// log. Logs are returned newest first. Offset skips that many matching logs and
+// Limit sets the most logs returned.
+type LogFilter struct {
+	Type       string
+	Since      time.Time
+	Until      time.Time
+	StatusCode int
+	Path       string
+	ClientIP   string
+	Offset     int64
+	Limit      int64
+}
+
+// LogDocument is a stored log. Request logs have the request fields set and
+// other logs have a level, a message and attributes.
+type LogDocument struct {
+	Timestamp    time.Time              `json:"timestamp"`
+	Type         string                 `json:"type"`
+	Level        string                 `json:"level,omitempty"`
+	Message      string                 `json:"message,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	RequestId    string                 `json:"requestId,omitempty"`
+	UserId       string                 `json:"userId,omitempty"`
+	ClientIP     string                 `json:"clientIP,omitempty"`
+	Method       string                 `json:"method,omitempty"`
+	Path         string                 `json:"path,omitempty"`
+	Protocol     string                 `json:"protocol,omitempty"`
+	StatusCode   int                    `json:"statusCode,omitempty"`
+	Latency      time.Duration          `json:"latency,omitempty"`
+	UserAgent    string                 `json:"userAgent,omitempty"`
+	ErrorMessage string                 `json:"errorMessage,omitempty"`
 }
@@ -0,0 +1,63 @@
+package dbController
+
+import (
+	"sort"
+
+	"methompson.com/auth-microservice/authServer/authUtils"
+)
+
+// A UserCollision describes a group of users whose username or email are the
+// same once they've been normalized. Field is either "username" or "email" and
+// NormalizedValue is the shared, normalized value.
+type UserCollision struct {
+	Field           string
+	NormalizedValue string
+	Users           []UserDocument
+}
+
+// FindUserCollisions groups the users passed to it by their normalized usernames
+// and emails and returns every group that contains more than one user. The
+// collisions are sorted by field, then by normalized value, so that the output
+// is stable between runs.
+func FindUserCollisions(users []UserDocument) []UserCollision {
+	collisions := make([]UserCollision, 0)
+
+	collisions = append(collisions, findFieldCollisions(users, "username", func(u UserDocument) string {
+		return authUtils.NormalizeUsername(u.Username)
+	})...)
+
+	collisions = append(collisions, findFieldCollisions(users, "email", func(u UserDocument) string {
+		return authUtils.NormalizeEmail(u.Email)
+	})...)
+
+	return collisions
+}
+
+func findFieldCollisions(users []UserDocument, field string, normalize func(UserDocument) string) []UserCollision {
+	groups := make(map[string][]UserDocument)
+
+	for _, user := range users {
+		value := normalize(user)
+		groups[value] = append(groups[value], user)
+	}
+
+	collisions := make([]UserCollision, 0)
+
+	for value, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		collisions = append(collisions, UserCollision{
+			Field:           field,
+			NormalizedValue: value,
+			Users:           group,
+		})
+	}
+
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].NormalizedValue < collisions[j].NormalizedValue
+	})
+
+	return collisions
+}
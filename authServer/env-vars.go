@@ -6,8 +6,11 @@ import (
 	"github.com/joho/godotenv"
 
 	ac "methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authTLS"
+	"methompson.com/auth-microservice/authServer/authTracing"
 	"methompson.com/auth-microservice/authServer/authUtils"
 	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
 )
 
 func LoadEnvVariables() {
@@ -41,6 +44,80 @@ func CheckEnvVariables() error {
 
 	authUtils.SetHashCost()
 
+	_, customClaimsErr := ac.ParseCustomClaimsMapping(os.Getenv(constants.JWT_CUSTOM_CLAIMS))
+	if customClaimsErr != nil {
+		return NewEnvironmentVariableError("JWT_CUSTOM_CLAIMS is invalid: " + customClaimsErr.Error())
+	}
+
+	_, clockSkewErr := ac.ParseClockSkew(os.Getenv(constants.JWT_CLOCK_SKEW))
+	if clockSkewErr != nil {
+		return NewEnvironmentVariableError("JWT_CLOCK_SKEW is invalid: " + clockSkewErr.Error())
+	}
+
+	_, introspectionClientsErr := ParseIntrospectionClients(os.Getenv(constants.INTROSPECTION_CLIENTS))
+	if introspectionClientsErr != nil {
+		return introspectionClientsErr
+	}
+
+	_, authenticatorErr := GetAuthenticatorOptions()
+	if authenticatorErr != nil {
+		return authenticatorErr
+	}
+
+	_, logLevelErr := authUtils.ParseLogLevel(os.Getenv(constants.LOG_LEVEL))
+	if logLevelErr != nil {
+		return NewEnvironmentVariableError("LOG_LEVEL is invalid: " + logLevelErr.Error())
+	}
+
+	_, fileLoggerErr := authUtils.GetFileLoggerOptions()
+	if fileLoggerErr != nil {
+		return NewEnvironmentVariableError(fileLoggerErr.Error())
+	}
+
+	_, asyncLoggerErr := authUtils.GetAsyncLoggerOptions()
+	if asyncLoggerErr != nil {
+		return NewEnvironmentVariableError(asyncLoggerErr.Error())
+	}
+
+	_, logRetentionErr := dbController.ParseLogRetention(os.Getenv(constants.LOG_RETENTION))
+	if logRetentionErr != nil {
+		return NewEnvironmentVariableError("LOG_RETENTION is invalid: " + logRetentionErr.Error())
+	}
+
+	if os.Getenv(constants.SYSLOG_LOGGING) == "true" {
+		_, syslogErr := authUtils.GetSyslogOptions()
+		if syslogErr != nil {
+			return NewEnvironmentVariableError(syslogErr.Error())
+		}
+	}
+
+	if os.Getenv(constants.HTTP_LOGGING) == "true" {
+		_, httpLoggerErr := authUtils.GetHTTPLoggerOptions()
+		if httpLoggerErr != nil {
+			return NewEnvironmentVariableError(httpLoggerErr.Error())
+		}
+	}
+
+	_, tracingErr := authTracing.GetTracingOptions()
+	if tracingErr != nil {
+		return NewEnvironmentVariableError(tracingErr.Error())
+	}
+
+	_, grpcPortErr := GetGRPCPort()
+	if grpcPortErr != nil {
+		return grpcPortErr
+	}
+
+	_, tlsErr := authTLS.GetTLSOptions()
+	if tlsErr != nil {
+		return NewEnvironmentVariableError(tlsErr.Error())
+	}
+
+	_, shutdownTimeoutErr := GetShutdownTimeout()
+	if shutdownTimeoutErr != nil {
+		return shutdownTimeoutErr
+	}
+
 	openRSAErr := openAndSetRSAKeys()
 
 	if openRSAErr != nil {
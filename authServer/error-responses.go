@@ -0,0 +1,70 @@
+package authServer
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// ErrorToProblem is the central mapping between the errors returned by the
+// AuthController and the problem details sent to clients. Errors caused by the
+// client's input include the error's message as the problem's detail. Server
+// errors never expose their messages.
+func ErrorToProblem(err error) apiErrors.Problem {
+	switch err.(type) {
+	case authUtils.NonceError:
+		return apiErrors.New(apiErrors.InvalidNonce, "")
+	case LoginError:
+		return apiErrors.New(apiErrors.InvalidCredentials, "")
+	case UnauthorizedError:
+		return apiErrors.New(apiErrors.Forbidden, "")
+	case authCrypto.ExpiredJWTError:
+		return apiErrors.New(apiErrors.ExpiredToken, "")
+	case authCrypto.JWTError:
+		return apiErrors.New(apiErrors.InvalidToken, err.Error())
+	case authMiddleware.CSRFError:
+		return apiErrors.New(apiErrors.CSRFFailed, "")
+	case authMiddleware.ClientCertError:
+		return apiErrors.New(apiErrors.ClientCertRequired, "")
+	case dbController.NoResultsError:
+		return apiErrors.New(apiErrors.NotFound, "")
+	case dbController.DuplicateEntryError:
+		return apiErrors.New(apiErrors.DuplicateEntry, err.Error())
+	case dbController.InvalidInputError:
+		return apiErrors.New(apiErrors.InvalidInput, err.Error())
+	case dbController.DBError:
+		return apiErrors.New(apiErrors.DatabaseError, "")
+	case authCrypto.CryptoKeyError:
+		return apiErrors.New(apiErrors.CryptoKeyError, "")
+	case HashError:
+		return apiErrors.New(apiErrors.HashError, "")
+	default:
+		return apiErrors.New(apiErrors.InternalError, "")
+	}
+}
+
+// hideUnknownUser converts the NoResultsError returned by LogUserIn for an
+// unknown username into a LoginError. An unknown username is reported the same
+// way as a wrong password, so that clients can't find out which usernames exist.
+func hideUnknownUser(loginErr error) error {
+	if _, ok := loginErr.(dbController.NoResultsError); ok {
+		return NewLoginError("Invalid username or password")
+	}
+
+	return loginErr
+}
+
+// respondWithError writes the problem details for err to the response
+func respondWithError(ctx *gin.Context, err error) {
+	apiErrors.Write(ctx, ErrorToProblem(err))
+}
+
+// respondWithInvalidBody writes an invalid_request_body problem to the response.
+// It's used when a request body can't be bound.
+func respondWithInvalidBody(ctx *gin.Context) {
+	apiErrors.WriteCode(ctx, apiErrors.InvalidRequestBody, "missing required values")
+}
@@ -23,3 +23,9 @@ type EnvironmentVariableError struct{ ErrMsg string }
 
 func (err EnvironmentVariableError) Error() string { return err.ErrMsg }
 func NewEnvironmentVariableError(msg string) error { return EnvironmentVariableError{msg} }
+
+// Used for when a readiness check fails
+type HealthError struct{ ErrMsg string }
+
+func (err HealthError) Error() string { return err.ErrMsg }
+func NewHealthError(msg string) error { return HealthError{msg} }
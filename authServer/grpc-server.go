@@ -0,0 +1,438 @@
+package authServer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+	"methompson.com/auth-microservice/authServer/authPb"
+	"methompson.com/auth-microservice/authServer/authTLS"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// The domain of the ErrorInfo details attached to gRPC errors
+const grpcErrorDomain = "auth-microservice"
+
+// GetGRPCPort returns the port the gRPC server listens on. An empty string
+// means that the gRPC server is disabled.
+func GetGRPCPort() (string, error) {
+	port := strings.TrimSpace(os.Getenv(constants.GRPC_PORT))
+	if len(port) == 0 {
+		return "", nil
+	}
+
+	portNum, portErr := strconv.Atoi(port)
+	if portErr != nil || portNum < 1 || portNum > 65535 {
+		return "", NewEnvironmentVariableError(fmt.Sprintf("GRPC_PORT '%s' is not a valid port", port))
+	}
+
+	if port == os.Getenv("PORT") {
+		return "", NewEnvironmentVariableError("GRPC_PORT must be different from PORT")
+	}
+
+	return port, nil
+}
+
+// startGRPCServer starts the gRPC server in the background if GRPC_PORT is set
+func (as *AuthServer) startGRPCServer() {
+	port, _ := GetGRPCPort()
+	if len(port) == 0 {
+		return
+	}
+
+	listener, listenErr := net.Listen("tcp", ":"+port)
+	if listenErr != nil {
+		log.Fatal("Error starting the gRPC server: ", listenErr.Error())
+	}
+
+	options := make([]grpc.ServerOption, 0)
+
+	if as.TLSConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(as.TLSConfig)))
+	}
+
+	// The environment variables are checked on startup, so the error is ignored
+	if tlsOptions, _ := authTLS.GetTLSOptions(); tlsOptions.RequireAdminClientCert {
+		options = append(options, grpc.ChainUnaryInterceptor(ClientCertInterceptor()))
+	}
+
+	as.grpcServer = NewGRPCServer(&as.AuthController, options...)
+
+	go func() {
+		if serveErr := as.grpcServer.Serve(listener); serveErr != nil {
+			as.AuthController.Logger().Error("error serving gRPC", "error", serveErr)
+		}
+	}()
+}
+
+// NewGRPCServer returns a gRPC server exposing the AuthService. It uses the same
+// AuthController as the HTTP routes. Tokens are validated by TokenInterceptor,
+// using AuthController.ValidateToken. Interceptors chained in options run after
+// TokenInterceptor.
+func NewGRPCServer(ac *AuthController, options ...grpc.ServerOption) *grpc.Server {
+	validator := authMiddleware.ValidatorFunc(ac.ValidateToken)
+
+	options = append([]grpc.ServerOption{grpc.UnaryInterceptor(TokenInterceptor(validator))}, options...)
+
+	server := grpc.NewServer(options...)
+	authPb.RegisterAuthServiceServer(server, &AuthGRPCService{AuthController: ac})
+
+	return server
+}
+
+/****************************************************************************************
+* Authentication
+****************************************************************************************/
+
+type grpcAccess int
+
+const (
+	grpcAuthenticated grpcAccess = iota
+	grpcAdmin
+)
+
+// grpcMethodAccess lists the methods that require a token. Methods that aren't
+// listed are public.
+var grpcMethodAccess = map[string]grpcAccess{
+	"/auth.v1.AuthService/AddUser":          grpcAdmin,
+	"/auth.v1.AuthService/EditUser":         grpcAuthenticated,
+	"/auth.v1.AuthService/EditUserPassword": grpcAuthenticated,
+}
+
+type grpcClaimsKey struct{}
+
+// TokenInterceptor returns a unary interceptor that validates the token in the
+// authorization metadata of requests to methods that require one. It's the gRPC
+// counterpart of the RequireAuth and RequireAdmin middleware. The claims of a
+// valid token are stored in the request's context.
+func TokenInterceptor(validator authMiddleware.TokenValidator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		access, ok := grpcMethodAccess[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		claims, claimsErr := grpcTokenClaims(ctx, validator)
+		if claimsErr != nil {
+			switch claimsErr.(type) {
+			case authCrypto.JWTError, authCrypto.ExpiredJWTError:
+				return nil, ErrorToStatus(claimsErr)
+			default:
+				return nil, ProblemToStatus(apiErrors.New(apiErrors.ServiceUnavailable, ""))
+			}
+		}
+
+		if access == grpcAdmin && !claims.Admin {
+			return nil, ErrorToStatus(NewUnauthorizedError("Not authorized to perform this action"))
+		}
+
+		return handler(context.WithValue(ctx, grpcClaimsKey{}, claims), req)
+	}
+}
+
+// ClientCertInterceptor returns a unary interceptor that rejects requests to
+// admin methods, and requests from admins acting on another user's account, from
+// connections without a verified client certificate. It's the gRPC counterpart
+// of the RequireAdminWithClientCert middleware. It must run after
+// TokenInterceptor.
+func ClientCertInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		access, ok := grpcMethodAccess[info.FullMethod]
+		if !ok || grpcHasClientCert(ctx) {
+			return handler(ctx, req)
+		}
+
+		required := access == grpcAdmin
+
+		// EditUser and EditUserPassword requests name the user they act on
+		if target, isTargeted := req.(interface{ GetId() string }); isTargeted {
+			if claims := grpcClaims(ctx); claims != nil && claims.Admin && target.GetId() != claims.Subject {
+				required = true
+			}
+		}
+
+		if required {
+			return nil, ErrorToStatus(authMiddleware.NewClientCertError("a client certificate is required"))
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// grpcHasClientCert reports whether the request's connection presented a
+// verified client certificate
+func grpcHasClientCert(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return false
+	}
+
+	return authMiddleware.HasClientCert(&tlsInfo.State)
+}
+
+// grpcTokenClaims validates the Bearer token in the authorization metadata
+func grpcTokenClaims(ctx context.Context, validator authMiddleware.TokenValidator) (*authCrypto.JWTClaims, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, value := range md.Get("authorization") {
+		if token, ok := authMiddleware.ParseBearerToken(value); ok {
+			return validator.Verify(token)
+		}
+	}
+
+	return nil, authCrypto.NewJWTError("missing jwt from request")
+}
+
+// grpcClaims returns the claims stored by TokenInterceptor
+func grpcClaims(ctx context.Context) *authCrypto.JWTClaims {
+	claims, _ := ctx.Value(grpcClaimsKey{}).(*authCrypto.JWTClaims)
+
+	return claims
+}
+
+/****************************************************************************************
+* Errors
+****************************************************************************************/
+
+// problemStatusCodes maps the HTTP status of a problem to a gRPC status code
+var problemStatusCodes = map[int]codes.Code{
+	400: codes.InvalidArgument,
+	401: codes.Unauthenticated,
+	403: codes.PermissionDenied,
+	404: codes.NotFound,
+	409: codes.AlreadyExists,
+	503: codes.Unavailable,
+}
+
+// ErrorToStatus converts an error into a gRPC status error using the same
+// mapping as the HTTP routes. The problem's error code is attached as the
+// reason of an ErrorInfo detail.
+func ErrorToStatus(err error) error {
+	return ProblemToStatus(ErrorToProblem(err))
+}
+
+// ProblemToStatus converts a problem into a gRPC status error
+func ProblemToStatus(problem apiErrors.Problem) error {
+	code, ok := problemStatusCodes[problem.Status]
+	if !ok {
+		code = codes.Internal
+	}
+
+	msg := problem.Title
+	if len(problem.Detail) > 0 {
+		msg = msg + ": " + problem.Detail
+	}
+
+	st := status.New(code, msg)
+
+	withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: problem.Code,
+		Domain: grpcErrorDomain,
+	})
+	if detailsErr == nil {
+		st = withDetails
+	}
+
+	return st.Err()
+}
+
+// validateGRPCBody checks the binding tags of a body struct, like Gin does
+// when a route binds a request body
+func validateGRPCBody(body interface{}) error {
+	if validationErr := binding.Validator.ValidateStruct(body); validationErr != nil {
+		return ProblemToStatus(apiErrors.New(apiErrors.InvalidRequestBody, "missing required values"))
+	}
+
+	return nil
+}
+
+/****************************************************************************************
+* AuthGRPCService
+****************************************************************************************/
+
+// grpcRequestContext is the RequestContext of a gRPC request. Headers are read
+// from the request's metadata.
+type grpcRequestContext struct {
+	clientIP string
+	md       metadata.MD
+	// Holds the trace context sent by the client
+	ctx context.Context
+}
+
+func (rc grpcRequestContext) ClientIP() string { return rc.clientIP }
+
+func (rc grpcRequestContext) GetHeader(key string) string {
+	if values := rc.md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func newGRPCRequestContext(ctx context.Context) grpcRequestContext {
+	md, _ := metadata.FromIncomingContext(ctx)
+	rc := grpcRequestContext{
+		md:  md,
+		ctx: otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md)),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, splitErr := net.SplitHostPort(p.Addr.String())
+		if splitErr != nil {
+			host = p.Addr.String()
+		}
+		rc.clientIP = host
+	}
+
+	return rc
+}
+
+// metadataCarrier reads and writes trace context in gRPC metadata, where it's
+// sent in the same traceparent key as in HTTP headers
+type metadataCarrier metadata.MD
+
+func (mc metadataCarrier) Get(key string) string {
+	if values := metadata.MD(mc).Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (mc metadataCarrier) Set(key string, value string) {
+	metadata.MD(mc).Set(key, value)
+}
+
+func (mc metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(mc))
+	for key := range mc {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// AuthGRPCService implements the AuthService. Requests are converted to the
+// body structs used by the HTTP routes and passed to the same AuthController
+// methods.
+type AuthGRPCService struct {
+	authPb.UnimplementedAuthServiceServer
+	AuthController *AuthController
+}
+
+func (s *AuthGRPCService) GetNonce(ctx context.Context, req *authPb.GetNonceRequest) (*authPb.GetNonceResponse, error) {
+	nonce, nonceErr := s.AuthController.GenerateNonce(newGRPCRequestContext(ctx))
+	if nonceErr != nil {
+		return nil, ErrorToStatus(nonceErr)
+	}
+
+	return &authPb.GetNonceResponse{Nonce: nonce}, nil
+}
+
+func (s *AuthGRPCService) Login(ctx context.Context, req *authPb.LoginRequest) (*authPb.LoginResponse, error) {
+	body := LoginBody{
+		Username: req.Username,
+		Password: req.Password,
+		Nonce:    req.Nonce,
+		Audience: req.Audience,
+		Scopes:   req.Scopes,
+	}
+	if validationErr := validateGRPCBody(&body); validationErr != nil {
+		return nil, validationErr
+	}
+
+	token, loginErr := s.AuthController.LogUserIn(body, newGRPCRequestContext(ctx))
+	if loginErr != nil {
+		return nil, ErrorToStatus(hideUnknownUser(loginErr))
+	}
+
+	return &authPb.LoginResponse{Token: token}, nil
+}
+
+func (s *AuthGRPCService) AddUser(ctx context.Context, req *authPb.AddUserRequest) (*authPb.AddUserResponse, error) {
+	body := AddUserBody{
+		Username:   req.Username,
+		Email:      req.Email,
+		Password:   req.Password,
+		Enabled:    req.Enabled,
+		Admin:      req.Admin,
+		Attributes: req.Attributes.AsMap(),
+		Nonce:      req.Nonce,
+	}
+	if validationErr := validateGRPCBody(&body); validationErr != nil {
+		return nil, validationErr
+	}
+
+	if addErr := s.AuthController.AddNewUser(body, grpcClaims(ctx), newGRPCRequestContext(ctx)); addErr != nil {
+		return nil, ErrorToStatus(addErr)
+	}
+
+	return &authPb.AddUserResponse{}, nil
+}
+
+func (s *AuthGRPCService) EditUser(ctx context.Context, req *authPb.EditUserRequest) (*authPb.EditUserResponse, error) {
+	body := EditUserBody{
+		Id:       req.Id,
+		Username: req.Username,
+		Email:    req.Email,
+		Enabled:  req.Enabled,
+		Admin:    req.Admin,
+		Nonce:    req.Nonce,
+	}
+	if req.Attributes != nil {
+		body.Attributes = req.Attributes.AsMap()
+	}
+	if validationErr := validateGRPCBody(&body); validationErr != nil {
+		return nil, validationErr
+	}
+
+	if editErr := s.AuthController.EditUser(&body, grpcClaims(ctx), newGRPCRequestContext(ctx)); editErr != nil {
+		return nil, ErrorToStatus(editErr)
+	}
+
+	return &authPb.EditUserResponse{}, nil
+}
+
+func (s *AuthGRPCService) EditUserPassword(ctx context.Context, req *authPb.EditUserPasswordRequest) (*authPb.EditUserPasswordResponse, error) {
+	body := EditPasswordBody{
+		Id:          req.Id,
+		OldPassword: req.OldPassword,
+		NewPassword: req.NewPassword,
+		Nonce:       req.Nonce,
+	}
+	if validationErr := validateGRPCBody(&body); validationErr != nil {
+		return nil, validationErr
+	}
+
+	if editErr := s.AuthController.EditUserPassword(&body, grpcClaims(ctx), newGRPCRequestContext(ctx)); editErr != nil {
+		return nil, ErrorToStatus(editErr)
+	}
+
+	return &authPb.EditUserPasswordResponse{}, nil
+}
+
+func (s *AuthGRPCService) GetPublicKey(ctx context.Context, req *authPb.GetPublicKeyRequest) (*authPb.GetPublicKeyResponse, error) {
+	return &authPb.GetPublicKeyResponse{PublicKey: os.Getenv(constants.RSA_PUBLIC_KEY)}, nil
+}
@@ -0,0 +1,135 @@
+package authServer
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+)
+
+// The statuses of a HealthResponse and its checks
+const HealthOK = "ok"
+const HealthFailed = "failed"
+const HealthUnavailable = "unavailable"
+
+// getHealthRoute is the GET /healthz route. It reports that the process is alive
+// and serving requests, without checking any dependency, so that a failing
+// database doesn't get the service restarted.
+func (as *AuthServer) getHealthRoute(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, HealthResponse{Status: HealthOK})
+}
+
+// getReadinessRoute is the GET /readyz route. It responds with 503 while any of
+// the readiness checks fails, so that the instance is taken out of rotation
+// until its dependencies recover.
+func (as *AuthServer) getReadinessRoute(ctx *gin.Context) {
+	response := as.AuthController.CheckReadiness()
+
+	status := http.StatusOK
+	if response.Status != HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	ctx.JSON(status, response)
+}
+
+// CheckReadiness checks that the service can handle requests: the database can
+// be reached, tokens can be signed and verified with the RSA keys and the
+// loggers can write logs. The response's status is HealthUnavailable if any
+// check failed.
+func (ac *AuthController) CheckReadiness() HealthResponse {
+	response := HealthResponse{
+		Status: HealthOK,
+		Checks: map[string]HealthCheck{
+			"database":    runHealthCheck(ac.checkDatabase),
+			"signingKeys": runHealthCheck(checkSigningKeys),
+			"loggers":     runHealthCheck(ac.checkLoggers),
+		},
+	}
+
+	for _, check := range response.Checks {
+		if check.Status != HealthOK {
+			response.Status = HealthUnavailable
+		}
+	}
+
+	return response
+}
+
+// runHealthCheck runs a check and measures how long it took
+func runHealthCheck(check func() error) HealthCheck {
+	start := time.Now()
+	checkErr := check()
+
+	result := HealthCheck{
+		Status:    HealthOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if checkErr != nil {
+		result.Status = HealthFailed
+		result.Error = checkErr.Error()
+	}
+
+	return result
+}
+
+// checkDatabase pings the database. The database's error can include its
+// address, so it's logged rather than returned.
+func (ac *AuthController) checkDatabase() error {
+	pingErr := (*ac.DBController).Ping()
+	if pingErr != nil {
+		ac.Logger().Warn("database readiness check failed", "error", pingErr)
+		return NewHealthError("database is unreachable")
+	}
+
+	return nil
+}
+
+// checkSigningKeys signs a string with the private key and verifies the
+// signature with the public key
+func checkSigningKeys() error {
+	publicKey, publicKeyErr := authCrypto.GetRSAPublicKey()
+	if publicKeyErr != nil {
+		return publicKeyErr
+	}
+
+	signature, signErr := authCrypto.SignString("readiness")
+	if signErr != nil {
+		return signErr
+	}
+
+	return authCrypto.VerifyStringSignature("readiness", signature, publicKey)
+}
+
+// checkLoggers asks every logger that can report its health whether it can
+// write logs. The loggers' errors can include file paths and addresses, so
+// they're logged and only the names of the failing loggers are returned.
+func (ac *AuthController) checkLoggers() error {
+	failures := make([]string, 0)
+
+	for i, logger := range ac.Loggers {
+		if checked, ok := (*logger).(authUtils.HealthCheckedLogger); ok {
+			if healthErr := checked.CheckHealth(); healthErr != nil {
+				name := fmt.Sprintf("logger %d", i)
+				if named, ok := (*logger).(interface{ Name() string }); ok && len(named.Name()) > 0 {
+					name = named.Name() + " logger"
+				}
+
+				ac.Logger().Warn("logger readiness check failed", "logger", name, "error", healthErr)
+				failures = append(failures, name+" can't write logs")
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return NewHealthError(strings.Join(failures, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package authServer
+
+import (
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// ImpersonateUser returns a short lived token that lets an admin act as another
+// user. The admin is identified in the token's act claim. Each impersonation
+// has a session of the user's, so that the token can be revoked like a login's.
+// Impersonation tokens can't change passwords or perform admin operations, and
+// can't be used to impersonate another user. Every attempt is written to the
+// audit log.
+func (ac *AuthController) ImpersonateUser(id string, nonce string, claims *authCrypto.JWTClaims, ctx RequestContext) (token string, err error) {
+	ctx, span := ac.startSpan(ctx, "ImpersonateUser")
+	defer func() { authTracing.EndSpan(span, err) }()
+
+	targetUsername := ""
+	defer func() {
+		ac.addAuditEvent(newAuditEvent(authUtils.AuditImpersonate, claims, id, targetUsername, err, ctx), ctx)
+	}()
+
+	// If we're not in debug mode OR we're in debug mode and we're NOT ignoring nonces
+	if !DebugMode() || (!authUtils.IgnoringNonce() && DebugMode()) {
+		nonceErr := ac.CheckNonceValidity(nonce, ctx)
+
+		if nonceErr != nil {
+			return "", nonceErr
+		}
+	}
+
+	if !claims.Admin || claims.Impersonated() {
+		return "", NewUnauthorizedError("Not authorized to perform this action")
+	}
+
+	if id == claims.Subject {
+		return "", dbController.NewInvalidInputError("Admins cannot impersonate themselves")
+	}
+
+	userDoc, userDocErr := ac.db(ctx).GetUserById(id)
+	if userDocErr != nil {
+		return "", userDocErr
+	}
+	targetUsername = userDoc.Username
+
+	session, sessionErr := ac.createSession(userDoc.Id, time.Now().Add(constants.IMPERSONATION_JWT_EXPIRATION).Unix(), ctx)
+	if sessionErr != nil {
+		return "", sessionErr
+	}
+
+	impersonationToken, tokenErr := authCrypto.GenerateImpersonationJWT(userDoc.GetUserDocument(), authCrypto.ActorClaim{
+		Subject:  claims.Subject,
+		Username: claims.Username,
+	}, session.Id)
+	if tokenErr != nil {
+		return "", tokenErr
+	}
+
+	return impersonationToken, nil
+}
@@ -0,0 +1,54 @@
+package authServer
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// ParseIntrospectionClients parses the value of the INTROSPECTION_CLIENTS
+// environment variable. The value is a comma separated list of registered
+// clients in the form client_id:secret_hash, where secret_hash is the hex
+// encoded sha3-512 hash of the client's secret. The returned map uses the
+// client id as the key and the secret hash as the value.
+func ParseIntrospectionClients(str string) (map[string]string, error) {
+	clients := make(map[string]string)
+
+	for _, entry := range strings.Split(str, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, NewEnvironmentVariableError(fmt.Sprintf("invalid introspection client '%s'", entry))
+		}
+
+		clients[parts[0]] = strings.ToLower(parts[1])
+	}
+
+	return clients, nil
+}
+
+// CheckIntrospectionClient returns true if the client id and secret belong to
+// a client registered in INTROSPECTION_CLIENTS.
+func CheckIntrospectionClient(clientId string, clientSecret string) bool {
+	clients, clientsErr := ParseIntrospectionClients(os.Getenv(constants.INTROSPECTION_CLIENTS))
+	if clientsErr != nil {
+		return false
+	}
+
+	secretHash, ok := clients[clientId]
+	if !ok {
+		return false
+	}
+
+	hash := authUtils.HashString(clientSecret)
+
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(secretHash)) == 1
+}
@@ -0,0 +1,156 @@
+package jwtVerifier
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+/****************************************************************************************
+* JWT Claims Struct
+****************************************************************************************/
+
+// JWTClaims are the claims contained in every JWT generated by the auth service.
+// Scope is a space separated list of scopes, as described in RFC 8693. Custom
+// holds the claims copied from a user's attributes. SessionId is the id of the
+// login session the token was issued for. Actor identifies the admin that
+// requested an impersonation token. They're written to, and read from, the top
+// level of the JWT payload.
+type JWTClaims struct {
+	Username  string                 `json:"username"`
+	Email     string                 `json:"email"`
+	Admin     bool                   `json:"admin"`
+	Scope     string                 `json:"scope,omitempty"`
+	SessionId string                 `json:"sid,omitempty"`
+	Actor     *ActorClaim            `json:"act,omitempty"`
+	Custom    map[string]interface{} `json:"-"`
+	jwt.StandardClaims
+}
+
+// ActorClaim is the act claim described in RFC 8693. It identifies the party
+// acting on behalf of the token's subject.
+type ActorClaim struct {
+	Subject  string `json:"sub"`
+	Username string `json:"username,omitempty"`
+}
+
+// Valid always returns nil. The time, issuer and audience checks depend on the
+// configuration of a Verifier, so they're performed by Verifier.VerifyClaims
+// rather than while the token is parsed.
+func (jc JWTClaims) Valid() error {
+	return nil
+}
+
+// Scopes returns the token's scopes as a slice
+func (jc *JWTClaims) Scopes() []string {
+	return strings.Fields(jc.Scope)
+}
+
+// Impersonated returns true if the token was issued to an admin impersonating
+// the token's subject
+func (jc *JWTClaims) Impersonated() bool {
+	return jc.Actor != nil
+}
+
+// HasScope returns true if the token was granted the scope
+func (jc *JWTClaims) HasScope(scope string) bool {
+	for _, s := range jc.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+/****************************************************************************************
+* JWTClaims JSON Encoding
+****************************************************************************************/
+
+// We use claimsAlias to encode and decode the regular JWTClaims fields without
+// recursing into JWTClaims' own MarshalJSON and UnmarshalJSON methods.
+type claimsAlias JWTClaims
+
+// MarshalJSON writes the custom claims at the top level of the JWT payload, next
+// to the regular claims. Regular claims always take precedence.
+func (jc JWTClaims) MarshalJSON() ([]byte, error) {
+	claimBytes, marshalErr := json.Marshal(claimsAlias(jc))
+	if marshalErr != nil || len(jc.Custom) == 0 {
+		return claimBytes, marshalErr
+	}
+
+	allClaims := make(map[string]interface{})
+	if unmarshalErr := json.Unmarshal(claimBytes, &allClaims); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	reserved := ReservedClaimNames()
+	for claim, value := range jc.Custom {
+		if reserved[claim] {
+			continue
+		}
+
+		allClaims[claim] = value
+	}
+
+	return json.Marshal(allClaims)
+}
+
+// UnmarshalJSON reads the regular claims into their fields and collects every
+// other claim in Custom.
+func (jc *JWTClaims) UnmarshalJSON(data []byte) error {
+	if unmarshalErr := json.Unmarshal(data, (*claimsAlias)(jc)); unmarshalErr != nil {
+		return unmarshalErr
+	}
+
+	allClaims := make(map[string]interface{})
+	if unmarshalErr := json.Unmarshal(data, &allClaims); unmarshalErr != nil {
+		return unmarshalErr
+	}
+
+	for claim := range ReservedClaimNames() {
+		delete(allClaims, claim)
+	}
+
+	if len(allClaims) > 0 {
+		jc.Custom = allClaims
+	}
+
+	return nil
+}
+
+// ReservedClaimNames returns the JSON names of every field in JWTClaims,
+// including the fields of the embedded standard claims. Custom claims can't
+// use these names.
+func ReservedClaimNames() map[string]bool {
+	names := make(map[string]bool)
+	addJSONFieldNames(reflect.TypeOf(JWTClaims{}), names)
+
+	return names
+}
+
+func addJSONFieldNames(t reflect.Type, names map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && len(tag) == 0 {
+			addJSONFieldNames(field.Type, names)
+			continue
+		}
+
+		if name == "-" {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		names[name] = true
+	}
+}
@@ -0,0 +1,13 @@
+package jwtVerifier
+
+// Used for when there's a generic issue reading or writing JWTs
+type JWTError struct{ ErrMsg string }
+
+func (err JWTError) Error() string { return err.ErrMsg }
+func NewJWTError(msg string) error { return JWTError{msg} }
+
+// Used for when the JWT expires
+type ExpiredJWTError struct{ ErrMsg string }
+
+func (err ExpiredJWTError) Error() string { return err.ErrMsg }
+func NewExpiredJWTError(msg string) error { return ExpiredJWTError{msg} }
@@ -0,0 +1,165 @@
+// Package jwtVerifier verifies the JWTs issued by the auth service. It only
+// depends on the JWT library and the standard library, so other services can
+// import it to verify tokens with the auth service's public key instead of
+// copying the auth service's validation code.
+package jwtVerifier
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Options configures a Verifier. PublicKey is required. When Issuer is set, a
+// token's iss claim must match it. When Audiences is set, a token's aud claim
+// must match one of them. ClockSkew is the leeway given to the exp, nbf and iat
+// checks to account for clocks that aren't perfectly in sync. Now can be used
+// to replace time.Now, e.g. in tests.
+type Options struct {
+	PublicKey *rsa.PublicKey
+	Issuer    string
+	Audiences []string
+	ClockSkew time.Duration
+	Now       func() time.Time
+}
+
+type Verifier struct {
+	options Options
+}
+
+// NewVerifier returns a Verifier for the options passed to it. It returns a
+// JWTError if no public key was provided.
+func NewVerifier(options Options) (*Verifier, error) {
+	if options.PublicKey == nil {
+		return nil, NewJWTError("a public key is required to verify JWTs")
+	}
+
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+
+	return &Verifier{options}, nil
+}
+
+// Verify checks the signature of a JWT and then checks its claims with
+// VerifyClaims. It returns the token's claims if the token is valid. Expired
+// tokens return an ExpiredJWTError and all other invalid tokens return a JWTError.
+func (v *Verifier) Verify(tokenString string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+
+	_, parseErr := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, NewJWTError(fmt.Sprintf("invalid signing method: %v", token.Header["alg"]))
+		}
+
+		return v.options.PublicKey, nil
+	})
+
+	if parseErr != nil {
+		if validationErr, ok := parseErr.(*jwt.ValidationError); ok && validationErr.Inner != nil {
+			return nil, NewJWTError(validationErr.Inner.Error())
+		}
+
+		return nil, NewJWTError(parseErr.Error())
+	}
+
+	if claimsErr := v.VerifyClaims(claims); claimsErr != nil {
+		return nil, claimsErr
+	}
+
+	return claims, nil
+}
+
+// VerifyClaims checks the time based claims of a token, as well as its issuer and
+// audience. Tokens must have an exp claim. The nbf and iat claims are optional,
+// but must not be in the future if they exist.
+func (v *Verifier) VerifyClaims(claims *JWTClaims) error {
+	now := v.options.Now()
+	skew := v.options.ClockSkew
+
+	if claims.ExpiresAt == 0 {
+		return NewJWTError("token has no expiration time")
+	}
+
+	if now.Add(-skew).Unix() > claims.ExpiresAt {
+		return NewExpiredJWTError("token is expired")
+	}
+
+	if claims.NotBefore != 0 && now.Add(skew).Unix() < claims.NotBefore {
+		return NewJWTError("token is not valid yet")
+	}
+
+	if claims.IssuedAt != 0 && now.Add(skew).Unix() < claims.IssuedAt {
+		return NewJWTError("token used before issued")
+	}
+
+	if len(v.options.Issuer) > 0 && claims.Issuer != v.options.Issuer {
+		return NewJWTError("invalid issuer")
+	}
+
+	if len(v.options.Audiences) > 0 && !v.acceptsAudience(claims.Audience) {
+		return NewJWTError("invalid audience")
+	}
+
+	return nil
+}
+
+func (v *Verifier) acceptsAudience(audience string) bool {
+	for _, aud := range v.options.Audiences {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseRSAPublicKey parses a PEM encoded PKIX RSA public key, such as the one
+// returned by the auth service's /public-key route.
+func ParseRSAPublicKey(publicKeyBytes []byte) (*rsa.PublicKey, error) {
+	publicKeyBlock, _ := pem.Decode(publicKeyBytes)
+	if publicKeyBlock == nil {
+		return nil, NewJWTError("failed to decode public key")
+	}
+
+	publicKeyInt, publicKeyIntErr := x509.ParsePKIXPublicKey(publicKeyBlock.Bytes)
+	if publicKeyIntErr != nil {
+		return nil, NewJWTError("failed to parse public key PEM block")
+	}
+
+	publicKey, ok := publicKeyInt.(*rsa.PublicKey)
+	if !ok {
+		return nil, NewJWTError("public key is not an RSA key")
+	}
+
+	return publicKey, nil
+}
+
+// FetchRSAPublicKey retrieves and parses the public key served by the auth
+// service's /public-key route, e.g. https://auth.example.com/public-key
+func FetchRSAPublicKey(url string) (*rsa.PublicKey, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+
+	response, responseErr := client.Get(url)
+	if responseErr != nil {
+		return nil, NewJWTError("failed to fetch public key: " + responseErr.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, NewJWTError(fmt.Sprintf("failed to fetch public key: status %d", response.StatusCode))
+	}
+
+	publicKeyBytes, readErr := io.ReadAll(response.Body)
+	if readErr != nil {
+		return nil, NewJWTError("failed to read public key: " + readErr.Error())
+	}
+
+	return ParseRSAPublicKey(publicKeyBytes)
+}
@@ -0,0 +1,55 @@
+package authServer
+
+import (
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// The number of logs returned when a query doesn't set a limit, and the most
+// that a query can request
+const defaultLogsLimit = 100
+const maxLogsLimit = 1000
+
+// GetLogs returns a page of the logs matching the filter, newest first, and
+// whether more logs follow the page. Only admins can read the logs.
+func (ac *AuthController) GetLogs(filter dbController.LogFilter, claims *authCrypto.JWTClaims, ctx RequestContext) (response LogsResponse, logsErr error) {
+	ctx, span := ac.startSpan(ctx, "GetLogs")
+	defer func() { authTracing.EndSpan(span, logsErr) }()
+
+	if !claims.Admin || claims.Impersonated() {
+		return LogsResponse{}, NewUnauthorizedError("Not authorized to perform this action")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLogsLimit
+	}
+	if filter.Limit > maxLogsLimit {
+		filter.Limit = maxLogsLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	limit := filter.Limit
+
+	// One more log is requested to find out if there's another page
+	filter.Limit++
+
+	logs, getErr := ac.db(ctx).GetLogs(filter)
+	if getErr != nil {
+		return LogsResponse{}, getErr
+	}
+
+	hasMore := int64(len(logs)) > limit
+	if hasMore {
+		logs = logs[:limit]
+	}
+
+	return LogsResponse{
+		Logs:    logs,
+		Offset:  filter.Offset,
+		Limit:   limit,
+		HasMore: hasMore,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package authServer
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/authMetrics"
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// getMetricsRoute is the GET /metrics route. It serves the metrics in the
+// Prometheus exposition format.
+func (as *AuthServer) getMetricsRoute(ctx *gin.Context) {
+	authMetrics.Handler().ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// observeDBOperation is the OperationObserver of the server's DatabaseController.
+// It measures every operation and traces the operations run as part of a trace.
+// A NoResultsError is an expected result, e.g. for an unknown username, so it
+// isn't counted as a failed operation.
+func observeDBOperation(ctx context.Context, operation string) func(err error) {
+	endSpan := authTracing.StartDBOperation(ctx, operation)
+	done := authMetrics.ObserveDBOperation(operation)
+
+	return func(err error) {
+		if _, ok := err.(dbController.NoResultsError); ok {
+			err = nil
+		}
+
+		done(err)
+		endSpan(err)
+	}
+}
+
+// loginOutcome returns the outcome label of a login attempt. Failed logins are
+// labelled with the error code sent to the client, except that unknown usernames
+// are told apart from wrong passwords.
+func loginOutcome(loginErr error) string {
+	if loginErr == nil {
+		return authMetrics.LoginSuccess
+	}
+
+	if _, ok := loginErr.(dbController.NoResultsError); ok {
+		return "unknown_user"
+	}
+
+	return ErrorToProblem(loginErr).Code
+}
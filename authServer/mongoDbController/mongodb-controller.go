@@ -3,6 +3,7 @@ package mongoDbController
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
@@ -11,23 +12,58 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
 	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
 	"methompson.com/auth-microservice/authServer/dbController"
 )
 
 type MongoDbController struct {
-	MongoClient *mongo.Client
-	dbName      string
+	MongoClient  *mongo.Client
+	dbName       string
+	logRetention dbController.LogRetention
 }
 
+// userCollation is the collation used by the unique username and email indexes
+// and by any query that looks users up by those values. A strength of 2 compares
+// base characters and diacritics while ignoring case, so the indexes reject
+// usernames and emails that only differ by case, even if they were written
+// before normalization existed.
+var userCollation = &options.Collation{Locale: "en", Strength: 2}
+
 type UserDocResult struct {
-	Id           string `bson:"_id"`
-	Username     string `bson:"username"`
-	Email        string `bson:"email"`
-	Enabled      bool   `bson:"enabled"`
-	Admin        bool   `bson:"admin"`
-	PasswordHash string `bson:"passwordHash"`
+	Id           string                 `bson:"_id"`
+	Username     string                 `bson:"username"`
+	Email        string                 `bson:"email"`
+	Enabled      bool                   `bson:"enabled"`
+	Admin        bool                   `bson:"admin"`
+	Attributes   map[string]interface{} `bson:"attributes,omitempty"`
+	PasswordHash string                 `bson:"passwordHash"`
+}
+
+// Ping checks that the primary can be reached. It waits at most 2 seconds, so
+// that readiness checks don't hang while the database is unreachable.
+func (mdbc *MongoDbController) Ping() error {
+	backCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if pingErr := mdbc.MongoClient.Ping(backCtx, readpref.Primary()); pingErr != nil {
+		return dbController.NewDBError(pingErr.Error())
+	}
+
+	return nil
+}
+
+// Disconnect closes the client's connections once their operations finish, or
+// when ctx is done. It isn't named Close so that closing the AsyncLogger writing
+// logs to the database doesn't disconnect it.
+func (mdbc *MongoDbController) Disconnect(ctx context.Context) error {
+	if disconnectErr := mdbc.MongoClient.Disconnect(ctx); disconnectErr != nil {
+		return dbController.NewDBError(disconnectErr.Error())
+	}
+
+	return nil
 }
 
 // InitDatabase runs several commands that create the user, nonce and logging collections.
@@ -49,10 +85,22 @@ func (mdbc *MongoDbController) InitDatabase() error {
 
 	initLoggingErr := mdbc.initLoggingDatabase(mdbc.dbName)
 
-	if initLoggingErr != nil && !strings.Contains(nonceCreationErr.Error(), "Collection already exists") {
+	if initLoggingErr != nil && !strings.Contains(initLoggingErr.Error(), "Collection already exists") {
 		return initLoggingErr
 	}
 
+	sessionCreationErr := mdbc.initSessionCollection(mdbc.dbName)
+
+	if sessionCreationErr != nil && !strings.Contains(sessionCreationErr.Error(), "Collection already exists") {
+		return sessionCreationErr
+	}
+
+	auditCreationErr := mdbc.initAuditCollection(mdbc.dbName)
+
+	if auditCreationErr != nil && !strings.Contains(auditCreationErr.Error(), "Collection already exists") {
+		return auditCreationErr
+	}
+
 	return nil
 }
 
@@ -60,9 +108,13 @@ func (mdbc *MongoDbController) InitDatabase() error {
 // sets the schema for the collection. The function accepts a dbName string
 // that represents the name of the database in which the collections are created.
 // The schema makes the username, passwordHash, email and enabled keys required.
+// The optional attributes object may only contain the displayName, locale and
+// metadata keys.
 // Afterward, indexes are created for the collection that make username and email
-// unique. The return value is an error in case an errors are encountered during
-// initialization.
+// unique, using userCollation so that the uniqueness check is case insensitive.
+// Collections created by older versions have their schema updated and their
+// indexes migrated instead. The return value is an error in case an errors are
+// encountered during initialization.
 func (mdbc *MongoDbController) initUserCollection(dbName string) error {
 	db := mdbc.MongoClient.Database(dbName)
 
@@ -90,6 +142,25 @@ func (mdbc *MongoDbController) initUserCollection(dbName string) error {
 				"bsonType":    "bool",
 				"description": "admin is required and must be a boolean",
 			},
+			"attributes": bson.M{
+				"bsonType":             "object",
+				"description":          "attributes is optional and must be an object",
+				"additionalProperties": false,
+				"properties": bson.M{
+					"displayName": bson.M{
+						"bsonType":    "string",
+						"description": "displayName must be a string",
+					},
+					"locale": bson.M{
+						"bsonType":    "string",
+						"description": "locale must be a string",
+					},
+					"metadata": bson.M{
+						"bsonType":    "object",
+						"description": "metadata must be an object",
+					},
+				},
+			},
 		},
 	}
 
@@ -97,28 +168,27 @@ func (mdbc *MongoDbController) initUserCollection(dbName string) error {
 
 	createCollectionErr := db.CreateCollection(context.TODO(), "users", colOpts)
 
-	if createCollectionErr != nil {
-		return dbController.NewDBError(createCollectionErr.Error())
-	}
+	if createCollectionErr != nil && strings.Contains(createCollectionErr.Error(), "Collection already exists") {
+		// Collections created by older versions get the current schema. Existing
+		// documents are only checked against it when they're next updated.
+		collModErr := db.RunCommand(context.TODO(), bson.D{
+			{Key: "collMod", Value: "users"},
+			{Key: "validator", Value: bson.M{"$jsonSchema": jsonSchema}},
+		}).Err()
 
-	models := []mongo.IndexModel{
-		{
-			Keys:    bson.D{{Key: "username", Value: 1}},
-			Options: options.Index().SetUnique(true),
-		},
-		{
-			Keys:    bson.D{{Key: "email", Value: 1}},
-			Options: options.Index().SetUnique(true),
-		},
-	}
+		if collModErr != nil {
+			return dbController.NewDBError(collModErr.Error())
+		}
 
-	opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
+		return mdbc.migrateUserIndexes()
+	}
 
-	collection, _, _ := mdbc.getCollection("users")
-	_, setIndexErr := collection.Indexes().CreateMany(context.TODO(), models, opts)
+	if createCollectionErr != nil {
+		return dbController.NewDBError(createCollectionErr.Error())
+	}
 
-	if setIndexErr != nil {
-		return dbController.NewDBError(setIndexErr.Error())
+	if setIndexErr := mdbc.createUserIndexes(); setIndexErr != nil {
+		return setIndexErr
 	}
 
 	hashedPass, hashedPassErr := authUtils.HashPassword("password")
@@ -138,13 +208,102 @@ func (mdbc *MongoDbController) initUserCollection(dbName string) error {
 	)
 
 	if addUserErr != nil {
-		fmt.Println(addUserErr.Error())
+		slog.Error("error adding the admin user", "error", addUserErr)
 		return dbController.NewDBError(addUserErr.Error())
 	}
 
 	return nil
 }
 
+// createUserIndexes creates the unique username and email indexes, using
+// userCollation
+func (mdbc *MongoDbController) createUserIndexes() error {
+	models := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true).SetCollation(userCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetCollation(userCollation),
+		},
+	}
+
+	opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
+
+	collection, _, _ := mdbc.getCollection("users")
+	names, setIndexErr := collection.Indexes().CreateMany(context.TODO(), models, opts)
+
+	if setIndexErr != nil {
+		return dbController.NewDBError(setIndexErr.Error())
+	}
+
+	slog.Debug("created indexes", "collection", "users", "indexes", names)
+
+	return nil
+}
+
+// migrateUserIndexes replaces the username and email indexes of a users
+// collection created before the indexes used userCollation. The collated indexes
+// would reject users that collide once normalized, so the indexes are only
+// replaced if there are none. Otherwise the old indexes are kept and a warning
+// asks for the collisions to be resolved.
+func (mdbc *MongoDbController) migrateUserIndexes() error {
+	collection, backCtx, cancel := mdbc.getCollection("users")
+	defer cancel()
+
+	cursor, listErr := collection.Indexes().List(backCtx)
+	if listErr != nil {
+		return dbController.NewDBError(listErr.Error())
+	}
+
+	var indexes []struct {
+		Name      string `bson:"name"`
+		Collation *struct {
+			Locale   string `bson:"locale"`
+			Strength int    `bson:"strength"`
+		} `bson:"collation"`
+	}
+
+	if decodeErr := cursor.All(backCtx, &indexes); decodeErr != nil {
+		return dbController.NewDBError(decodeErr.Error())
+	}
+
+	outdated := make([]string, 0)
+	for _, index := range indexes {
+		if index.Name != "username_1" && index.Name != "email_1" {
+			continue
+		}
+
+		if index.Collation == nil || index.Collation.Locale != userCollation.Locale || index.Collation.Strength != userCollation.Strength {
+			outdated = append(outdated, index.Name)
+		}
+	}
+
+	if len(outdated) > 0 {
+		users, usersErr := mdbc.GetAllUsers()
+		if usersErr != nil {
+			return usersErr
+		}
+
+		if collisions := dbController.FindUserCollisions(users); len(collisions) > 0 {
+			slog.Warn("users collide once normalized, so the username and email indexes are still case sensitive. Run find-user-collisions to list them.", "collisions", len(collisions))
+			return nil
+		}
+
+		for _, name := range outdated {
+			if _, dropErr := collection.Indexes().DropOne(backCtx, name); dropErr != nil {
+				return dbController.NewDBError(dropErr.Error())
+			}
+		}
+
+		slog.Info("dropped indexes without the user collation", "collection", "users", "indexes", outdated)
+	}
+
+	// Creates the dropped indexes, along with any that were never created
+	return mdbc.createUserIndexes()
+}
+
 // initNonceDatabase is a private method that creates the authNonce collection
 // and sets the schema for the collection. The function accepts a dbName string
 // that represents the name of the database in which the collections are created.
@@ -202,7 +361,7 @@ func (mdbc *MongoDbController) initNonceDatabase(dbName string) error {
 		return dbController.NewDBError(setIndexErr.Error())
 	}
 
-	fmt.Printf("created indexes %v\n", names)
+	slog.Debug("created indexes", "collection", "authNonces", "indexes", names)
 
 	return nil
 }
@@ -230,16 +389,229 @@ func (mdbc *MongoDbController) initLoggingDatabase(dbName string) error {
 		},
 	}
 
+	// The collection used to be capped. Capped collections can't expire
+	// documents, so logs are removed with a TTL index instead.
 	colOpts := options.CreateCollection().SetValidator(bson.M{"$jsonSchema": jsonSchema})
-	colOpts.SetCapped(true)
-	colOpts.SetSizeInBytes(100000)
 
 	createCollectionErr := db.CreateCollection(context.TODO(), "logging", colOpts)
 
+	if createCollectionErr != nil && !strings.Contains(createCollectionErr.Error(), "Collection already exists") {
+		return dbController.NewDBError(createCollectionErr.Error())
+	}
+
+	// The indexes are also created for existing collections, which were created
+	// without them
+	return mdbc.createLoggingIndexes(db)
+}
+
+// createLoggingIndexes creates the indexes used to query the logging collection
+// and the TTL index that removes expired logs. Each log's expiresAt date is set
+// from LOG_RETENTION when it's written. Older versions created the collection
+// capped, which can't have a TTL index, so the TTL index is skipped with a
+// warning until that collection is dropped and recreated.
+func (mdbc *MongoDbController) createLoggingIndexes(db *mongo.Database) error {
+	models := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "timestamp", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "type", Value: 1}, {Key: "timestamp", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "clientIP", Value: 1}, {Key: "timestamp", Value: -1}},
+		},
+	}
+
+	capped, cappedErr := mdbc.loggingCollectionCapped(db)
+	if cappedErr != nil {
+		return cappedErr
+	}
+
+	if capped {
+		slog.Warn("the logging collection is capped, so LOG_RETENTION doesn't apply. Drop the collection to recreate it without a cap.")
+	} else {
+		models = append(models, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+	}
+
+	opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
+
+	collection, _, _ := mdbc.getCollection("logging")
+	names, setIndexErr := collection.Indexes().CreateMany(context.TODO(), models, opts)
+
+	if setIndexErr != nil {
+		return dbController.NewDBError(setIndexErr.Error())
+	}
+
+	slog.Debug("created indexes", "collection", "logging", "indexes", names)
+
+	return nil
+}
+
+func (mdbc *MongoDbController) loggingCollectionCapped(db *mongo.Database) (bool, error) {
+	specs, specsErr := db.ListCollectionSpecifications(context.TODO(), bson.D{{Key: "name", Value: "logging"}})
+	if specsErr != nil {
+		return false, dbController.NewDBError(specsErr.Error())
+	}
+
+	for _, spec := range specs {
+		if capped, ok := spec.Options.Lookup("capped").BooleanOK(); ok && capped {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// initSessionCollection is a private method that creates the sessions collection
+// and sets the schema for the collection. Session ids are generated by the
+// AuthController, so they're stored as strings in _id. An index on userId is used
+// to list a user's sessions.
+func (mdbc *MongoDbController) initSessionCollection(dbName string) error {
+	db := mdbc.MongoClient.Database(dbName)
+
+	jsonSchema := bson.M{
+		"bsonType": "object",
+		"required": []string{"userId", "createdAt", "lastSeenAt", "expiresAt", "revokedAt"},
+		"properties": bson.M{
+			"userId": bson.M{
+				"bsonType":    "string",
+				"description": "userId is required and must be a string",
+			},
+			"userAgent": bson.M{
+				"bsonType":    "string",
+				"description": "userAgent must be a string",
+			},
+			"ipAddress": bson.M{
+				"bsonType":    "string",
+				"description": "ipAddress must be a string",
+			},
+			"createdAt": bson.M{
+				"bsonType":    "long",
+				"description": "createdAt is required and must be a 64-bit integer (aka a long)",
+			},
+			"lastSeenAt": bson.M{
+				"bsonType":    "long",
+				"description": "lastSeenAt is required and must be a 64-bit integer (aka a long)",
+			},
+			"expiresAt": bson.M{
+				"bsonType":    "long",
+				"description": "expiresAt is required and must be a 64-bit integer (aka a long)",
+			},
+			"refreshTokenFamily": bson.M{
+				"bsonType":    "string",
+				"description": "refreshTokenFamily must be a string",
+			},
+			"revokedAt": bson.M{
+				"bsonType":    "long",
+				"description": "revokedAt is required and must be a 64-bit integer (aka a long)",
+			},
+		},
+	}
+
+	colOpts := options.CreateCollection().SetValidator(bson.M{"$jsonSchema": jsonSchema})
+
+	createCollectionErr := db.CreateCollection(context.TODO(), "sessions", colOpts)
+
+	if createCollectionErr != nil {
+		return dbController.NewDBError(createCollectionErr.Error())
+	}
+
+	models := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "expiresAt", Value: 1}},
+		},
+	}
+
+	opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
+
+	collection, _, _ := mdbc.getCollection("sessions")
+	names, setIndexErr := collection.Indexes().CreateMany(context.TODO(), models, opts)
+
+	if setIndexErr != nil {
+		return dbController.NewDBError(setIndexErr.Error())
+	}
+
+	slog.Debug("created indexes", "collection", "sessions", "indexes", names)
+
+	return nil
+}
+
+// initAuditCollection is a private method that creates the audit collection and
+// sets the schema for the collection. Unlike the logging collection, it isn't
+// capped, so audit events are never discarded. The unique index on sequence
+// keeps two events from taking the same place in the audit chain. Indexes on
+// the actor, target and timestamp are used to filter the events.
+func (mdbc *MongoDbController) initAuditCollection(dbName string) error {
+	db := mdbc.MongoClient.Database(dbName)
+
+	jsonSchema := bson.M{
+		"bsonType": "object",
+		"required": []string{"sequence", "timestamp", "action", "outcome", "hash"},
+		"properties": bson.M{
+			"sequence": bson.M{
+				"bsonType":    "long",
+				"description": "sequence is required and must be a 64-bit integer (aka a long)",
+			},
+			"timestamp": bson.M{
+				"bsonType":    "date",
+				"description": "timestamp is required and must be a date",
+			},
+			"action": bson.M{
+				"bsonType":    "string",
+				"description": "action is required and must be a string",
+			},
+			"outcome": bson.M{
+				"bsonType":    "string",
+				"description": "outcome is required and must be a string",
+			},
+			"hash": bson.M{
+				"bsonType":    "string",
+				"description": "hash is required and must be a string",
+			},
+		},
+	}
+
+	colOpts := options.CreateCollection().SetValidator(bson.M{"$jsonSchema": jsonSchema})
+
+	createCollectionErr := db.CreateCollection(context.TODO(), "audit", colOpts)
+
 	if createCollectionErr != nil {
 		return dbController.NewDBError(createCollectionErr.Error())
 	}
 
+	models := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "timestamp", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "timestamp", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "timestamp", Value: -1}},
+		},
+	}
+
+	opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
+
+	collection, _, _ := mdbc.getCollection("audit")
+	names, setIndexErr := collection.Indexes().CreateMany(context.TODO(), models, opts)
+
+	if setIndexErr != nil {
+		return dbController.NewDBError(setIndexErr.Error())
+	}
+
+	slog.Debug("created indexes", "collection", "audit", "indexes", names)
+
 	return nil
 }
 
@@ -259,15 +631,18 @@ func (mdbc *MongoDbController) getCollection(collectionName string) (*mongo.Coll
 // The function accepts a username and password from the user and returns a UserDocument
 // struct and an error. The errors returned are either a document error or a database
 // error. GetUserByUsername doesn't perform any logic to generate values it uses for
-// searching the database.
+// searching the database, but the query uses the same collation as the username
+// index so that it matches the index's notion of equality.
 func (mdbc *MongoDbController) GetUserByUsername(username string) (dbController.FullUserDocument, error) {
 	collection, colCtx, cancel := mdbc.getCollection("users")
 	defer cancel()
 
+	opts := options.FindOne().SetCollation(userCollation)
+
 	var result UserDocResult
 	mdbErr := collection.FindOne(colCtx, bson.D{
 		{Key: "username", Value: username},
-	}).Decode(&result)
+	}, opts).Decode(&result)
 
 	// If no document exists, we'll get an error
 	if mdbErr != nil {
@@ -288,6 +663,7 @@ func (mdbc *MongoDbController) GetUserByUsername(username string) (dbController.
 		Email:        result.Email,
 		Enabled:      result.Enabled,
 		Admin:        result.Admin,
+		Attributes:   result.Attributes,
 		PasswordHash: result.PasswordHash,
 	}, nil
 }
@@ -321,14 +697,48 @@ func (mdbc *MongoDbController) GetUserById(id string) (dbController.FullUserDocu
 	}
 
 	return dbController.FullUserDocument{
-		Id:       result.Id,
-		Username: result.Username,
-		Email:    result.Email,
-		Enabled:  result.Enabled,
-		Admin:    result.Admin,
+		Id:         result.Id,
+		Username:   result.Username,
+		Email:      result.Email,
+		Enabled:    result.Enabled,
+		Admin:      result.Admin,
+		Attributes: result.Attributes,
 	}, nil
 }
 
+// GetAllUsers retrieves every user document in the users collection. It's meant
+// for maintenance tasks, such as looking for users that collide once their
+// usernames and emails are normalized, and should not be used when serving requests.
+func (mdbc *MongoDbController) GetAllUsers() ([]dbController.UserDocument, error) {
+	collection := mdbc.MongoClient.Database(mdbc.dbName).Collection("users")
+	backCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, mdbErr := collection.Find(backCtx, bson.D{})
+	if mdbErr != nil {
+		return nil, dbController.NewDBError(mdbErr.Error())
+	}
+
+	var results []UserDocResult
+	if decodeErr := cursor.All(backCtx, &results); decodeErr != nil {
+		return nil, dbController.NewDBError(decodeErr.Error())
+	}
+
+	users := make([]dbController.UserDocument, 0, len(results))
+	for _, result := range results {
+		users = append(users, dbController.UserDocument{
+			Id:         result.Id,
+			Username:   result.Username,
+			Email:      result.Email,
+			Enabled:    result.Enabled,
+			Admin:      result.Admin,
+			Attributes: result.Attributes,
+		})
+	}
+
+	return users, nil
+}
+
 func (mdbc *MongoDbController) AddUser(userDoc dbController.FullUserDocument) error {
 	collection, backCtx, cancel := mdbc.getCollection("users")
 	defer cancel()
@@ -341,11 +751,15 @@ func (mdbc *MongoDbController) AddUser(userDoc dbController.FullUserDocument) er
 		{Key: "admin", Value: userDoc.Admin},
 	}
 
+	if len(userDoc.Attributes) > 0 {
+		insert = append(insert, bson.E{Key: "attributes", Value: userDoc.Attributes})
+	}
+
 	_, mdbErr := collection.InsertOne(backCtx, insert)
 
 	if mdbErr != nil {
 		err := mdbErr.Error()
-		print("Add User Error: " + err + "\n")
+		slog.Error("error adding user", "error", mdbErr)
 
 		if strings.Contains(err, "duplicate key error") {
 			msg := "Duplicate user."
@@ -383,22 +797,35 @@ func (mdbc *MongoDbController) EditUser(userDoc dbController.EditUserDocument) e
 		values = append(values, bson.E{Key: "admin", Value: userDoc.Admin})
 	}
 
+	// Attributes are set or removed one at a time so that attributes which
+	// aren't part of the edit are left alone.
+	unsetValues := bson.D{}
+	for key, value := range userDoc.Attributes {
+		if value == nil {
+			unsetValues = append(unsetValues, bson.E{Key: "attributes." + key, Value: ""})
+		} else {
+			values = append(values, bson.E{Key: "attributes." + key, Value: value})
+		}
+	}
+
 	id, idErr := primitive.ObjectIDFromHex(userDoc.Id)
 	if idErr != nil {
 		return dbController.NewInvalidInputError("Invalid User ID")
 	}
 	filter := bson.D{{Key: "_id", Value: id}}
 
-	update := bson.D{{
-		Key: "$set", Value: values,
-	}}
+	// MongoDB before 5.0 rejects an empty $set, so it's left out when the edit
+	// only removes attributes
+	update := bson.D{}
+	if len(values) > 0 || len(unsetValues) == 0 {
+		update = append(update, bson.E{Key: "$set", Value: values})
+	}
+	if len(unsetValues) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unsetValues})
+	}
 
 	result, mdbErr := collection.UpdateOne(backCtx, filter, update)
 
-	if result.MatchedCount == 0 {
-		return dbController.NewInvalidInputError("Id did not match any users")
-	}
-
 	if mdbErr != nil {
 		err := mdbErr.Error()
 
@@ -416,6 +843,10 @@ func (mdbc *MongoDbController) EditUser(userDoc dbController.EditUserDocument) e
 		return dbController.NewDBError(mdbErr.Error())
 	}
 
+	if result.MatchedCount == 0 {
+		return dbController.NewInvalidInputError("Id did not match any users")
+	}
+
 	return nil
 }
 
@@ -441,16 +872,39 @@ func (mdbc *MongoDbController) EditUserPassword(userId string, passwordHash stri
 
 	result, mdbErr := collection.UpdateOne(backCtx, filter, update)
 
+	if mdbErr != nil {
+		slog.Error("error editing user", "error", mdbErr)
+
+		return dbController.NewDBError(mdbErr.Error())
+	}
+
 	if result.MatchedCount == 0 {
 		return dbController.NewInvalidInputError("Id did not match any users")
 	}
 
-	if mdbErr != nil {
-		print("Edit User Error: " + mdbErr.Error() + "\n")
+	return nil
+}
+
+// DeleteUser removes a user. It returns a NoResultsError if no user has the id.
+func (mdbc *MongoDbController) DeleteUser(userId string) error {
+	id, idErr := primitive.ObjectIDFromHex(userId)
+	if idErr != nil {
+		return dbController.NewInvalidInputError("Invalid User ID")
+	}
+
+	collection, backCtx, cancel := mdbc.getCollection("users")
+	defer cancel()
 
+	result, mdbErr := collection.DeleteOne(backCtx, bson.D{{Key: "_id", Value: id}})
+
+	if mdbErr != nil {
 		return dbController.NewDBError(mdbErr.Error())
 	}
 
+	if result.DeletedCount == 0 {
+		return dbController.NewNoResultsError("Id did not match any users")
+	}
+
 	return nil
 }
 
@@ -537,6 +991,22 @@ func (mdbc *MongoDbController) RemoveOldNonces(exp int64) error {
 	return nil
 }
 
+// CountNonces returns the number of stored nonces. The count comes from the
+// collection's metadata, so it's cheap but may be slightly off after an unclean
+// shutdown.
+func (mdbc *MongoDbController) CountNonces() (int64, error) {
+	collection, backCtx, cancel := mdbc.getCollection("authNonces")
+	defer cancel()
+
+	count, mdbErr := collection.EstimatedDocumentCount(backCtx)
+
+	if mdbErr != nil {
+		return 0, dbController.NewDBError(mdbErr.Error())
+	}
+
+	return count, nil
+}
+
 // AddRequestLog expects a RequestLogData object and attempts to write it to the
 // database. If there are any issues saving the log information, an error will be
 // returned.
@@ -544,9 +1014,66 @@ func (mdbc *MongoDbController) AddRequestLog(log *authUtils.RequestLogData) erro
 	collection, backCtx, cancel := mdbc.getCollection("logging")
 	defer cancel()
 
-	insert := bson.D{
+	_, mdbErr := collection.InsertOne(backCtx, mdbc.requestLogDocument(log))
+
+	if mdbErr != nil {
+		return dbController.NewDBError(mdbErr.Error())
+	}
+
+	return nil
+}
+
+// AddErrorLog expects an ErrorLogData object and attempts to write it to the
+// database. If there are any issues saving the log information, an error will be
+// returned.
+func (mdbc *MongoDbController) AddInfoLog(log *authUtils.InfoLogData) error {
+	collection, backCtx, cancel := mdbc.getCollection("logging")
+	defer cancel()
+
+	_, mdbErr := collection.InsertOne(backCtx, mdbc.infoLogDocument(log))
+
+	if mdbErr != nil {
+		return dbController.NewDBError(mdbErr.Error())
+	}
+
+	return nil
+}
+
+// AddLogs writes several logs with a single insert. The insert is unordered, so
+// one invalid log doesn't stop the others from being written.
+func (mdbc *MongoDbController) AddLogs(entries []authUtils.LogEntry) error {
+	collection, backCtx, cancel := mdbc.getCollection("logging")
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.Request != nil {
+			documents = append(documents, mdbc.requestLogDocument(entry.Request))
+		} else if entry.Info != nil {
+			documents = append(documents, mdbc.infoLogDocument(entry.Info))
+		}
+	}
+
+	if len(documents) == 0 {
+		return nil
+	}
+
+	_, mdbErr := collection.InsertMany(backCtx, documents, options.InsertMany().SetOrdered(false))
+
+	if mdbErr != nil {
+		return dbController.NewDBError(mdbErr.Error())
+	}
+
+	return nil
+}
+
+func (mdbc *MongoDbController) requestLogDocument(log *authUtils.RequestLogData) bson.D {
+	document := bson.D{
 		{Key: "timestamp", Value: primitive.Timestamp{T: uint32(log.Timestamp.Unix())}},
 		{Key: "type", Value: log.Type},
+		{Key: "requestId", Value: log.RequestId},
+		{Key: "userId", Value: log.UserId},
 		{Key: "clientIP", Value: log.ClientIP},
 		{Key: "method", Value: log.Method},
 		{Key: "path", Value: log.Path},
@@ -557,7 +1084,142 @@ func (mdbc *MongoDbController) AddRequestLog(log *authUtils.RequestLogData) erro
 		{Key: "errorMessage", Value: log.ErrorMessage},
 	}
 
-	_, mdbErr := collection.InsertOne(backCtx, insert)
+	return mdbc.addLogExpiry(document, log.Type, log.Timestamp)
+}
+
+func (mdbc *MongoDbController) infoLogDocument(log *authUtils.InfoLogData) bson.D {
+	document := bson.D{
+		{Key: "timestamp", Value: primitive.Timestamp{T: uint32(log.Timestamp.Unix())}},
+		{Key: "level", Value: log.Level},
+		{Key: "type", Value: log.Type},
+		{Key: "message", Value: log.Message},
+	}
+
+	if len(log.Attributes) > 0 {
+		document = append(document, bson.E{Key: "attributes", Value: log.Attributes})
+	}
+
+	return mdbc.addLogExpiry(document, log.Type, log.Timestamp)
+}
+
+// addLogExpiry sets the date the TTL index removes the log on, if logs of its
+// type expire
+func (mdbc *MongoDbController) addLogExpiry(document bson.D, logType string, timestamp time.Time) bson.D {
+	expiresAt, expires := mdbc.logRetention.ExpiresAt(logType, timestamp)
+	if !expires {
+		return document
+	}
+
+	return append(document, bson.E{Key: "expiresAt", Value: expiresAt})
+}
+
+// logDocument is a log as stored in the logging collection
+type logDocument struct {
+	Timestamp    primitive.Timestamp    `bson:"timestamp"`
+	Type         string                 `bson:"type"`
+	Level        string                 `bson:"level"`
+	Message      string                 `bson:"message"`
+	Attributes   map[string]interface{} `bson:"attributes"`
+	RequestId    string                 `bson:"requestId"`
+	UserId       string                 `bson:"userId"`
+	ClientIP     string                 `bson:"clientIP"`
+	Method       string                 `bson:"method"`
+	Path         string                 `bson:"path"`
+	Protocol     string                 `bson:"protocol"`
+	StatusCode   int                    `bson:"statusCode"`
+	Latency      time.Duration          `bson:"latency"`
+	UserAgent    string                 `bson:"userAgent"`
+	ErrorMessage string                 `bson:"errorMessage"`
+}
+
+func (ld logDocument) LogDocument() dbController.LogDocument {
+	return dbController.LogDocument{
+		Timestamp:    time.Unix(int64(ld.Timestamp.T), 0).UTC(),
+		Type:         ld.Type,
+		Level:        ld.Level,
+		Message:      ld.Message,
+		Attributes:   ld.Attributes,
+		RequestId:    ld.RequestId,
+		UserId:       ld.UserId,
+		ClientIP:     ld.ClientIP,
+		Method:       ld.Method,
+		Path:         ld.Path,
+		Protocol:     ld.Protocol,
+		StatusCode:   ld.StatusCode,
+		Latency:      ld.Latency,
+		UserAgent:    ld.UserAgent,
+		ErrorMessage: ld.ErrorMessage,
+	}
+}
+
+// GetLogs returns the logs matching the filter, newest first. Log timestamps
+// are stored in seconds, so Since and Until are compared in seconds.
+func (mdbc *MongoDbController) GetLogs(filter dbController.LogFilter) ([]dbController.LogDocument, error) {
+	collection, backCtx, cancel := mdbc.getCollection("logging")
+	defer cancel()
+
+	query := bson.D{}
+	if len(filter.Type) > 0 {
+		query = append(query, bson.E{Key: "type", Value: filter.Type})
+	}
+	if filter.StatusCode > 0 {
+		query = append(query, bson.E{Key: "statusCode", Value: filter.StatusCode})
+	}
+	if len(filter.Path) > 0 {
+		query = append(query, bson.E{Key: "path", Value: filter.Path})
+	}
+	if len(filter.ClientIP) > 0 {
+		query = append(query, bson.E{Key: "clientIP", Value: filter.ClientIP})
+	}
+
+	timestamp := bson.M{}
+	if !filter.Since.IsZero() {
+		timestamp["$gte"] = primitive.Timestamp{T: uint32(filter.Since.Unix())}
+	}
+	if !filter.Until.IsZero() {
+		// Every log written in the Until second is included
+		timestamp["$lte"] = primitive.Timestamp{T: uint32(filter.Until.Unix()), I: ^uint32(0)}
+	}
+	if len(timestamp) > 0 {
+		query = append(query, bson.E{Key: "timestamp", Value: timestamp})
+	}
+
+	// Logs written in the same second are sorted by _id, which increases
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+	if filter.Offset > 0 {
+		opts.SetSkip(filter.Offset)
+	}
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, mdbErr := collection.Find(backCtx, query, opts)
+	if mdbErr != nil {
+		return nil, dbController.NewDBError(mdbErr.Error())
+	}
+
+	documents := make([]logDocument, 0)
+	if cursorErr := cursor.All(backCtx, &documents); cursorErr != nil {
+		return nil, dbController.NewDBError(cursorErr.Error())
+	}
+
+	logs := make([]dbController.LogDocument, 0, len(documents))
+	for _, document := range documents {
+		logs = append(logs, document.LogDocument())
+	}
+
+	return logs, nil
+}
+
+/****************************************************************************************
+* Sessions
+****************************************************************************************/
+
+func (mdbc *MongoDbController) AddSession(session dbController.SessionDocument) error {
+	collection, backCtx, cancel := mdbc.getCollection("sessions")
+	defer cancel()
+
+	_, mdbErr := collection.InsertOne(backCtx, session)
 
 	if mdbErr != nil {
 		return dbController.NewDBError(mdbErr.Error())
@@ -566,20 +1228,69 @@ func (mdbc *MongoDbController) AddRequestLog(log *authUtils.RequestLogData) erro
 	return nil
 }
 
-// AddErrorLog expects an ErrorLogData object and attempts to write it to the
-// database. If there are any issues saving the log information, an error will be
-// returned.
-func (mdbc *MongoDbController) AddInfoLog(log *authUtils.InfoLogData) error {
-	collection, backCtx, cancel := mdbc.getCollection("logging")
+// GetSession returns a NoResultsError if no session has the id
+func (mdbc *MongoDbController) GetSession(sessionId string) (dbController.SessionDocument, error) {
+	collection, backCtx, cancel := mdbc.getCollection("sessions")
 	defer cancel()
 
-	insert := bson.D{
-		{Key: "timestamp", Value: primitive.Timestamp{T: uint32(log.Timestamp.Unix())}},
-		{Key: "type", Value: log.Type},
-		{Key: "message", Value: log.Message},
+	var result dbController.SessionDocument
+	mdbErr := collection.FindOne(backCtx, bson.D{{Key: "_id", Value: sessionId}}).Decode(&result)
+
+	if mdbErr != nil {
+		if mdbErr == mongo.ErrNoDocuments {
+			return result, dbController.NewNoResultsError("")
+		}
+
+		msg := fmt.Sprintln("error getting data from database: ", mdbErr.Error())
+		return result, dbController.NewDBError(msg)
 	}
 
-	_, mdbErr := collection.InsertOne(backCtx, insert)
+	return result, nil
+}
+
+// GetUserSessions returns all of a user's sessions, newest first, including
+// revoked and expired sessions that haven't been removed yet.
+func (mdbc *MongoDbController) GetUserSessions(userId string) ([]dbController.SessionDocument, error) {
+	collection, backCtx, cancel := mdbc.getCollection("sessions")
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, mdbErr := collection.Find(backCtx, bson.D{{Key: "userId", Value: userId}}, opts)
+	if mdbErr != nil {
+		return nil, dbController.NewDBError(mdbErr.Error())
+	}
+
+	sessions := make([]dbController.SessionDocument, 0)
+	if cursorErr := cursor.All(backCtx, &sessions); cursorErr != nil {
+		return nil, dbController.NewDBError(cursorErr.Error())
+	}
+
+	return sessions, nil
+}
+
+func (mdbc *MongoDbController) UpdateSessionLastSeen(sessionId string, lastSeen int64) error {
+	return mdbc.setSessionValue(sessionId, "lastSeenAt", lastSeen)
+}
+
+// RevokeSession returns a NoResultsError if no session has the id
+func (mdbc *MongoDbController) RevokeSession(sessionId string, revokedAt int64) error {
+	return mdbc.setSessionValue(sessionId, "revokedAt", revokedAt)
+}
+
+// RevokeUserSessions revokes all of a user's sessions that aren't revoked yet.
+// A user without sessions isn't an error.
+func (mdbc *MongoDbController) RevokeUserSessions(userId string, revokedAt int64) error {
+	collection, backCtx, cancel := mdbc.getCollection("sessions")
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "userId", Value: userId},
+		{Key: "revokedAt", Value: 0},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revokedAt", Value: revokedAt}}}}
+
+	_, mdbErr := collection.UpdateMany(backCtx, filter, update)
 
 	if mdbErr != nil {
 		return dbController.NewDBError(mdbErr.Error())
@@ -588,6 +1299,128 @@ func (mdbc *MongoDbController) AddInfoLog(log *authUtils.InfoLogData) error {
 	return nil
 }
 
+func (mdbc *MongoDbController) setSessionValue(sessionId string, key string, value int64) error {
+	collection, backCtx, cancel := mdbc.getCollection("sessions")
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: sessionId}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: key, Value: value}}}}
+
+	result, mdbErr := collection.UpdateOne(backCtx, filter, update)
+
+	if mdbErr != nil {
+		return dbController.NewDBError(mdbErr.Error())
+	}
+
+	if result.MatchedCount == 0 {
+		return dbController.NewNoResultsError("Id did not match any sessions")
+	}
+
+	return nil
+}
+
+// RemoveExpiredSessions removes sessions that expired before now
+func (mdbc *MongoDbController) RemoveExpiredSessions(now int64) error {
+	collection, backCtx, cancel := mdbc.getCollection("sessions")
+	defer cancel()
+
+	_, mdbErr := collection.DeleteMany(backCtx, bson.D{
+		{Key: "expiresAt", Value: bson.M{"$lt": now}},
+	})
+
+	if mdbErr != nil {
+		return dbController.NewDBError(mdbErr.Error())
+	}
+
+	return nil
+}
+
+/****************************************************************************************
+* Audit Events
+****************************************************************************************/
+
+func (mdbc *MongoDbController) AddAuditEvent(event *authUtils.AuditEvent) error {
+	collection, backCtx, cancel := mdbc.getCollection("audit")
+	defer cancel()
+
+	_, mdbErr := collection.InsertOne(backCtx, event)
+
+	if mdbErr != nil {
+		return dbController.NewDBError(mdbErr.Error())
+	}
+
+	return nil
+}
+
+// GetAuditEvents returns the audit events matching the filter, newest first.
+// Events are sorted by their place in the audit chain.
+func (mdbc *MongoDbController) GetAuditEvents(filter dbController.AuditEventFilter) ([]authUtils.AuditEvent, error) {
+	collection, backCtx, cancel := mdbc.getCollection("audit")
+	defer cancel()
+
+	query := bson.D{}
+	if len(filter.ActorId) > 0 {
+		query = append(query, bson.E{Key: "actorId", Value: filter.ActorId})
+	}
+	if len(filter.TargetId) > 0 {
+		query = append(query, bson.E{Key: "targetId", Value: filter.TargetId})
+	}
+	if len(filter.Action) > 0 {
+		query = append(query, bson.E{Key: "action", Value: filter.Action})
+	}
+	if len(filter.Outcome) > 0 {
+		query = append(query, bson.E{Key: "outcome", Value: filter.Outcome})
+	}
+
+	timestamp := bson.M{}
+	if !filter.Since.IsZero() {
+		timestamp["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		timestamp["$lte"] = filter.Until
+	}
+	if len(timestamp) > 0 {
+		query = append(query, bson.E{Key: "timestamp", Value: timestamp})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, mdbErr := collection.Find(backCtx, query, opts)
+	if mdbErr != nil {
+		return nil, dbController.NewDBError(mdbErr.Error())
+	}
+
+	events := make([]authUtils.AuditEvent, 0)
+	if cursorErr := cursor.All(backCtx, &events); cursorErr != nil {
+		return nil, dbController.NewDBError(cursorErr.Error())
+	}
+
+	return events, nil
+}
+
+// GetAllAuditEvents returns every audit event in the order of the audit chain.
+// It's used to verify the chain.
+func (mdbc *MongoDbController) GetAllAuditEvents() ([]authUtils.AuditEvent, error) {
+	collection := mdbc.MongoClient.Database(mdbc.dbName).Collection("audit")
+
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}})
+
+	cursor, mdbErr := collection.Find(context.TODO(), bson.D{}, opts)
+	if mdbErr != nil {
+		return nil, dbController.NewDBError(mdbErr.Error())
+	}
+
+	events := make([]authUtils.AuditEvent, 0)
+	if cursorErr := cursor.All(context.TODO(), &events); cursorErr != nil {
+		return nil, dbController.NewDBError(cursorErr.Error())
+	}
+
+	return events, nil
+}
+
 // setupMongoDbClient constructs a MongoDB connection URL based on environment
 // variables and attempts to connect to the URL. The resulting mongo.Client
 // object is returned, and an error is returned.
@@ -623,5 +1456,8 @@ func MakeMongoDbController(dbName string) (MongoDbController, error) {
 		return MongoDbController{}, clientErr
 	}
 
-	return MongoDbController{client, dbName}, nil
+	// The environment variables are checked on startup, so the error is ignored
+	logRetention, _ := dbController.ParseLogRetention(os.Getenv(constants.LOG_RETENTION))
+
+	return MongoDbController{client, dbName, logRetention}, nil
 }
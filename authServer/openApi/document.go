@@ -0,0 +1,226 @@
+// Package openApi describes the service's HTTP API as an OpenAPI 3 document and
+// validates requests against it. Request body schemas are generated from the Go
+// structs that the routes bind, so the document can't drift from the code.
+package openApi
+
+import (
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// A PathItem maps lowercase HTTP methods to operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	Responses       map[string]*Response      `json:"responses,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+func NewDocument(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			Responses:       make(map[string]*Response),
+			SecuritySchemes: make(map[string]SecurityScheme),
+		},
+	}
+}
+
+/****************************************************************************************
+* Paths
+****************************************************************************************/
+
+// ToOpenAPIPath converts a Gin route path to an OpenAPI path, e.g.
+// "/users/:id" becomes "/users/{id}"
+func ToOpenAPIPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// pathParameters returns the names of the parameters in a Gin route path
+func pathParameters(ginPath string) []string {
+	names := make([]string, 0)
+
+	for _, segment := range strings.Split(ginPath, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+		}
+	}
+
+	return names
+}
+
+// AddOperation adds an operation for a method and a Gin route path. Path
+// parameters that the operation doesn't describe are added as strings.
+func (d *Document) AddOperation(method string, ginPath string, op *Operation) {
+	for _, name := range pathParameters(ginPath) {
+		if !op.hasParameter(name, "path") {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	path := ToOpenAPIPath(ginPath)
+	if _, ok := d.Paths[path]; !ok {
+		d.Paths[path] = make(PathItem)
+	}
+
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// Operation returns the operation for a method and a Gin route path
+func (d *Document) Operation(method string, ginPath string) (*Operation, bool) {
+	item, ok := d.Paths[ToOpenAPIPath(ginPath)]
+	if !ok {
+		return nil, false
+	}
+
+	op, ok := item[strings.ToLower(method)]
+
+	return op, ok
+}
+
+// Operations calls fn for every operation in the document. The method is
+// uppercase, e.g. http.MethodGet, and the path is an OpenAPI path.
+func (d *Document) Operations(fn func(method string, path string, op *Operation)) {
+	for path, item := range d.Paths {
+		for method, op := range item {
+			fn(strings.ToUpper(method), path, op)
+		}
+	}
+}
+
+func (op *Operation) hasParameter(name string, in string) bool {
+	for _, p := range op.Parameters {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+
+	return false
+}
+
+/****************************************************************************************
+* Components
+****************************************************************************************/
+
+// AddSchema generates a schema for the type of v, stores it in the document's
+// components and returns a reference to it.
+func (d *Document) AddSchema(name string, v interface{}) *Schema {
+	d.Components.Schemas[name] = SchemaFor(v)
+
+	return Ref(name)
+}
+
+// Ref returns a reference to a schema in the document's components
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Resolve follows a schema reference. Schemas that aren't references are
+// returned as is. Unknown references return nil.
+func (d *Document) Resolve(schema *Schema) *Schema {
+	for schema != nil && len(schema.Ref) > 0 {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+
+	return schema
+}
+
+// JSONBody is a shortcut for a required JSON request body
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// JSONResponse is a shortcut for a response with a JSON body. A nil schema
+// returns a response without a body.
+func JSONResponse(description string, schema *Schema) *Response {
+	if schema == nil {
+		return &Response{Description: description}
+	}
+
+	return &Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// StatusCode formats a status code as a response key
+func StatusCode(status int) string {
+	if status == 0 {
+		return "default"
+	}
+
+	return strconv.Itoa(status)
+}
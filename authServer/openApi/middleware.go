@@ -0,0 +1,61 @@
+package openApi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+)
+
+// ValidateRequests returns middleware that validates JSON request bodies
+// against the document before they reach the route. Invalid bodies are rejected
+// with an invalid_request_body problem that names the invalid value. Routes
+// that aren't in the document and bodies that aren't JSON, e.g. forms, are
+// passed through unchanged.
+//
+// The middleware must run before the route's handler. On authenticated routes,
+// it should run after the authentication middleware.
+func ValidateRequests(doc *Document) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		op, ok := doc.Operation(ctx.Request.Method, ctx.FullPath())
+		if !ok || op.RequestBody == nil {
+			return
+		}
+
+		// The routes bind JSON regardless of the content type, so bodies are
+		// treated as JSON unless the operation describes their content type.
+		if contentType := ctx.ContentType(); contentType != "application/json" {
+			if _, described := op.RequestBody.Content[contentType]; described {
+				return
+			}
+		}
+
+		media, ok := op.RequestBody.Content["application/json"]
+		if !ok || media.Schema == nil {
+			return
+		}
+
+		bodyBytes, readErr := ioutil.ReadAll(ctx.Request.Body)
+		if readErr != nil {
+			apiErrors.WriteCode(ctx, apiErrors.InvalidRequestBody, "the request body could not be read")
+			return
+		}
+
+		// The route reads the body again
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+
+		var body interface{}
+		if jsonErr := json.Unmarshal(bodyBytes, &body); jsonErr != nil {
+			apiErrors.WriteCode(ctx, apiErrors.InvalidRequestBody, "the request body is not valid JSON")
+			return
+		}
+
+		if validationErr := doc.Validate(media.Schema, body); validationErr != nil {
+			apiErrors.WriteCode(ctx, apiErrors.InvalidRequestBody, validationErr.Error())
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package openApi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI schema object used by this service
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	MaxLength            int                `json:"maxLength,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaFor generates a schema from the type of v. Struct fields use their json
+// tag names. Fields with a binding:"required" tag are required and, if they are
+// strings, can't be empty, matching Gin's validation. Pointer fields are
+// nullable. Fields tagged json:"-" are skipped.
+func SchemaFor(v interface{}) *Schema {
+	return schemaForType(reflect.TypeOf(v))
+}
+
+func schemaForType(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaForType(t.Elem())
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaForType(t.Elem())}
+	case reflect.Map:
+		schema := &Schema{Type: "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema.AdditionalProperties = schemaForType(t.Elem())
+		}
+		return schema
+	case reflect.Struct:
+		return schemaForStruct(t)
+	}
+
+	// Interfaces accept any value
+	return &Schema{}
+}
+
+func schemaForStruct(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a json name are flattened, like encoding/json
+		if field.Anonymous && len(name) == 0 && field.Type.Kind() == reflect.Struct {
+			embedded := schemaForStruct(field.Type)
+			for propName, prop := range embedded.Properties {
+				schema.Properties[propName] = prop
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		prop := schemaForType(field.Type)
+
+		if isRequired(field) {
+			schema.Required = append(schema.Required, name)
+			if prop.Type == "string" {
+				prop.MinLength = 1
+			}
+		}
+
+		schema.Properties[name] = prop
+	}
+
+	return schema
+}
+
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+package openApi
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+/****************************************************************************************
+* ValidationError
+****************************************************************************************/
+
+// Used for when a value doesn't match its schema
+type ValidationError struct{ ErrMsg string }
+
+func (err ValidationError) Error() string { return err.ErrMsg }
+func NewValidationError(msg string) error { return ValidationError{msg} }
+
+/****************************************************************************************
+* Validation
+****************************************************************************************/
+
+// Validate checks a value decoded by encoding/json against a schema. The error
+// names the first value that doesn't match, e.g. "username is required".
+func (d *Document) Validate(schema *Schema, value interface{}) error {
+	return d.validate(schema, value, "body")
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, path string) error {
+	schema = d.Resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if value == nil {
+		if schema.Nullable || len(schema.Type) == 0 {
+			return nil
+		}
+		return NewValidationError(fmt.Sprintf("%s must not be null", path))
+	}
+
+	switch schema.Type {
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(path, "a boolean")
+		}
+	case "integer":
+		num, ok := value.(float64)
+		if !ok || num != math.Trunc(num) {
+			return typeError(path, "an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return typeError(path, "a number")
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return typeError(path, "a string")
+		}
+		return validateString(schema, str, path)
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return typeError(path, "an array")
+		}
+		for i, item := range arr {
+			if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(path, "an object")
+		}
+		return d.validateObject(schema, obj, path)
+	}
+
+	return nil
+}
+
+func (d *Document) validateObject(schema *Schema, obj map[string]interface{}, path string) error {
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			return NewValidationError(fmt.Sprintf("%s is required", childPath(path, name)))
+		}
+	}
+
+	for name, value := range obj {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			prop = schema.AdditionalProperties
+		}
+
+		if err := d.validate(prop, value, childPath(path, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateString(schema *Schema, str string, path string) error {
+	length := utf8.RuneCountInString(str)
+
+	if length < schema.MinLength {
+		if schema.MinLength == 1 {
+			return NewValidationError(fmt.Sprintf("%s must not be empty", path))
+		}
+		return NewValidationError(fmt.Sprintf("%s must be at least %d characters", path, schema.MinLength))
+	}
+
+	if schema.MaxLength > 0 && length > schema.MaxLength {
+		return NewValidationError(fmt.Sprintf("%s must be at most %d characters", path, schema.MaxLength))
+	}
+
+	if len(schema.Enum) > 0 {
+		for _, value := range schema.Enum {
+			if value == str {
+				return nil
+			}
+		}
+		return NewValidationError(fmt.Sprintf("%s is not an allowed value", path))
+	}
+
+	return nil
+}
+
+// childPath omits the "body" prefix for top level properties
+func childPath(path string, name string) string {
+	if path == "body" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+func typeError(path string, expected string) error {
+	return NewValidationError(fmt.Sprintf("%s must be %s", path, expected))
+}
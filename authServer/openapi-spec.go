@@ -0,0 +1,420 @@
+package authServer
+
+import (
+	"fmt"
+	"net/http"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/openApi"
+)
+
+type NonceResponse struct {
+	Nonce string `json:"nonce"`
+}
+
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+// OpenAPISpec returns the OpenAPI document describing every route. Request
+// body schemas are generated from the structs in types.go. Every route must be
+// described here; the tests fail if a route and the document disagree.
+func OpenAPISpec() *openApi.Document {
+	doc := openApi.NewDocument(openApi.Info{
+		Title:       "Auth Microservice",
+		Description: "Authentication and user management. Errors are returned as RFC 7807 problem details, see /problems.",
+		Version:     "1.0.0",
+	})
+
+	doc.Components.SecuritySchemes["bearerAuth"] = openApi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+	}
+	doc.Components.SecuritySchemes["basicAuth"] = openApi.SecurityScheme{Type: "http", Scheme: "basic"}
+	bearerAuth := []map[string][]string{{"bearerAuth": {}}}
+
+	problem := &openApi.Response{
+		Description: "Error",
+		Content: map[string]openApi.MediaType{
+			apiErrors.ContentType: {Schema: doc.AddSchema("Problem", apiErrors.Problem{})},
+		},
+	}
+	ok := openApi.JSONResponse("Success", nil)
+
+	doc.AddSchema("LoginBody", LoginBody{})
+	doc.AddSchema("AddUserBody", AddUserBody{})
+	doc.AddSchema("EditUserBody", EditUserBody{})
+	doc.AddSchema("EditPasswordBody", EditPasswordBody{})
+	doc.AddSchema("IntrospectBody", IntrospectBody{})
+	doc.AddSchema("PatchUserBody", PatchUserBody{})
+	doc.AddSchema("SetPasswordBody", SetPasswordBody{})
+	doc.AddSchema("UserResponse", UserResponse{})
+	doc.AddSchema("PatchMeBody", PatchMeBody{})
+	doc.AddSchema("MeResponse", MeResponse{})
+	doc.AddSchema("SessionResponse", SessionResponse{})
+	addUserAttributeSchemas(doc)
+
+	health := doc.AddSchema("HealthResponse", HealthResponse{})
+
+	doc.AddOperation(http.MethodGet, "/", &openApi.Operation{
+		OperationID: "getHome",
+		Summary:     "Reports that the service is alive. Same as /healthz.",
+		Responses:   map[string]*openApi.Response{"200": openApi.JSONResponse("The service is alive", health)},
+	})
+
+	doc.AddOperation(http.MethodGet, "/healthz", &openApi.Operation{
+		OperationID: "getHealth",
+		Summary:     "Reports that the service is alive. Doesn't check any dependency.",
+		Responses:   map[string]*openApi.Response{"200": openApi.JSONResponse("The service is alive", health)},
+	})
+
+	doc.AddOperation(http.MethodGet, "/readyz", &openApi.Operation{
+		OperationID: "getReadiness",
+		Summary:     "Checks the database, the signing keys and the loggers and reports whether the service can handle requests",
+		Responses: map[string]*openApi.Response{
+			"200": openApi.JSONResponse("Every check passed", health),
+			"503": openApi.JSONResponse("At least one check failed", health),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/nonce", &openApi.Operation{
+		OperationID: "getNonce",
+		Summary:     "Returns a nonce for the client's address. Every POST request except /introspect requires one.",
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("A new nonce", doc.AddSchema("NonceResponse", NonceResponse{})),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/public-key", &openApi.Operation{
+		OperationID: "getPublicKey",
+		Summary:     "Returns the PEM encoded RSA public key used to verify tokens",
+		Responses: map[string]*openApi.Response{
+			"200": {
+				Description: "The public key",
+				Content:     map[string]openApi.MediaType{"text/plain": {Schema: &openApi.Schema{Type: "string"}}},
+			},
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/problems", &openApi.Operation{
+		OperationID: "getProblems",
+		Summary:     "Returns the catalog of error codes",
+		Responses: map[string]*openApi.Response{
+			"200": openApi.JSONResponse("The catalog, keyed by code", &openApi.Schema{
+				Type:                 "object",
+				AdditionalProperties: doc.AddSchema("CatalogEntry", apiErrors.CatalogEntry{}),
+			}),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/problems/:code", &openApi.Operation{
+		OperationID: "getProblem",
+		Summary:     "Describes an error code",
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("The error code", openApi.Ref("CatalogEntry")),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/openapi.json", &openApi.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "Returns this document",
+		Responses:   map[string]*openApi.Response{"200": openApi.JSONResponse("The OpenAPI document", &openApi.Schema{Type: "object"})},
+	})
+
+	doc.AddOperation(http.MethodGet, "/metrics", &openApi.Operation{
+		OperationID: "getMetrics",
+		Summary:     "Returns the service's metrics in the Prometheus exposition format",
+		Responses: map[string]*openApi.Response{
+			"200": {
+				Description: "The metrics",
+				Content:     map[string]openApi.MediaType{"text/plain": {Schema: &openApi.Schema{Type: "string"}}},
+			},
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/login", &openApi.Operation{
+		OperationID: "login",
+		Summary:     "Exchanges a username and password for a token",
+		RequestBody: openApi.JSONBody(openApi.Ref("LoginBody")),
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("A signed JWT", doc.AddSchema("TokenResponse", TokenResponse{})),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/logout", &openApi.Operation{
+		OperationID: "logout",
+		Summary:     "Removes the token cookies set by /login",
+		Responses:   map[string]*openApi.Response{"200": ok},
+	})
+
+	doc.AddOperation(http.MethodPost, "/add-user", &openApi.Operation{
+		OperationID: "addUser",
+		Summary:     "Adds a user. Requires an admin token.",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(openApi.Ref("AddUserBody")),
+		Responses:   map[string]*openApi.Response{"200": ok, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodPost, "/edit-user", &openApi.Operation{
+		OperationID: "editUser",
+		Summary:     "Edits a user. Admins can edit any user, other users can only edit themselves.",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(openApi.Ref("EditUserBody")),
+		Responses:   map[string]*openApi.Response{"200": ok, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodPost, "/edit-user-password", &openApi.Operation{
+		OperationID: "editUserPassword",
+		Summary:     "Changes a user's password. Non-admins must send their old password.",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(openApi.Ref("EditPasswordBody")),
+		Responses:   map[string]*openApi.Response{"200": ok, "default": problem},
+	})
+
+	introspectBody := openApi.JSONBody(openApi.Ref("IntrospectBody"))
+	introspectBody.Content["application/x-www-form-urlencoded"] = openApi.MediaType{Schema: openApi.Ref("IntrospectBody")}
+
+	doc.AddOperation(http.MethodPost, "/introspect", &openApi.Operation{
+		OperationID: "introspect",
+		Summary:     "RFC 7662 token introspection. Requires client credentials (HTTP Basic) or an admin token.",
+		Security:    append(bearerAuth, map[string][]string{"basicAuth": {}}),
+		RequestBody: introspectBody,
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("The token's claims and whether it's active", &openApi.Schema{Type: "object"}),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/me", &openApi.Operation{
+		OperationID: "getMe",
+		Summary:     "Returns the caller's user data and metadata about their token",
+		Security:    bearerAuth,
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("The caller", openApi.Ref("MeResponse")),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodPatch, "/me", &openApi.Operation{
+		OperationID: "updateMe",
+		Summary:     "Edits the caller's profile and returns the result",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(openApi.Ref("PatchMeBody")),
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("The caller", openApi.Ref("MeResponse")),
+			"default": problem,
+		},
+	})
+
+	addSessionOperations(doc, problem, bearerAuth)
+	addAuditOperations(doc, problem, bearerAuth)
+	addV1Operations(doc, problem, bearerAuth)
+
+	return doc
+}
+
+// addSessionOperations describes the session routes
+func addSessionOperations(doc *openApi.Document, problem *openApi.Response, bearerAuth []map[string][]string) {
+	sessions := openApi.JSONResponse("The active sessions", &openApi.Schema{Type: "array", Items: openApi.Ref("SessionResponse")})
+	noContent := openApi.JSONResponse("Success", nil)
+	nonce := []openApi.Parameter{{
+		Name:     "nonce",
+		In:       "query",
+		Required: true,
+		Schema:   &openApi.Schema{Type: "string", MinLength: 1},
+	}}
+
+	doc.AddOperation(http.MethodGet, "/sessions", &openApi.Operation{
+		OperationID: "getSessions",
+		Summary:     "Lists the caller's active sessions",
+		Security:    bearerAuth,
+		Responses:   map[string]*openApi.Response{"200": sessions, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodDelete, "/sessions/:sessionId", &openApi.Operation{
+		OperationID: "revokeSession",
+		Summary:     "Revokes one of the caller's sessions. Tokens issued for the session are rejected afterward.",
+		Security:    bearerAuth,
+		Parameters:  nonce,
+		Responses:   map[string]*openApi.Response{"204": noContent, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodGet, "/users/:id/sessions", &openApi.Operation{
+		OperationID: "getUserSessions",
+		Summary:     "Lists a user's active sessions. Requires an admin token.",
+		Security:    bearerAuth,
+		Responses:   map[string]*openApi.Response{"200": sessions, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodDelete, "/users/:id/sessions/:sessionId", &openApi.Operation{
+		OperationID: "revokeUserSession",
+		Summary:     "Revokes one of a user's sessions. Requires an admin token.",
+		Security:    bearerAuth,
+		Parameters:  nonce,
+		Responses:   map[string]*openApi.Response{"204": noContent, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodPost, "/users/:id/impersonate", &openApi.Operation{
+		OperationID: "impersonateUser",
+		Summary:     "Returns a short lived token for acting as a user. Requires an admin token. The token can't change passwords or perform admin operations.",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(doc.AddSchema("ImpersonateBody", ImpersonateBody{})),
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("A signed JWT", openApi.Ref("TokenResponse")),
+			"default": problem,
+		},
+	})
+}
+
+// addAuditOperations describes the audit log and log routes
+func addAuditOperations(doc *openApi.Document, problem *openApi.Response, bearerAuth []map[string][]string) {
+	filter := func(name string, description string, schema *openApi.Schema) openApi.Parameter {
+		return openApi.Parameter{Name: name, In: "query", Description: description, Schema: schema}
+	}
+	str := &openApi.Schema{Type: "string"}
+	dateTime := &openApi.Schema{Type: "string", Format: "date-time"}
+
+	doc.AddOperation(http.MethodGet, "/audit", &openApi.Operation{
+		OperationID: "getAuditEvents",
+		Summary:     "Lists audit events, newest first. Requires an admin token.",
+		Security:    bearerAuth,
+		Parameters: []openApi.Parameter{
+			filter("actor", "The id of the user that performed the action", str),
+			filter("target", "The id of the user the action was performed on", str),
+			filter("action", "The action", &openApi.Schema{Type: "string", Enum: []string{
+				authUtils.AuditLogin,
+				authUtils.AuditAddUser,
+				authUtils.AuditEditUser,
+				authUtils.AuditEditPassword,
+				authUtils.AuditImpersonate,
+				authUtils.AuditDeleteUser,
+				authUtils.AuditRevokeSession,
+			}}),
+			filter("outcome", "The outcome", &openApi.Schema{Type: "string", Enum: []string{authUtils.AuditSuccess, authUtils.AuditFailure}}),
+			filter("since", "The earliest time to return", dateTime),
+			filter("until", "The latest time to return", dateTime),
+			filter("limit", "The number of events to return. Defaults to 100, with a maximum of 1000", &openApi.Schema{Type: "integer"}),
+		},
+		Responses: map[string]*openApi.Response{
+			"200": openApi.JSONResponse("The audit events", &openApi.Schema{
+				Type:  "array",
+				Items: doc.AddSchema("AuditEvent", authUtils.AuditEvent{}),
+			}),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/logs", &openApi.Operation{
+		OperationID: "getLogs",
+		Summary:     "Lists the logs stored in the database, newest first. Requires an admin token.",
+		Security:    bearerAuth,
+		Parameters: []openApi.Parameter{
+			filter("type", "The log type, e.g. request, log or audit", str),
+			filter("since", "The earliest time to return", dateTime),
+			filter("until", "The latest time to return", dateTime),
+			filter("status", "The status code of request logs", &openApi.Schema{Type: "integer"}),
+			filter("path", "The path of request logs", str),
+			filter("ip", "The client IP address of request logs", str),
+			filter("offset", "The number of matching logs to skip", &openApi.Schema{Type: "integer"}),
+			filter("limit", "The number of logs to return. Defaults to 100, with a maximum of 1000", &openApi.Schema{Type: "integer"}),
+		},
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("A page of logs", doc.AddSchema("LogsResponse", LogsResponse{})),
+			"default": problem,
+		},
+	})
+}
+
+// addV1Operations describes the routes set by setV1Routes
+func addV1Operations(doc *openApi.Document, problem *openApi.Response, bearerAuth []map[string][]string) {
+	noContent := openApi.JSONResponse("Success", nil)
+	user := openApi.JSONResponse("The user", openApi.Ref("UserResponse"))
+
+	doc.AddOperation(http.MethodGet, "/v1/nonces", &openApi.Operation{
+		OperationID: "v1CreateNonce",
+		Summary:     "Returns a nonce for the client's address. Every mutating request requires one.",
+		Responses: map[string]*openApi.Response{
+			"200":     openApi.JSONResponse("A new nonce", openApi.Ref("NonceResponse")),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/v1/sessions", &openApi.Operation{
+		OperationID: "v1CreateSession",
+		Summary:     "Exchanges a username and password for a token",
+		RequestBody: openApi.JSONBody(openApi.Ref("LoginBody")),
+		Responses: map[string]*openApi.Response{
+			"201":     openApi.JSONResponse("A signed JWT", openApi.Ref("TokenResponse")),
+			"default": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/v1/users", &openApi.Operation{
+		OperationID: "v1CreateUser",
+		Summary:     "Adds a user. Requires an admin token.",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(openApi.Ref("AddUserBody")),
+		Responses:   map[string]*openApi.Response{"201": noContent, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodGet, "/v1/users/:id", &openApi.Operation{
+		OperationID: "v1GetUser",
+		Summary:     "Returns a user. Admins can get any user, other users can only get themselves.",
+		Security:    bearerAuth,
+		Responses:   map[string]*openApi.Response{"200": user, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodPatch, "/v1/users/:id", &openApi.Operation{
+		OperationID: "v1UpdateUser",
+		Summary:     "Edits a user and returns the result. Admins can edit any user, other users can only edit themselves.",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(openApi.Ref("PatchUserBody")),
+		Responses:   map[string]*openApi.Response{"200": user, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodDelete, "/v1/users/:id", &openApi.Operation{
+		OperationID: "v1DeleteUser",
+		Summary:     "Deletes a user. Requires an admin token. Admins can't delete themselves.",
+		Security:    bearerAuth,
+		Parameters: []openApi.Parameter{{
+			Name:     "nonce",
+			In:       "query",
+			Required: true,
+			Schema:   &openApi.Schema{Type: "string", MinLength: 1},
+		}},
+		Responses: map[string]*openApi.Response{"204": noContent, "default": problem},
+	})
+
+	doc.AddOperation(http.MethodPut, "/v1/users/:id/password", &openApi.Operation{
+		OperationID: "v1SetUserPassword",
+		Summary:     "Changes a user's password. Non-admins must send their old password.",
+		Security:    bearerAuth,
+		RequestBody: openApi.JSONBody(openApi.Ref("SetPasswordBody")),
+		Responses:   map[string]*openApi.Response{"204": noContent, "default": problem},
+	})
+}
+
+// addUserAttributeSchemas describes the allowed user attributes, which are a
+// free-form map in the body structs. The limits match ValidateUserAttributes.
+func addUserAttributeSchemas(doc *openApi.Document) {
+	attributes := func(nullable bool) *openApi.Schema {
+		return &openApi.Schema{
+			Type: "object",
+			Properties: map[string]*openApi.Schema{
+				"displayName": {Type: "string", MaxLength: maxDisplayNameLength, Nullable: nullable},
+				"locale":      {Type: "string", Description: "A BCP 47 language tag", Nullable: nullable},
+				"metadata":    {Type: "object", Description: fmt.Sprintf("Free-form data, at most %d bytes of JSON", maxMetadataSize), Nullable: nullable},
+			},
+		}
+	}
+
+	doc.Components.Schemas["AddUserBody"].Properties["attributes"] = attributes(false)
+	doc.Components.Schemas["EditUserBody"].Properties["attributes"] = attributes(true)
+	doc.Components.Schemas["PatchUserBody"].Properties["attributes"] = attributes(true)
+	doc.Components.Schemas["PatchMeBody"].Properties["attributes"] = attributes(true)
+}
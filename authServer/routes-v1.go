@@ -0,0 +1,108 @@
+package authServer
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+)
+
+// setV1Routes sets the routes of the versioned API. The legacy routes in
+// routes.go share their implementation with these routes, so both keep working
+// as the API evolves. Breaking changes belong in a new version. Like in
+// SetRoutes, request bodies are validated after the authentication middleware.
+func (as *AuthServer) setV1Routes(requireAuth gin.HandlerFunc, requireAdmin gin.HandlerFunc, validate gin.HandlerFunc) {
+	v1 := as.GinEngine.Group("/v1")
+
+	v1.GET("/nonces", as.getNonceRoute)
+	v1.POST("/sessions", validate, as.postSessionRoute)
+
+	v1.POST("/users", requireAuth, requireAdmin, validate, as.postUserRoute)
+	v1.GET("/users/:id", requireAuth, as.getUserRoute)
+	v1.PATCH("/users/:id", requireAuth, validate, as.patchUserRoute)
+	v1.DELETE("/users/:id", requireAuth, requireAdmin, as.deleteUserRoute)
+	v1.PUT("/users/:id/password", requireAuth, validate, as.putUserPasswordRoute)
+}
+
+/****************************************************************************************
+* V1 Route Functions
+****************************************************************************************/
+
+// postSessionRoute is the POST /v1/sessions route. It's the same as /login,
+// except that it responds with 201.
+func (as *AuthServer) postSessionRoute(ctx *gin.Context) {
+	as.createSession(ctx, 201)
+}
+
+// postUserRoute is the POST /v1/users route. Only admins can add users.
+func (as *AuthServer) postUserRoute(ctx *gin.Context) {
+	if as.addUser(ctx) {
+		ctx.Status(201)
+	}
+}
+
+// getUserRoute is the GET /v1/users/:id route. Admins can get any user, other
+// users can only get themselves.
+func (as *AuthServer) getUserRoute(ctx *gin.Context) {
+	as.respondWithUser(ctx, ctx.Param("id"))
+}
+
+// patchUserRoute is the PATCH /v1/users/:id route. It responds with the edited
+// user.
+func (as *AuthServer) patchUserRoute(ctx *gin.Context) {
+	var body PatchUserBody
+	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
+		respondWithInvalidBody(ctx)
+		return
+	}
+
+	editBody := body.EditUserBody(ctx.Param("id"))
+	if as.editUser(ctx, &editBody) {
+		as.respondWithUser(ctx, editBody.Id)
+	}
+}
+
+// putUserPasswordRoute is the PUT /v1/users/:id/password route
+func (as *AuthServer) putUserPasswordRoute(ctx *gin.Context) {
+	var body SetPasswordBody
+	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
+		respondWithInvalidBody(ctx)
+		return
+	}
+
+	passwordBody := body.EditPasswordBody(ctx.Param("id"))
+	if as.editUserPassword(ctx, &passwordBody) {
+		ctx.Status(204)
+	}
+}
+
+// deleteUserRoute is the DELETE /v1/users/:id route. Only admins can delete
+// users. The nonce is passed in the query string.
+func (as *AuthServer) deleteUserRoute(ctx *gin.Context) {
+	var query NonceQuery
+	if bindErr := ctx.ShouldBindQuery(&query); bindErr != nil {
+		respondWithInvalidBody(ctx)
+		return
+	}
+
+	claims, _ := authMiddleware.GetClaims(ctx)
+
+	deleteErr := as.AuthController.DeleteUser(ctx.Param("id"), query.Nonce, claims, ctx)
+	if deleteErr != nil {
+		respondWithError(ctx, deleteErr)
+		return
+	}
+
+	ctx.Status(204)
+}
+
+func (as *AuthServer) respondWithUser(ctx *gin.Context, id string) {
+	claims, _ := authMiddleware.GetClaims(ctx)
+
+	userDoc, userErr := as.AuthController.GetUser(id, claims, ctx)
+	if userErr != nil {
+		respondWithError(ctx, userErr)
+		return
+	}
+
+	ctx.JSON(200, NewUserResponse(userDoc))
+}
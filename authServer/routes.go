@@ -1,34 +1,94 @@
 package authServer
 
 import (
-	"net/http"
 	"os"
 
 	"github.com/gin-gonic/gin"
 
+	"methompson.com/auth-microservice/authServer/apiErrors"
 	"methompson.com/auth-microservice/authServer/authCrypto"
-	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+	"methompson.com/auth-microservice/authServer/authTLS"
 	"methompson.com/auth-microservice/authServer/constants"
-	"methompson.com/auth-microservice/authServer/dbController"
+	"methompson.com/auth-microservice/authServer/openApi"
 )
 
-// setRoutes sets all of the routes for the Gin Server
-func (as *AuthServer) setRoutes() {
+// SetRoutes sets all of the routes for the Gin Server. Every route must also be
+// described in OpenAPISpec. Request bodies are validated against the spec
+// before they reach the routes. On authenticated routes, they're validated after
+// the authentication middleware, so that unauthenticated callers don't learn
+// anything about the bodies.
+func (as *AuthServer) SetRoutes() {
+	as.apiSpec = OpenAPISpec()
+	validate := openApi.ValidateRequests(as.apiSpec)
+
 	as.GinEngine.GET("/", as.getHomeRoute)
+	as.GinEngine.GET("/healthz", as.getHealthRoute)
+	as.GinEngine.GET("/readyz", as.getReadinessRoute)
 	as.GinEngine.GET("/nonce", as.getNonceRoute)
 	as.GinEngine.GET("/public-key", as.getPublicKeyRoute)
+	as.GinEngine.GET("/problems", as.getProblemsRoute)
+	as.GinEngine.GET("/problems/:code", as.getProblemRoute)
+	as.GinEngine.GET("/openapi.json", as.getOpenAPIRoute)
+	as.GinEngine.GET("/metrics", as.getMetricsRoute)
+
+	requireAuth := as.authenticator().RequireAuth()
+	requireAdmin := as.requireAdmin()
+
+	as.GinEngine.POST("/login", validate, as.postLoginRoute)
+	as.GinEngine.POST("/logout", as.postLogoutRoute)
+	as.GinEngine.POST("/add-user", requireAuth, requireAdmin, validate, as.postAddUserRoute)
+	as.GinEngine.POST("/edit-user", requireAuth, validate, as.postEditUserRoute)
+	as.GinEngine.POST("/edit-user-password", requireAuth, validate, as.postEditUserPasswordRoute)
+	as.GinEngine.POST("/introspect", validate, as.postIntrospectRoute)
+	as.GinEngine.GET("/me", requireAuth, as.getMeRoute)
+	as.GinEngine.PATCH("/me", requireAuth, validate, as.patchMeRoute)
+	as.GinEngine.GET("/sessions", requireAuth, as.getSessionsRoute)
+	as.GinEngine.DELETE("/sessions/:sessionId", requireAuth, as.deleteSessionRoute)
+	as.GinEngine.GET("/users/:id/sessions", requireAuth, requireAdmin, as.getSessionsRoute)
+	as.GinEngine.DELETE("/users/:id/sessions/:sessionId", requireAuth, requireAdmin, as.deleteSessionRoute)
+	as.GinEngine.POST("/users/:id/impersonate", requireAuth, requireAdmin, validate, as.postImpersonateRoute)
+	as.GinEngine.GET("/audit", requireAuth, requireAdmin, as.getAuditRoute)
+	as.GinEngine.GET("/logs", requireAuth, requireAdmin, as.getLogsRoute)
+
+	as.setV1Routes(requireAuth, requireAdmin, validate)
+}
+
+// requireAdmin returns the middleware of the admin routes. With
+// TLS_ADMIN_CLIENT_CERT, admin requests must also present a verified client
+// certificate. The environment variables are checked on startup, so the error is
+// ignored.
+func (as *AuthServer) requireAdmin() gin.HandlerFunc {
+	options, _ := authTLS.GetTLSOptions()
+	if options.RequireAdminClientCert {
+		return authMiddleware.RequireAdminWithClientCert()
+	}
+
+	return authMiddleware.RequireAdmin()
+}
+
+// requireClientCertForUser responds with a client_certificate_required problem if
+// an admin acts on another user's account without a verified client certificate
+// while TLS_ADMIN_CLIENT_CERT is set. Users acting on their own account don't
+// need one. It returns false if it responded. The environment variables are
+// checked on startup, so the error is ignored.
+func requireClientCertForUser(ctx *gin.Context, claims *authCrypto.JWTClaims, userId string) bool {
+	options, _ := authTLS.GetTLSOptions()
+
+	if options.RequireAdminClientCert && claims.Admin && userId != claims.Subject && !authMiddleware.HasClientCert(ctx.Request.TLS) {
+		apiErrors.WriteCode(ctx, apiErrors.ClientCertRequired, "")
+		return false
+	}
 
-	as.GinEngine.POST("/login", as.postLoginRoute)
-	as.GinEngine.POST("/add-user", as.postAddUserRoute)
-	as.GinEngine.POST("/edit-user", as.postEditUserRoute)
-	as.GinEngine.POST("/edit-user-password", as.postEditUserPasswordRoute)
+	return true
 }
 
 /****************************************************************************************
 * Route Functions
 ****************************************************************************************/
+// Reports that the service is alive, like GET /healthz
 func (as *AuthServer) getHomeRoute(ctx *gin.Context) {
-	ctx.Data(200, "text/html; charset=utf-8", make([]byte, 0))
+	as.getHealthRoute(ctx)
 }
 
 // Returns a nonce value.
@@ -36,78 +96,57 @@ func (as *AuthServer) getNonceRoute(ctx *gin.Context) {
 	nonce, err := as.AuthController.GenerateNonce(ctx)
 
 	if err != nil {
-		msg := "Unknown Error"
-		errCode := http.StatusInternalServerError
-
-		switch err.(type) {
-		case dbController.DBError:
-			msg = "Server Error"
-			errCode = http.StatusInternalServerError
-		}
-
-		ctx.JSON(errCode, gin.H{
-			"error": msg,
-		})
+		respondWithError(ctx, err)
 		return
 	}
 
-	ctx.JSON(200, gin.H{
-		"nonce": nonce,
-	})
+	ctx.JSON(200, NonceResponse{Nonce: nonce})
 }
 
 // Takes a user's nonce, username and password and confirms the data on behalf of
 // the user. Returns a JWT that a user can use for authorization purposes.
 // /login
 func (as *AuthServer) postLoginRoute(ctx *gin.Context) {
+	as.createSession(ctx, 200)
+}
+
+// createSession logs a user in and responds with a token using the status
+// code passed. It's shared by POST /login and POST /v1/sessions.
+func (as *AuthServer) createSession(ctx *gin.Context, status int) {
 	var body LoginBody
 
 	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
-		ctx.JSON(
-			http.StatusBadRequest,
-			gin.H{"error": "Invalid Body"},
-			// gin.H{"error": bindJsonErr.Error()},
-		)
+		respondWithInvalidBody(ctx)
 		return
 	}
 
 	token, loginError := as.AuthController.LogUserIn(body, ctx)
 
 	if loginError != nil {
-		var msg string
-		var errCode int
-
-		switch loginError.(type) {
-		case dbController.NoResultsError:
-			msg = "Invalid Username or Password"
-			errCode = http.StatusBadRequest
-		case dbController.DBError:
-			msg = "Server Error"
-			errCode = http.StatusInternalServerError
-		case authUtils.NonceError:
-			msg = "Invalid Nonce"
-			errCode = http.StatusBadRequest
-		case authCrypto.JWTError:
-			msg = "Server Encountered an Error While Generating JWT"
-			errCode = http.StatusInternalServerError
-		case LoginError:
-			msg = "Invalid username or password"
-			errCode = http.StatusUnauthorized
-		default:
-			msg = "Unknown Error"
-			errCode = http.StatusInternalServerError
-		}
-
-		ctx.JSON(
-			errCode,
-			gin.H{"error": msg},
-		)
+		respondWithError(ctx, hideUnknownUser(loginError))
 		return
 	}
 
-	ctx.JSON(200, gin.H{
-		"token": token,
-	})
+	// Browser clients can receive the token in an HttpOnly cookie
+	authenticator := as.authenticator()
+	if authenticator.Options().UsesSource(authMiddleware.CookieSource) {
+		cookieErr := authenticator.SetTokenCookies(ctx, token, int(constants.JWT_EXPIRATION.Seconds()))
+
+		if cookieErr != nil {
+			respondWithError(ctx, cookieErr)
+			return
+		}
+	}
+
+	ctx.JSON(status, TokenResponse{Token: token})
+}
+
+// postLogoutRoute is the POST /logout route. It removes the token cookies set
+// by /login. Tokens sent in a header aren't affected.
+func (as *AuthServer) postLogoutRoute(ctx *gin.Context) {
+	as.authenticator().ClearTokenCookies(ctx)
+
+	ctx.Status(200)
 }
 
 // Prints the RSA Public Key for JWT verification
@@ -115,234 +154,310 @@ func (as *AuthServer) getPublicKeyRoute(ctx *gin.Context) {
 	ctx.String(200, os.Getenv(constants.RSA_PUBLIC_KEY))
 }
 
-// TODO Log all errors
-func (as *AuthServer) postAddUserRoute(ctx *gin.Context) {
-	// Check the user's authorization token.
-	claims, claimsErr := as.ExtractJWTFromHeader(ctx)
-	if claimsErr != nil {
-		var errMsg string
-		var statusCode int
-
-		switch claimsErr.(type) {
-		case authCrypto.ExpiredJWTError:
-			errMsg = "Expired authorization token"
-			statusCode = http.StatusUnauthorized
-		case authCrypto.JWTError:
-			errMsg = "Not authorized"
-			statusCode = http.StatusUnauthorized
-		default:
-			errMsg = "Invalid authorization token"
-			statusCode = http.StatusBadRequest
-		}
+// getProblemsRoute is the GET /problems route. It returns the catalog of error
+// codes used in problem responses.
+func (as *AuthServer) getProblemsRoute(ctx *gin.Context) {
+	ctx.JSON(200, apiErrors.Catalog)
+}
 
-		ctx.JSON(
-			statusCode,
-			gin.H{"error": errMsg},
-		)
+// getProblemRoute is the GET /problems/:code route. It describes a single error
+// code. The type of every problem response points to this route.
+func (as *AuthServer) getProblemRoute(ctx *gin.Context) {
+	entry, ok := apiErrors.Catalog[ctx.Param("code")]
+	if !ok {
+		apiErrors.WriteCode(ctx, apiErrors.NotFound, "unknown error code")
 		return
 	}
 
-	if !claims.Admin {
-		ctx.JSON(
-			http.StatusUnauthorized,
-			gin.H{"error": "Not Authorized"},
-		)
-		return
+	ctx.JSON(200, entry)
+}
+
+// getOpenAPIRoute is the GET /openapi.json route. It returns the OpenAPI
+// document describing the service's routes.
+func (as *AuthServer) getOpenAPIRoute(ctx *gin.Context) {
+	ctx.JSON(200, as.apiSpec)
+}
+
+// TODO Log all errors
+// postAddUserRoute is the POST /add-user route. The RequireAuth and RequireAdmin
+// middleware make sure that only admins can add users.
+func (as *AuthServer) postAddUserRoute(ctx *gin.Context) {
+	if as.addUser(ctx) {
+		ctx.Status(200)
 	}
+}
 
+// addUser binds an AddUserBody and adds the user. It returns false if it
+// responded with an error. It's shared by POST /add-user and POST /v1/users.
+func (as *AuthServer) addUser(ctx *gin.Context) bool {
 	// Extract data from the body of the request.
 	var body AddUserBody
 
 	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
-		ctx.JSON(
-			http.StatusBadRequest,
-			gin.H{"error": "missing required values"},
-			// gin.H{"error": bindJsonErr.Error()},
-		)
-		return
+		respondWithInvalidBody(ctx)
+		return false
 	}
 
-	addUserErr := as.AuthController.AddNewUser(body, ctx)
+	claims, _ := authMiddleware.GetClaims(ctx)
 
-	if addUserErr != nil {
-
-		var errMsg string
-		var statusCode int
-
-		switch addUserErr.(type) {
-		case authUtils.NonceError:
-			errMsg = "Invalid nonce"
-			statusCode = http.StatusBadRequest
-		case dbController.DBError:
-			errMsg = "Internal server error"
-			statusCode = http.StatusInternalServerError
-		case dbController.DuplicateEntryError:
-			errMsg = addUserErr.Error()
-			statusCode = http.StatusBadRequest
-		default:
-			errMsg = "Error adding user"
-			statusCode = http.StatusBadRequest
-		}
+	addUserErr := as.AuthController.AddNewUser(body, claims, ctx)
 
-		ctx.JSON(
-			statusCode,
-			gin.H{"error": errMsg},
-		)
-		return
+	if addUserErr != nil {
+		respondWithError(ctx, addUserErr)
+		return false
 	}
 
-	ctx.Status(200)
+	return true
 }
 
 // postEditUserRoute is the POST /edit-user route. This route handles updating
 // user information. Admin users are allowed to edit any user's information.
 // Otherwise, regular users can update their own information.
 func (as *AuthServer) postEditUserRoute(ctx *gin.Context) {
-	// Check the user's authorization token. We want to make sure it exists.
-	claims, claimsErr := as.ExtractJWTFromHeader(ctx)
+	// We extract the data from the request body and check that the body is OK
+	var body EditUserBody
+	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
+		respondWithInvalidBody(ctx)
+		return
+	}
 
-	// We determine if there are any issues with the claims. If it expired or is not
-	// valid.
-	if claimsErr != nil {
-		var errMsg string
-		var statusCode int
-
-		switch claimsErr.(type) {
-		case authCrypto.ExpiredJWTError:
-			errMsg = "Expired authorization token"
-			statusCode = http.StatusUnauthorized
-		case authCrypto.JWTError:
-			errMsg = "Not authorized"
-			statusCode = http.StatusUnauthorized
-		default:
-			errMsg = "Invalid authorization token"
-			statusCode = http.StatusBadRequest
-		}
+	if as.editUser(ctx, &body) {
+		ctx.Status(200)
+	}
+}
+
+// editUser performs the edit for POST /edit-user and PATCH /v1/users/:id. It
+// returns false if it responded with an error.
+func (as *AuthServer) editUser(ctx *gin.Context, body *EditUserBody) bool {
+	// The RequireAuth middleware has already validated the user's authorization token.
+	claims, _ := authMiddleware.GetClaims(ctx)
 
-		ctx.JSON(
-			statusCode,
-			gin.H{"error": errMsg},
-		)
+	if !requireClientCertForUser(ctx, claims, body.Id) {
+		return false
+	}
+
+	editUserErr := as.AuthController.EditUser(body, claims, ctx)
+
+	if editUserErr != nil {
+		respondWithError(ctx, editUserErr)
+		return false
+	}
+
+	return true
+}
+
+func (as *AuthServer) postEditUserPasswordRoute(ctx *gin.Context) {
+	// Extract data from the body of the request.
+	var body EditPasswordBody
+	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
+		respondWithInvalidBody(ctx)
 		return
 	}
 
-	// We extract the data from the request body and check that the body is OK
-	var body EditUserBody
+	if as.editUserPassword(ctx, &body) {
+		ctx.Status(200)
+	}
+}
+
+// editUserPassword changes the password for POST /edit-user-password and
+// PUT /v1/users/:id/password. It returns false if it responded with an error.
+func (as *AuthServer) editUserPassword(ctx *gin.Context, body *EditPasswordBody) bool {
+	// The RequireAuth middleware has already validated the user's authorization token.
+	claims, _ := authMiddleware.GetClaims(ctx)
+
+	if !requireClientCertForUser(ctx, claims, body.Id) {
+		return false
+	}
+
+	editPassErr := as.AuthController.EditUserPassword(body, claims, ctx)
+
+	if editPassErr != nil {
+		respondWithError(ctx, editPassErr)
+		return false
+	}
+
+	return true
+}
+
+// getMeRoute is the GET /me route. It returns the caller's user data, read from
+// the database rather than from the token's claims, along with metadata about
+// the token.
+func (as *AuthServer) getMeRoute(ctx *gin.Context) {
+	claims, _ := authMiddleware.GetClaims(ctx)
+
+	userDoc, userErr := as.AuthController.GetUser(claims.Subject, claims, ctx)
+	if userErr != nil {
+		respondWithError(ctx, userErr)
+		return
+	}
+
+	ctx.JSON(200, MeResponse{
+		User:  NewUserResponse(userDoc),
+		Token: NewTokenMetadata(claims),
+	})
+}
+
+// patchMeRoute is the PATCH /me route. Users can edit their own profile without
+// passing their id. It responds like GET /me.
+func (as *AuthServer) patchMeRoute(ctx *gin.Context) {
+	claims, _ := authMiddleware.GetClaims(ctx)
+
+	var body PatchMeBody
 	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
-		ctx.JSON(
-			http.StatusBadRequest,
-			gin.H{"error": "Missing required values"},
-		)
+		respondWithInvalidBody(ctx)
 		return
 	}
 
-	// Perform the actual edit
-	editUserErr := as.AuthController.EditUser(&body, claims, ctx)
+	editBody := body.EditUserBody(claims.Subject)
+	if as.editUser(ctx, &editBody) {
+		as.getMeRoute(ctx)
+	}
+}
 
-	if editUserErr != nil {
-		var errMsg string
-		var statusCode int
-
-		switch editUserErr.(type) {
-		case UnauthorizedError:
-			errMsg = "Not authorized to perform this action"
-			statusCode = http.StatusUnauthorized
-		case authUtils.NonceError:
-			errMsg = "Invalid nonce"
-			statusCode = http.StatusBadRequest
-		case authCrypto.JWTError:
-			errMsg = "Not authorized"
-			statusCode = http.StatusUnauthorized
-		case dbController.DuplicateEntryError:
-			errMsg = editUserErr.Error()
-			statusCode = http.StatusBadRequest
-		case dbController.InvalidInputError:
-			errMsg = editUserErr.Error()
-			statusCode = http.StatusBadRequest
-		default:
-			errMsg = "Error editing user"
-			statusCode = http.StatusBadRequest
-		}
+// getSessionsRoute is the GET /sessions and GET /users/:id/sessions route. It
+// lists the active sessions of the user in the path, or of the caller if the
+// path has no id.
+func (as *AuthServer) getSessionsRoute(ctx *gin.Context) {
+	claims, _ := authMiddleware.GetClaims(ctx)
 
-		ctx.JSON(
-			statusCode,
-			gin.H{"error": errMsg},
-		)
+	sessions, sessionsErr := as.AuthController.GetSessions(sessionUserId(ctx, claims), claims)
+	if sessionsErr != nil {
+		respondWithError(ctx, sessionsErr)
 		return
 	}
 
-	ctx.Status(200)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, NewSessionResponse(session, claims))
+	}
+
+	ctx.JSON(200, response)
 }
 
-func (as *AuthServer) postEditUserPasswordRoute(ctx *gin.Context) {
-	// Check the user's authorization token. We want to make sure it exists.
-	claims, claimsErr := as.ExtractJWTFromHeader(ctx)
+// deleteSessionRoute is the DELETE /sessions/:sessionId and DELETE
+// /users/:id/sessions/:sessionId route. It revokes a session. The nonce is
+// passed in the query string.
+func (as *AuthServer) deleteSessionRoute(ctx *gin.Context) {
+	claims, _ := authMiddleware.GetClaims(ctx)
 
-	// We determine if there are any issues with the claims. If it expired or is not
-	// valid.
-	if claimsErr != nil {
-		var errMsg string
-		var statusCode int
-
-		switch claimsErr.(type) {
-		case authCrypto.ExpiredJWTError:
-			errMsg = "Expired authorization token"
-			statusCode = http.StatusUnauthorized
-		case authCrypto.JWTError:
-			errMsg = "Not authorized"
-			statusCode = http.StatusUnauthorized
-		default:
-			errMsg = "Invalid authorization token"
-			statusCode = http.StatusBadRequest
-		}
+	var query NonceQuery
+	if bindErr := ctx.ShouldBindQuery(&query); bindErr != nil {
+		respondWithInvalidBody(ctx)
+		return
+	}
 
-		ctx.JSON(
-			statusCode,
-			gin.H{"error": errMsg},
-		)
+	revokeErr := as.AuthController.RevokeSession(sessionUserId(ctx, claims), ctx.Param("sessionId"), query.Nonce, claims, ctx)
+	if revokeErr != nil {
+		respondWithError(ctx, revokeErr)
 		return
 	}
 
-	// Extract data from the body of the request.
-	var body EditPasswordBody
+	ctx.Status(204)
+}
+
+// postImpersonateRoute is the POST /users/:id/impersonate route. It responds
+// with a short lived token for the user in the path.
+func (as *AuthServer) postImpersonateRoute(ctx *gin.Context) {
+	claims, _ := authMiddleware.GetClaims(ctx)
+
+	var body ImpersonateBody
 	if bindJsonErr := ctx.ShouldBindJSON(&body); bindJsonErr != nil {
-		ctx.JSON(
-			http.StatusBadRequest,
-			gin.H{"error": "missing required values"},
-		)
+		respondWithInvalidBody(ctx)
 		return
 	}
 
-	editPassErr := as.AuthController.EditUserPassword(&body, claims, ctx)
+	token, impersonateErr := as.AuthController.ImpersonateUser(ctx.Param("id"), body.Nonce, claims, ctx)
+	if impersonateErr != nil {
+		respondWithError(ctx, impersonateErr)
+		return
+	}
 
-	if editPassErr != nil {
-		var errMsg string
-		var statusCode int
-
-		switch editPassErr.(type) {
-		case UnauthorizedError:
-			errMsg = "Not authorized to perform this action"
-			statusCode = http.StatusUnauthorized
-		case authUtils.NonceError:
-			errMsg = "Invalid nonce"
-			statusCode = http.StatusBadRequest
-		case authCrypto.JWTError:
-			errMsg = "Not authorized"
-			statusCode = http.StatusUnauthorized
-		case dbController.InvalidInputError:
-			errMsg = editPassErr.Error()
-			statusCode = http.StatusBadRequest
-		default:
-			errMsg = "Error editing user"
-			statusCode = http.StatusBadRequest
-		}
+	ctx.JSON(200, TokenResponse{Token: token})
+}
+
+// getAuditRoute is the GET /audit route. It lists the audit events matching the
+// filters in the query string, newest first.
+func (as *AuthServer) getAuditRoute(ctx *gin.Context) {
+	claims, _ := authMiddleware.GetClaims(ctx)
 
-		ctx.JSON(
-			statusCode,
-			gin.H{"error": errMsg},
-		)
+	var query AuditQuery
+	if bindErr := ctx.ShouldBindQuery(&query); bindErr != nil {
+		apiErrors.WriteCode(ctx, apiErrors.InvalidInput, "invalid audit filters")
 		return
 	}
 
-	ctx.Status(200)
+	events, eventsErr := as.AuthController.GetAuditEvents(query.Filter(), claims, ctx)
+	if eventsErr != nil {
+		respondWithError(ctx, eventsErr)
+		return
+	}
+
+	ctx.JSON(200, events)
+}
+
+// getLogsRoute is the GET /logs route. It returns a page of the logs matching
+// the filters in the query string, newest first.
+func (as *AuthServer) getLogsRoute(ctx *gin.Context) {
+	claims, _ := authMiddleware.GetClaims(ctx)
+
+	var query LogsQuery
+	if bindErr := ctx.ShouldBindQuery(&query); bindErr != nil {
+		apiErrors.WriteCode(ctx, apiErrors.InvalidInput, "invalid log filters")
+		return
+	}
+
+	logs, logsErr := as.AuthController.GetLogs(query.Filter(), claims, ctx)
+	if logsErr != nil {
+		respondWithError(ctx, logsErr)
+		return
+	}
+
+	ctx.JSON(200, logs)
+}
+
+// sessionUserId returns the id in the path of the admin session routes, or the
+// caller's id for the /sessions routes
+func sessionUserId(ctx *gin.Context, claims *authCrypto.JWTClaims) string {
+	if id := ctx.Param("id"); len(id) > 0 {
+		return id
+	}
+
+	return claims.Subject
+}
+
+// postIntrospectRoute is the POST /introspect route. It implements token
+// introspection as described in RFC 7662, for clients that can't verify JWTs
+// themselves. Callers must either authenticate as a registered client using
+// HTTP Basic authentication or send an admin token.
+func (as *AuthServer) postIntrospectRoute(ctx *gin.Context) {
+	if !as.canIntrospect(ctx) {
+		apiErrors.WriteCode(ctx, apiErrors.Unauthenticated, "a registered client or an admin token is required")
+		return
+	}
+
+	var body IntrospectBody
+	if bindErr := ctx.ShouldBind(&body); bindErr != nil {
+		respondWithInvalidBody(ctx)
+		return
+	}
+
+	// Introspection responses must not be cached
+	ctx.Header("Cache-Control", "no-store")
+
+	ctx.JSON(200, as.AuthController.IntrospectToken(body.Token))
+}
+
+// canIntrospect returns true if the caller is a registered introspection client
+// or has an admin token.
+func (as *AuthServer) canIntrospect(ctx *gin.Context) bool {
+	if clientId, clientSecret, ok := ctx.Request.BasicAuth(); ok {
+		return CheckIntrospectionClient(clientId, clientSecret)
+	}
+
+	claims, claimsErr := as.ExtractJWTFromHeader(ctx)
+	if claimsErr != nil {
+		return false
+	}
+
+	return claims.Admin
 }
@@ -1,20 +1,32 @@
 package authServer
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 
+	"methompson.com/auth-microservice/authServer/apiErrors"
 	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+	"methompson.com/auth-microservice/authServer/authTLS"
+	"methompson.com/auth-microservice/authServer/authTracing"
 	"methompson.com/auth-microservice/authServer/authUtils"
 	"methompson.com/auth-microservice/authServer/constants"
 	"methompson.com/auth-microservice/authServer/dbController"
 	"methompson.com/auth-microservice/authServer/mongoDbController"
+	"methompson.com/auth-microservice/authServer/openApi"
 )
 
 // The purpose of the AuthServer is to handle all aspects of serving data, handling
@@ -24,6 +36,19 @@ import (
 type AuthServer struct {
 	AuthController AuthController
 	GinEngine      *gin.Engine
+	apiSpec        *openApi.Document
+	// The servers are served over TLS if it's set
+	TLSConfig    *tls.Config
+	certificates *authTLS.CertificateReloader
+	grpcServer   *grpc.Server
+	httpServer   *http.Server
+	// Flushes the queued spans and closes the trace exporter
+	shutdownTracing func(ctx context.Context) error
+	// Closes the database's connections
+	disconnectDB func(ctx context.Context) error
+	// Cancels the background jobs, which Shutdown waits on with jobs
+	stopJobs context.CancelFunc
+	jobs     *sync.WaitGroup
 }
 
 func StartServer() {
@@ -41,28 +66,61 @@ func StartServer() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// The environment variables are checked above, so the error is ignored
+	tracingOptions, _ := authTracing.GetTracingOptions()
+
+	shutdownTracing, tracingErr := authTracing.ConfigureTracing(tracingOptions)
+	if tracingErr != nil {
+		log.Fatal("Error configuring tracing: ", tracingErr.Error())
+	}
+
 	authServer := makeNewServer()
+	authServer.shutdownTracing = shutdownTracing
+
+	// The environment variables are checked above, so the error is ignored
+	tlsOptions, _ := authTLS.GetTLSOptions()
+
+	if tlsOptions.Enabled() {
+		certificates, certificatesErr := authTLS.NewCertificateReloader(tlsOptions)
+		if certificatesErr != nil {
+			log.Fatal(certificatesErr.Error())
+		}
+
+		authServer.certificates = certificates
+		authServer.TLSConfig = certificates.TLSConfig()
+	}
 
 	// We run this after creating a server, but before setting routes. Any
 	// route set BEFORE this won't actually use this.
+	authServer.GinEngine.Use(authMiddleware.RequestId())
+	authServer.GinEngine.Use(authMiddleware.Tracing())
+	authServer.GinEngine.Use(authMiddleware.Metrics())
+
 	if !DebugMode() {
 		errs := configureReleaseLogging(&authServer)
 
-		if len(errs) > 0 {
-			for _, err := range errs {
-				print(err.Error() + "\n")
-			}
+		// Everything logged with slog, including the MongoDbController's logs,
+		// is written to the configured loggers
+		slog.SetDefault(authServer.AuthController.Logger())
+
+		for _, err := range errs {
+			slog.Error("error configuring logging", "error", err)
 		}
+
 		addLogging(&authServer)
 
 		addRecovery(&authServer)
+
+		authServer.reopenLogsOnSIGHUP()
 	}
 
-	authServer.scheduleNonceCleanout()
+	authServer.startBackgroundJobs()
+
+	authServer.SetRoutes()
 
-	authServer.setRoutes()
+	authServer.startGRPCServer()
 
-	// The Run command blocks console logging, so we just run it and nothing after.
+	// runServer blocks until the server has been shut down
 	authServer.runServer()
 }
 
@@ -72,6 +130,8 @@ func addLogging(as *AuthServer) {
 		requestData := authUtils.RequestLogData{
 			Timestamp:    param.TimeStamp,
 			Type:         "request",
+			RequestId:    requestLogId(param.Keys),
+			UserId:       requestLogUserId(param.Keys),
 			ClientIP:     param.ClientIP,
 			Method:       param.Method,
 			Path:         param.Path,
@@ -82,73 +142,127 @@ func addLogging(as *AuthServer) {
 			ErrorMessage: param.ErrorMessage,
 		}
 
-		for _, logger := range as.AuthController.Loggers {
-			l := *logger
-			l.AddRequestLog(&requestData)
-		}
+		as.AuthController.AddRequestLog(&requestData)
 
 		return ""
 	}))
 }
 
+// requestLogId returns the id stored by the RequestId middleware
+func requestLogId(keys map[string]interface{}) string {
+	requestId, _ := keys[authMiddleware.RequestIdKey].(string)
+
+	return requestId
+}
+
+// requestLogUserId returns the subject of the token stored by the RequireAuth
+// middleware. Requests that weren't authenticated have no user id.
+func requestLogUserId(keys map[string]interface{}) string {
+	claims, ok := keys[authMiddleware.ClaimsKey].(*authCrypto.JWTClaims)
+	if !ok || claims == nil {
+		return ""
+	}
+
+	return claims.Subject
+}
+
 // TODO figure out recovery
 func addRecovery(as *AuthServer) {
 	as.GinEngine.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
-		msg := "Unknown Error"
-		if err, ok := recovered.(string); ok {
-			msg = fmt.Sprintf("error: %s", err)
-			c.String(http.StatusInternalServerError, msg)
-		}
-
-		errorLog := authUtils.InfoLogData{
-			Timestamp: time.Now(),
-			Type:      "error",
-			Message:   msg,
-		}
+		as.AuthController.Logger().Error("recovered from panic",
+			"error", fmt.Sprint(recovered),
+			"requestId", authMiddleware.GetRequestId(c),
+		)
 
-		for _, logger := range as.AuthController.Loggers {
-			l := *logger
-			l.AddInfoLog(&errorLog)
-		}
-
-		c.AbortWithStatus(http.StatusInternalServerError)
+		apiErrors.WriteCode(c, apiErrors.InternalError, "")
 	}))
 }
 
+// configureReleaseLogging adds the loggers enabled in the environment. Every
+// logger writes in the background from its own queue, so that requests don't wait
+// on logging.
 func configureReleaseLogging(as *AuthServer) []error {
 	errs := make([]error, 0)
 	controller := &as.AuthController
 
+	// The environment variables are checked on startup, so the errors are ignored
+	asyncOptions, _ := authUtils.GetAsyncLoggerOptions()
+
 	if os.Getenv(constants.DB_LOGGING) == "true" {
 		// We set the logger to a database logger
 		// First, we manipulate the pointers in order to add the DBController to the logger
 		// in order to log release data to the database.
-		var dbController authUtils.AuthLogger = *controller.DBController
+		// Audit events are stored in the audit collection, so they aren't stored
+		// again with the other logs
+		dbOptions := withLoggerName(asyncOptions, "database")
+		dbOptions.SkipAudit = true
+
+		var dbController authUtils.AuthLogger = authUtils.NewAsyncLogger(*controller.DBController, dbOptions)
 		controller.AddLogger(&dbController)
 	}
 
 	if os.Getenv(constants.FILE_LOGGING) == "true" {
 		// We can also log to a file
-		var fileLogger authUtils.AuthLogger
-		var fileLoggerErr error
+		options, _ := authUtils.GetFileLoggerOptions()
 
-		fileLogger, fileLoggerErr = authUtils.MakeNewFileLogger(os.Getenv(constants.FILE_LOGGING_PATH), "logs.log")
+		fileLogger, fileLoggerErr := authUtils.MakeNewFileLogger(os.Getenv(constants.FILE_LOGGING_PATH), "logs.log", options)
 
 		if fileLoggerErr != nil {
 			errs = append(errs, fileLoggerErr)
 		}
-		controller.AddLogger(&fileLogger)
+
+		var asyncFileLogger authUtils.AuthLogger = authUtils.NewAsyncLogger(fileLogger, withLoggerName(asyncOptions, "file"))
+		controller.AddLogger(&asyncFileLogger)
 	}
 
 	if os.Getenv(constants.CONSOLE_LOGGING) == "true" {
-		var consoleLogger authUtils.AuthLogger = &authUtils.ConsoleLogger{}
+		var consoleLogger authUtils.AuthLogger = authUtils.NewAsyncLogger(&authUtils.ConsoleLogger{}, withLoggerName(asyncOptions, "console"))
 
 		controller.AddLogger(&consoleLogger)
 	}
 
+	if os.Getenv(constants.SYSLOG_LOGGING) == "true" {
+		options, _ := authUtils.GetSyslogOptions()
+
+		syslogLogger, syslogLoggerErr := authUtils.MakeNewSyslogLogger(options)
+
+		if syslogLoggerErr != nil {
+			errs = append(errs, syslogLoggerErr)
+		}
+
+		var asyncSyslogLogger authUtils.AuthLogger = authUtils.NewAsyncLogger(syslogLogger, withLoggerName(asyncOptions, "syslog"))
+		controller.AddLogger(&asyncSyslogLogger)
+	}
+
+	if os.Getenv(constants.HTTP_LOGGING) == "true" {
+		options, _ := authUtils.GetHTTPLoggerOptions()
+
+		var httpLogger authUtils.AuthLogger = authUtils.NewAsyncLogger(authUtils.MakeNewHTTPLogger(options), withLoggerName(asyncOptions, "http"))
+		controller.AddLogger(&httpLogger)
+	}
+
 	return errs
 }
 
+// withLoggerName sets the logger label of an AsyncLogger's metrics
+func withLoggerName(options authUtils.AsyncLoggerOptions, name string) authUtils.AsyncLoggerOptions {
+	options.Name = name
+	return options
+}
+
+// reopenLogsOnSIGHUP reopens the log files on SIGHUP, so that they can be
+// rotated by tools like logrotate
+func (as *AuthServer) reopenLogsOnSIGHUP() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			as.AuthController.ReopenLoggers()
+		}
+	}()
+}
+
 func makeNewServer() AuthServer {
 	mdbController, mdbControllerErr := mongoDbController.MakeMongoDbController(constants.AUTH_DB_NAME)
 
@@ -165,17 +279,19 @@ func makeNewServer() AuthServer {
 	engine := makeServer()
 
 	// First we assign the pointer-to MongoDbController of mongoDbController to
-	// the variable indirect. Next, we assign that value to a variable of type
-	// DatabaseController. Then we get the pointer-to DatabaseController and
+	// the variable indirect. Next, we wrap that value in an InstrumentedController,
+	// so that every database operation is measured, and assign it to a variable of
+	// type DatabaseController. Then we get the pointer-to DatabaseController and
 	// assign that to cont. We can use pointer-to DatabaseController to run
 	// InitController to initialize the AuthController.
 	indirect := &mdbController
-	var passedController dbController.DatabaseController = indirect
+	var passedController dbController.DatabaseController = dbController.NewInstrumentedController(indirect, observeDBOperation)
 	cont := &passedController
 
 	authServer := AuthServer{
 		AuthController: InitController(cont),
 		GinEngine:      engine,
+		disconnectDB:   indirect.Disconnect,
 	}
 
 	return authServer
@@ -189,51 +305,82 @@ func makeServer() *gin.Engine {
 	return gin.Default()
 }
 
+// runServer serves HTTP requests until the process receives SIGINT or SIGTERM,
+// then shuts the server down gracefully. A second signal stops the process right
+// away.
 func (as *AuthServer) runServer() {
-	as.GinEngine.Run()
-}
+	listener, listenErr := net.Listen("tcp", serverAddress())
+	if listenErr != nil {
+		log.Fatal("Error starting the server: ", listenErr.Error())
+	}
 
-// Every 5 minutes, we'll clean up the Nonces
-func (as *AuthServer) scheduleNonceCleanout() {
-	go func() {
-		time.Sleep(5 * time.Minute)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-		as.AuthController.RemoveOldNonces()
+	served := as.Serve(listener)
 
-		as.scheduleNonceCleanout()
-	}()
+	select {
+	case serveErr := <-served:
+		log.Fatal("Server Error: ", serveErr)
+	case sig := <-signals:
+		signal.Stop(signals)
+		as.AuthController.Logger().Info("shutting down", "signal", sig.String())
+	}
+
+	// The environment variables are checked on startup, so the error is ignored
+	timeout, _ := GetShutdownTimeout()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The loggers are closed by now, so errors are written to stderr
+	if shutdownErr := as.Shutdown(ctx); shutdownErr != nil {
+		fmt.Fprintln(os.Stderr, "Error shutting down: "+shutdownErr.Error())
+		os.Exit(1)
+	}
 }
 
-func (as *AuthServer) ExtractJWTFromHeader(ctx *gin.Context) (*authCrypto.JWTClaims, error) {
-	var header AuthorizationHeader
-	expiredTxt := "token is expired"
-	invalidTxt := "invalid signing method"
-	verificationTxt := "verification error"
-
-	// No Token Error
-	if headerErr := ctx.ShouldBindHeader(&header); headerErr != nil {
-		return nil, authCrypto.NewJWTError("missing jwt from header")
-	}
-
-	claims, jwtErr := authCrypto.ValidateJWT(header.Token)
-
-	// Expired Token Error
-	// Invalid Signing Method Error
-	// Verificatin error
-	if jwtErr != nil {
-		errTxt := strings.ToLower(jwtErr.Error())
-
-		var returnErr error
-		if strings.Contains(errTxt, expiredTxt) {
-			returnErr = authCrypto.NewExpiredJWTError(jwtErr.Error())
-		} else if strings.Contains(errTxt, invalidTxt) || strings.Contains(errTxt, verificationTxt) {
-			returnErr = authCrypto.NewJWTError(jwtErr.Error())
-		} else {
-			returnErr = authCrypto.NewJWTError(jwtErr.Error())
+// scheduleNonceCleanout cleans up the Nonces and expired sessions every 5
+// minutes until ctx is cancelled. A cleanout that has started runs to the end.
+func (as *AuthServer) scheduleNonceCleanout(ctx context.Context) {
+	ticker := time.NewTicker(constants.FIVE_MINUTES)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 
-		return nil, returnErr
+		if noncesErr := as.AuthController.RemoveOldNonces(); noncesErr != nil {
+			as.AuthController.Logger().Error("error removing old nonces", "error", noncesErr)
+		}
+		if sessionsErr := as.AuthController.RemoveExpiredSessions(); sessionsErr != nil {
+			as.AuthController.Logger().Error("error removing expired sessions", "error", sessionsErr)
+		}
 	}
+}
+
+// tokenValidator returns the TokenValidator used by the authentication
+// middleware. It validates tokens with the service's own key and configuration
+// and rejects tokens from revoked sessions.
+func (as *AuthServer) tokenValidator() authMiddleware.TokenValidator {
+	return authMiddleware.ValidatorFunc(as.AuthController.ValidateToken)
+}
 
-	return claims, nil
+// authenticator returns the Authenticator used by the authentication middleware.
+// It accepts tokens from the sources configured with TOKEN_SOURCES. The
+// environment variables are checked on startup, so the error is ignored.
+func (as *AuthServer) authenticator() *authMiddleware.Authenticator {
+	options, _ := GetAuthenticatorOptions()
+
+	return authMiddleware.NewAuthenticator(as.tokenValidator(), options)
+}
+
+// ExtractJWTFromHeader validates the JWT in the request. Despite its name, the
+// token can come from any of the configured token sources. Routes that always
+// require a token should use the authMiddleware.RequireAuth middleware instead.
+func (as *AuthServer) ExtractJWTFromHeader(ctx *gin.Context) (*authCrypto.JWTClaims, error) {
+	return as.authenticator().ExtractClaims(ctx)
 }
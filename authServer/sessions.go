@@ -0,0 +1,156 @@
+package authServer
+
+import (
+	"context"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// A session's last seen time is only updated if it's older than this, so that
+// validating a token doesn't write to the database on every request.
+const sessionLastSeenInterval = time.Minute
+
+// createSession stores a session for a login or an impersonation. The session
+// expires at expiresAt, with the token issued for it.
+func (ac *AuthController) createSession(userId string, expiresAt int64, ctx RequestContext) (session dbController.SessionDocument, addErr error) {
+	ctx, span := ac.startSpan(ctx, "createSession")
+	defer func() { authTracing.EndSpan(span, addErr) }()
+
+	sessionId, _ := GenerateRandomString(16)
+	refreshTokenFamily, _ := GenerateRandomString(16)
+	now := time.Now().Unix()
+
+	session = dbController.SessionDocument{
+		Id:                 sessionId,
+		UserId:             userId,
+		UserAgent:          ctx.GetHeader("User-Agent"),
+		IPAddress:          ctx.ClientIP(),
+		CreatedAt:          now,
+		LastSeenAt:         now,
+		ExpiresAt:          expiresAt,
+		RefreshTokenFamily: refreshTokenFamily,
+	}
+
+	addErr = ac.db(ctx).AddSession(session)
+
+	return session, addErr
+}
+
+// ValidateToken validates a JWT like authCrypto.ValidateJWT and also rejects
+// tokens whose session has been revoked or no longer exists. Login tokens
+// issued before sessions were introduced don't have a session id. They're
+// accepted until they expire. Impersonation tokens always have one, so that
+// they can be revoked.
+func (ac *AuthController) ValidateToken(token string) (*authCrypto.JWTClaims, error) {
+	claims, claimsErr := authCrypto.ValidateJWT(token)
+	if claimsErr != nil {
+		return nil, claimsErr
+	}
+
+	if len(claims.SessionId) == 0 {
+		if claims.Impersonated() {
+			return nil, authCrypto.NewJWTError("impersonation token has no session")
+		}
+
+		return claims, nil
+	}
+
+	session, sessionErr := (*ac.DBController).GetSession(claims.SessionId)
+	if sessionErr != nil {
+		if _, ok := sessionErr.(dbController.NoResultsError); ok {
+			return nil, authCrypto.NewJWTError("session does not exist")
+		}
+
+		ac.Logger().Error("error reading the token's session", "sessionId", claims.SessionId, "error", sessionErr)
+		return nil, sessionErr
+	}
+
+	if session.Revoked() {
+		return nil, authCrypto.NewJWTError("session has been revoked")
+	}
+
+	now := time.Now()
+	if now.Sub(time.Unix(session.LastSeenAt, 0)) > sessionLastSeenInterval {
+		// Failing to update the last seen time shouldn't fail the request
+		if updateErr := (*ac.DBController).UpdateSessionLastSeen(session.Id, now.Unix()); updateErr != nil {
+			ac.Logger().Warn("error updating the session's last seen time", "sessionId", session.Id, "error", updateErr)
+		}
+	}
+
+	return claims, nil
+}
+
+// GetSessions returns a user's active sessions. Admins can get any user's
+// sessions. Other users can only get their own sessions.
+func (ac *AuthController) GetSessions(userId string, claims *authCrypto.JWTClaims) ([]dbController.SessionDocument, error) {
+	if !claims.Admin && userId != claims.Subject {
+		return nil, NewUnauthorizedError("Not authorized to perform this action")
+	}
+
+	sessions, sessionsErr := (*ac.DBController).GetUserSessions(userId)
+	if sessionsErr != nil {
+		return nil, sessionsErr
+	}
+
+	now := time.Now().Unix()
+	active := make([]dbController.SessionDocument, 0)
+
+	for _, session := range sessions {
+		if !session.Revoked() && session.ExpiresAt > now {
+			active = append(active, session)
+		}
+	}
+
+	return active, nil
+}
+
+// RevokeSession revokes one of a user's sessions. Tokens issued for the session
+// are rejected afterward. Admins can revoke any user's sessions. Other users
+// can only revoke their own sessions. The attempt is written to the audit log.
+func (ac *AuthController) RevokeSession(userId string, sessionId string, nonce string, claims *authCrypto.JWTClaims, ctx RequestContext) (revokeErr error) {
+	ctx, span := ac.startSpan(ctx, "RevokeSession")
+	defer func() { authTracing.EndSpan(span, revokeErr) }()
+
+	defer func() {
+		ac.addAuditEvent(newAuditEvent(authUtils.AuditRevokeSession, claims, userId, "", revokeErr, ctx), ctx)
+	}()
+
+	// If we're not in debug mode OR we're in debug mode and we're NOT ignoring nonces
+	if !DebugMode() || (!authUtils.IgnoringNonce() && DebugMode()) {
+		nonceErr := ac.CheckNonceValidity(nonce, ctx)
+
+		if nonceErr != nil {
+			return nonceErr
+		}
+	}
+
+	if !claims.Admin && userId != claims.Subject {
+		return NewUnauthorizedError("Not authorized to perform this action")
+	}
+
+	session, sessionErr := ac.db(ctx).GetSession(sessionId)
+	if sessionErr != nil {
+		return sessionErr
+	}
+
+	// Sessions of other users are reported as missing
+	if session.UserId != userId {
+		return dbController.NewNoResultsError("Id did not match any sessions")
+	}
+
+	return ac.db(ctx).RevokeSession(sessionId, time.Now().Unix())
+}
+
+// RemoveExpiredSessions removes sessions whose tokens have expired
+func (ac *AuthController) RemoveExpiredSessions() error {
+	ctx, span := authTracing.Tracer().Start(context.Background(), "AuthController.RemoveExpiredSessions")
+
+	removeErr := ac.dbWithContext(ctx).RemoveExpiredSessions(time.Now().Unix())
+	authTracing.EndSpan(span, removeErr)
+
+	return removeErr
+}
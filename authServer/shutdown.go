@@ -0,0 +1,205 @@
+package authServer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// How long the spans and the database connections get to close once the server
+// has drained
+const flushTimeout = 5 * time.Second
+
+// GetShutdownTimeout returns how long a shutdown waits for in-flight requests and
+// background jobs to finish. Defaults to 30 seconds.
+func GetShutdownTimeout() (time.Duration, error) {
+	str := strings.TrimSpace(os.Getenv(constants.SHUTDOWN_TIMEOUT))
+	if len(str) == 0 {
+		return constants.DEFAULT_SHUTDOWN_TIMEOUT, nil
+	}
+
+	timeout, parseErr := time.ParseDuration(str)
+	if parseErr != nil || timeout <= 0 {
+		return 0, NewEnvironmentVariableError(fmt.Sprintf("SHUTDOWN_TIMEOUT '%s' is not a valid duration", str))
+	}
+
+	return timeout, nil
+}
+
+// serverAddress returns the address the HTTP server listens on. Like Gin's Run,
+// it listens on PORT, or 8080 if PORT isn't set.
+func serverAddress() string {
+	if port := os.Getenv("PORT"); len(port) > 0 {
+		return ":" + port
+	}
+
+	return ":8080"
+}
+
+// Serve serves HTTP requests from listener in the background until Shutdown is
+// called. Requests are served over TLS if TLSConfig is set. The returned channel
+// receives the error that stopped the server, or nil once the server has been
+// shut down.
+func (as *AuthServer) Serve(listener net.Listener) <-chan error {
+	server := &http.Server{Handler: as.GinEngine, TLSConfig: as.TLSConfig}
+	as.httpServer = server
+
+	served := make(chan error, 1)
+
+	go func() {
+		var serveErr error
+
+		// The certificate comes from TLSConfig, so no files are passed
+		if server.TLSConfig != nil {
+			serveErr = server.ServeTLS(listener, "", "")
+		} else {
+			serveErr = server.Serve(listener)
+		}
+
+		if errors.Is(serveErr, http.ErrServerClosed) {
+			serveErr = nil
+		}
+
+		served <- serveErr
+	}()
+
+	return served
+}
+
+// Shutdown stops the server. The HTTP and gRPC servers stop accepting requests
+// and the background jobs are cancelled. Once the in-flight requests and running
+// jobs finish, or ctx is done, the queued spans and logs are flushed and the
+// database is disconnected. Requests still running when ctx is done are cut off
+// and an error is returned.
+func (as *AuthServer) Shutdown(ctx context.Context) error {
+	errs := make([]error, 0)
+
+	if as.httpServer != nil {
+		if shutdownErr := as.httpServer.Shutdown(ctx); shutdownErr != nil {
+			errs = append(errs, fmt.Errorf("draining HTTP requests: %w", shutdownErr))
+			as.httpServer.Close()
+		}
+	}
+
+	if as.grpcServer != nil {
+		if stopErr := as.stopGRPCServer(ctx); stopErr != nil {
+			errs = append(errs, fmt.Errorf("draining gRPC requests: %w", stopErr))
+		}
+	}
+
+	if stopErr := as.stopBackgroundJobs(ctx); stopErr != nil {
+		errs = append(errs, fmt.Errorf("stopping background jobs: %w", stopErr))
+	}
+
+	if as.shutdownTracing != nil {
+		flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		if tracingErr := as.shutdownTracing(flushCtx); tracingErr != nil {
+			errs = append(errs, fmt.Errorf("flushing spans: %w", tracingErr))
+		}
+		cancel()
+	}
+
+	// Every logger waits up to its own FlushTimeout and reports its errors
+	as.AuthController.CloseLoggers()
+
+	if as.disconnectDB != nil {
+		disconnectCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		if disconnectErr := as.disconnectDB(disconnectCtx); disconnectErr != nil {
+			errs = append(errs, fmt.Errorf("disconnecting the database: %w", disconnectErr))
+		}
+		cancel()
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopGRPCServer waits for the gRPC requests to finish. If they don't finish
+// before ctx is done, the server is stopped right away.
+func (as *AuthServer) stopGRPCServer(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		as.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		as.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
+
+// startBackgroundJobs starts the jobs that run for as long as the server does:
+// the nonce and session cleanout and, with TLS, the certificate reloads. They're
+// cancelled by Shutdown.
+func (as *AuthServer) startBackgroundJobs() {
+	ctx, cancel := context.WithCancel(context.Background())
+	as.stopJobs = cancel
+
+	as.runJob(func() { as.scheduleNonceCleanout(ctx) })
+
+	if as.certificates != nil {
+		as.runJob(func() { as.certificates.Watch(ctx, as.logCertificateReload) })
+	}
+}
+
+// logCertificateReload logs the result of reloading the TLS certificates after
+// their files changed
+func (as *AuthServer) logCertificateReload(reloadErr error) {
+	if reloadErr != nil {
+		as.AuthController.Logger().Error("error reloading the TLS certificates", "error", reloadErr)
+		return
+	}
+
+	as.AuthController.Logger().Info("reloaded the TLS certificates")
+}
+
+// runJob runs a background job in its own goroutine. Shutdown waits for it to
+// return.
+func (as *AuthServer) runJob(job func()) {
+	if as.jobs == nil {
+		as.jobs = &sync.WaitGroup{}
+	}
+
+	as.jobs.Add(1)
+
+	go func() {
+		defer as.jobs.Done()
+		job()
+	}()
+}
+
+// stopBackgroundJobs cancels the background jobs and waits for them to return,
+// or for ctx to be done
+func (as *AuthServer) stopBackgroundJobs(ctx context.Context) error {
+	if as.stopJobs != nil {
+		as.stopJobs()
+	}
+
+	if as.jobs == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		as.jobs.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,232 @@
+package authServerTest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_AuditChain(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	publicKey, _ := authCrypto.GetRSAPublicKey()
+	adminClaims := &authCrypto.JWTClaims{Admin: true}
+
+	addEvents := func(ac *authServer.AuthController, count int) {
+		for i := 0; i < count; i++ {
+			ac.EditUser(&authServer.EditUserBody{Id: "456", Nonce: "YWJj"}, adminClaims, mocks.MakeTestContext())
+		}
+	}
+
+	t.Run("Audit events are linked to the previous event", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+		addEvents(&as.AuthController, 3)
+
+		events := tdbc.AuditEvents()
+		if events[0].Sequence != 1 || len(events[0].PreviousHash) > 0 {
+			t.Fatalf("the first event should start the chain")
+		}
+		if events[2].Sequence != 3 || events[2].PreviousHash != events[1].Hash {
+			t.Fatalf("the events should be linked")
+		}
+		if err := authServer.VerifyAuditChain(events, publicKey, true); err != nil {
+			t.Fatalf("the chain should be intact, got %v", err)
+		}
+	})
+
+	t.Run("The chain continues from the newest stored event", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		addEvents(&makeTestServer(tdbc).AuthController, 2)
+		addEvents(&makeTestServer(tdbc).AuthController, 1)
+
+		events := tdbc.AuditEvents()
+		if events[2].Sequence != 3 || events[2].PreviousHash != events[1].Hash {
+			t.Fatalf("a new controller should continue the chain")
+		}
+	})
+
+	t.Run("Events aren't stored if the end of the chain can't be loaded", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetAuditEventsErr(dbController.NewDBError("database error"))
+		addEvents(&makeTestServer(tdbc).AuthController, 1)
+
+		if events := tdbc.AuditEvents(); len(events) != 0 {
+			t.Fatalf("no event should be stored, got %v", events)
+		}
+	})
+
+	t.Run("The chain is reloaded when another controller stored the next event", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		first := makeTestServer(tdbc)
+		second := makeTestServer(tdbc)
+
+		addEvents(&first.AuthController, 1)
+		addEvents(&second.AuthController, 1)
+		// The first controller's chain still ends at sequence 1
+		addEvents(&first.AuthController, 1)
+
+		events := tdbc.AuditEvents()
+		if len(events) != 3 || events[2].Sequence != 3 {
+			t.Fatalf("the event should be stored after the second controller's, got %v", events)
+		}
+		if err := authServer.VerifyAuditChain(events, publicKey, true); err != nil {
+			t.Fatalf("the chain should be intact, got %v", err)
+		}
+	})
+
+	t.Run("VerifyAuditChain reports modified events", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		addEvents(&makeTestServer(tdbc).AuthController, 3)
+
+		events := tdbc.AuditEvents()
+		events[1].Outcome = authUtils.AuditFailure
+
+		err := authServer.VerifyAuditChain(events, publicKey, true)
+		if chainErr, ok := err.(authServer.AuditChainError); !ok || chainErr.Sequence != 2 {
+			t.Fatalf("the second event should be reported, got %v", err)
+		}
+	})
+
+	t.Run("VerifyAuditChain reports removed events", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		addEvents(&makeTestServer(tdbc).AuthController, 3)
+
+		events := tdbc.AuditEvents()
+		events = append(events[:1], events[2:]...)
+
+		err := authServer.VerifyAuditChain(events, publicKey, true)
+		if chainErr, ok := err.(authServer.AuditChainError); !ok || chainErr.Sequence != 2 {
+			t.Fatalf("the second event should be reported, got %v", err)
+		}
+	})
+
+	t.Run("VerifyAuditChain reports a missing first event if the chain must start at sequence 1", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		addEvents(&makeTestServer(tdbc).AuthController, 3)
+
+		events := tdbc.AuditEvents()[1:]
+
+		err := authServer.VerifyAuditChain(events, publicKey, true)
+		if chainErr, ok := err.(authServer.AuditChainError); !ok || chainErr.Sequence != 1 {
+			t.Fatalf("the missing first event should be reported, got %v", err)
+		}
+		if err := authServer.VerifyAuditChain(events, publicKey, false); err != nil {
+			t.Fatalf("a partial chain should be intact, got %v", err)
+		}
+	})
+
+	t.Run("VerifyAuditChain reports rewritten chains with invalid checkpoints", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		addEvents(&makeTestServer(tdbc).AuthController, authServer.AuditCheckpointInterval)
+
+		events := tdbc.AuditEvents()
+		checkpoint := events[authServer.AuditCheckpointInterval-1]
+		if len(checkpoint.Checkpoint) == 0 {
+			t.Fatalf("the event should be signed")
+		}
+		if err := authServer.VerifyAuditChain(events, publicKey, true); err != nil {
+			t.Fatalf("the chain should be intact, got %v", err)
+		}
+
+		// Rewriting an event and every hash after it leaves the old signature
+		events[0].Reason = "rewritten"
+		for i := range events {
+			if i > 0 {
+				events[i].PreviousHash = events[i-1].Hash
+			}
+			events[i].Hash = events[i].ComputeHash()
+		}
+
+		err := authServer.VerifyAuditChain(events, publicKey, true)
+		if chainErr, ok := err.(authServer.AuditChainError); !ok || chainErr.Sequence != int64(authServer.AuditCheckpointInterval) {
+			t.Fatalf("the checkpoint should be reported, got %v", err)
+		}
+	})
+
+	t.Run("The chain can be verified from a FileLogger log file", func(t *testing.T) {
+		dir := t.TempDir()
+		fileLogger, _ := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{})
+		var logger authUtils.AuthLogger = fileLogger
+
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+		as.AuthController.AddLogger(&logger)
+
+		addEvents(&as.AuthController, 3)
+		as.AuthController.AddInfoLog(&authUtils.InfoLogData{Type: "error", Message: "not an audit event"})
+		fileLogger.Close()
+
+		file, _ := os.Open(filepath.Join(dir, "logs.log"))
+		defer file.Close()
+
+		events, readErr := authServer.ReadAuditLog(file)
+		if readErr != nil || len(events) != 3 {
+			t.Fatalf("the audit events should be read, got %v", readErr)
+		}
+		if err := authServer.VerifyAuditChain(events, publicKey, true); err != nil {
+			t.Fatalf("the chain should be intact, got %v", err)
+		}
+	})
+
+	t.Run("Events that can't be stored don't break the chain in the log file", func(t *testing.T) {
+		dir := t.TempDir()
+		fileLogger, _ := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{})
+		var logger authUtils.AuthLogger = fileLogger
+
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+		as.AuthController.AddLogger(&logger)
+
+		addEvents(&as.AuthController, 1)
+
+		// The first attempt fails and the event is stored by the retry
+		tdbc.SetAuditFailures(1)
+		addEvents(&as.AuthController, 1)
+
+		// Every attempt fails and the event is logged as unstored
+		tdbc.SetAuditFailures(3)
+		addEvents(&as.AuthController, 1)
+
+		addEvents(&as.AuthController, 1)
+		fileLogger.Close()
+
+		content, _ := os.ReadFile(filepath.Join(dir, "logs.log"))
+		if !strings.Contains(string(content), `"audit_unstored"`) {
+			t.Fatalf("the unstored event should be logged")
+		}
+
+		file, _ := os.Open(filepath.Join(dir, "logs.log"))
+		defer file.Close()
+
+		events, readErr := authServer.ReadAuditLog(file)
+		if readErr != nil || len(events) != 3 {
+			t.Fatalf("only the stored events should be read, got %d events and %v", len(events), readErr)
+		}
+		if err := authServer.VerifyAuditChain(events, publicKey, true); err != nil {
+			t.Fatalf("the chain should be intact, got %v", err)
+		}
+	})
+
+	t.Run("Stored events keep their hash", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		addEvents(&makeTestServer(tdbc).AuthController, 1)
+
+		// Times are stored with millisecond precision and may be read back in
+		// another time zone
+		event := tdbc.AuditEvents()[0]
+		event.Timestamp = event.Timestamp.Local()
+
+		if event.ComputeHash() != event.Hash {
+			t.Fatalf("the hash should not depend on the time zone")
+		}
+	})
+}
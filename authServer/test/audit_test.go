@@ -0,0 +1,184 @@
+package authServerTest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_AuditEvents(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	adminToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "admin", Admin: true})
+	adminClaims, _ := authCrypto.ValidateJWT(adminToken)
+
+	t.Run("Failed logins are audited with the reason", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDocErr(dbController.NewNoResultsError("no user"))
+		as := makeTestServer(tdbc)
+
+		as.AuthController.LogUserIn(authServer.LoginBody{Username: "Someone", Password: "password", Nonce: "YWJj"}, mocks.MakeTestContext())
+
+		events := tdbc.AuditEvents()
+		if len(events) != 1 {
+			t.Fatalf("one event should be added, got %d", len(events))
+		}
+		if events[0].Action != authUtils.AuditLogin || events[0].Outcome != authUtils.AuditFailure || events[0].Reason != "no user" {
+			t.Fatalf("a failed login should be added")
+		}
+		if events[0].TargetUsername != "someone" || len(events[0].ActorId) > 0 {
+			t.Fatalf("the normalized username should be the target and there should be no actor")
+		}
+	})
+
+	t.Run("Edits by admins are audited with the admin as the actor", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+
+		as.AuthController.EditUser(&authServer.EditUserBody{Id: "456", Nonce: "YWJj"}, adminClaims, mocks.MakeTestContext())
+
+		events := tdbc.AuditEvents()
+		if len(events) != 1 || events[0].Action != authUtils.AuditEditUser || events[0].Outcome != authUtils.AuditSuccess {
+			t.Fatalf("a successful edit should be added")
+		}
+		if events[0].ActorId != "123" || events[0].TargetId != "456" {
+			t.Fatalf("the admin should be the actor and the user the target")
+		}
+	})
+
+	t.Run("Password changes are audited", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+
+		as.AuthController.EditUserPassword(&authServer.EditPasswordBody{Id: "456", Nonce: "YWJj", NewPassword: "password"}, adminClaims, mocks.MakeTestContext())
+
+		events := tdbc.AuditEvents()
+		if len(events) != 1 || events[0].Action != authUtils.AuditEditPassword {
+			t.Fatalf("a password change should be added")
+		}
+	})
+
+	t.Run("Deleted users are audited", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+
+		as.AuthController.DeleteUser("456", "YWJj", adminClaims, mocks.MakeTestContext())
+
+		events := tdbc.AuditEvents()
+		if len(events) != 1 || events[0].Action != authUtils.AuditDeleteUser || events[0].TargetId != "456" {
+			t.Fatalf("a deleted user should be added")
+		}
+	})
+
+	t.Run("Revoked sessions are audited", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(dbController.SessionDocument{Id: "abc", UserId: "456"})
+		as := makeTestServer(tdbc)
+
+		as.AuthController.RevokeSession("456", "abc", "YWJj", adminClaims, mocks.MakeTestContext())
+
+		events := tdbc.AuditEvents()
+		if len(events) != 1 || events[0].Action != authUtils.AuditRevokeSession || events[0].Outcome != authUtils.AuditSuccess {
+			t.Fatalf("a revoked session should be added")
+		}
+	})
+
+	t.Run("Failed impersonations are audited", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDocErr(dbController.NewNoResultsError("no user"))
+		as := makeTestServer(tdbc)
+
+		as.AuthController.ImpersonateUser("456", "YWJj", adminClaims, mocks.MakeTestContext())
+
+		events := tdbc.AuditEvents()
+		if len(events) != 1 || events[0].Action != authUtils.AuditImpersonate || events[0].Outcome != authUtils.AuditFailure {
+			t.Fatalf("a failed impersonation should be added")
+		}
+		if events[0].TargetId != "456" {
+			t.Fatalf("the user should be the target")
+		}
+	})
+
+	t.Run("Failing to store an event doesn't fail the action", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetAuditEventsErr(dbController.NewDBError("database error"))
+		as := makeTestServer(tdbc)
+
+		err := as.AuthController.EditUser(&authServer.EditUserBody{Id: "456", Nonce: "YWJj"}, adminClaims, mocks.MakeTestContext())
+		if err != nil {
+			t.Fatalf("the edit should succeed, got %v", err)
+		}
+	})
+
+	request := func(as *authServer.AuthServer, token string, query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/audit"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	t.Run("GET /audit returns the audit events", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+		as.AuthController.EditUser(&authServer.EditUserBody{Id: "456", Nonce: "YWJj"}, adminClaims, mocks.MakeTestContext())
+
+		recorder := request(as, adminToken, "?target=456&since=2021-01-01T00:00:00Z")
+
+		var events []authUtils.AuditEvent
+		json.Unmarshal(recorder.Body.Bytes(), &events)
+
+		if recorder.Code != http.StatusOK || len(events) != 1 {
+			t.Fatalf("the events should be returned, got %d", recorder.Code)
+		}
+
+		filter := tdbc.LastAuditFilter()
+		if filter.TargetId != "456" || filter.Since.Year() != 2021 {
+			t.Fatalf("the filters should be passed to the database")
+		}
+	})
+
+	t.Run("GET /audit limits the number of events", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+
+		request(as, adminToken, "")
+		if tdbc.LastAuditFilter().Limit != 100 {
+			t.Fatalf("the default limit should be used")
+		}
+
+		request(as, adminToken, "?limit=100000")
+		if tdbc.LastAuditFilter().Limit != 1000 {
+			t.Fatalf("the maximum limit should be used")
+		}
+	})
+
+	t.Run("GET /audit rejects invalid times", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+
+		recorder := request(as, adminToken, "?since=yesterday")
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("status code should be 400, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("GET /audit requires an admin token", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+		userToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "456", Username: "user"})
+
+		recorder := request(as, userToken, "")
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403, got %d", recorder.Code)
+		}
+	})
+}
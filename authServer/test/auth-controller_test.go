@@ -264,6 +264,9 @@ func Test_LogUserIn(t *testing.T) {
 		}
 	})
 	t.Run("LogUserIn fails if GenerateJWT fails", func(t *testing.T) {
+		// Other tests may have set the keys already
+		os.Unsetenv(constants.RSA_PRIVATE_KEY)
+
 		username, password, email := "test", "test", "test"
 
 		hashedPass, _ := authUtils.HashPassword(password)
@@ -552,6 +555,31 @@ func Test_EditUser(t *testing.T) {
 		}
 	})
 
+	t.Run("If the attributes do not match the attribute schema, EditUser will return an InvalidInputError", func(t *testing.T) {
+		resetEnvVariables()
+
+		tdbc := mocks.MakeBlankTestDbController()
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		ctx := mocks.MakeTestContext()
+
+		editUserErr := ac.EditUser(&authServer.EditUserBody{
+			Attributes: map[string]interface{}{
+				"favoriteColor": "blue",
+			},
+		}, &authCrypto.JWTClaims{}, ctx)
+
+		if editUserErr == nil {
+			t.Fatalf("editUserErr should not be nil.")
+		}
+
+		if _, ok := editUserErr.(dbController.InvalidInputError); !ok {
+			t.Fatalf(fmt.Sprint("editUserErr should be an InvalidInputError. ", editUserErr.Error()))
+		}
+	})
+
 	t.Run("If ac.DBController.EditUser fails, EditUser will return the same error", func(t *testing.T) {
 		resetEnvVariables()
 
@@ -595,6 +623,27 @@ func Test_EditUser(t *testing.T) {
 			t.Fatalf(fmt.Sprint("editUserErr should be a DBError. it's a ", errType, ". ", editUserErr.Error()))
 		}
 	})
+
+	t.Run("Disabling a user rejects their existing tokens", func(t *testing.T) {
+		resetEnvVariables()
+		mocks.PrepTestRSAKeys()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		token := activeSessionToken(&tdbc, "123")
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		enabled := false
+		adminClaims := &authCrypto.JWTClaims{Admin: true, StandardClaims: jwt.StandardClaims{Subject: "456"}}
+		if editErr := ac.EditUser(&authServer.EditUserBody{Id: "123", Enabled: &enabled}, adminClaims, mocks.MakeTestContext()); editErr != nil {
+			t.Fatalf(fmt.Sprint("editErr should be nil. Current error: ", editErr.Error()))
+		}
+
+		if _, validateErr := ac.ValidateToken(token); validateErr == nil {
+			t.Fatalf("the disabled user's token should be rejected")
+		}
+	})
 }
 
 func Test_EditUserPassword(t *testing.T) {
@@ -1000,6 +1049,191 @@ func Test_EditUserPassword(t *testing.T) {
 	})
 }
 
+func Test_GetUser(t *testing.T) {
+	userDoc := dbController.FullUserDocument{
+		Id:           "123",
+		Username:     "user",
+		PasswordHash: "hash",
+	}
+
+	t.Run("Users can get their own data, without the password hash", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDoc(userDoc)
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		claims := &authCrypto.JWTClaims{StandardClaims: jwt.StandardClaims{Subject: "123"}}
+		user, userErr := ac.GetUser("123", claims, mocks.MakeTestContext())
+
+		if userErr != nil {
+			t.Fatalf(fmt.Sprint("userErr should be nil. Current error: ", userErr.Error()))
+		}
+
+		if user.Id != "123" || user.Username != "user" {
+			t.Fatalf("the user's data should be returned")
+		}
+	})
+
+	t.Run("If we are not an admin and the IDs do not match, GetUser will return an UnauthorizedError", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDoc(userDoc)
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		claims := &authCrypto.JWTClaims{StandardClaims: jwt.StandardClaims{Subject: "456"}}
+		_, userErr := ac.GetUser("123", claims, mocks.MakeTestContext())
+
+		if _, ok := userErr.(authServer.UnauthorizedError); !ok {
+			t.Fatalf("userErr should be an UnauthorizedError")
+		}
+	})
+
+	t.Run("If we are an admin, the IDs do not have to match", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDoc(userDoc)
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		claims := &authCrypto.JWTClaims{Admin: true, StandardClaims: jwt.StandardClaims{Subject: "456"}}
+		if _, userErr := ac.GetUser("123", claims, mocks.MakeTestContext()); userErr != nil {
+			t.Fatalf(fmt.Sprint("userErr should be nil. Current error: ", userErr.Error()))
+		}
+	})
+
+	t.Run("If ac.DBController.GetUserById fails, GetUser will return the same error", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDocErr(dbController.NewNoResultsError(""))
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		_, userErr := ac.GetUser("123", &authCrypto.JWTClaims{Admin: true}, mocks.MakeTestContext())
+
+		if _, ok := userErr.(dbController.NoResultsError); !ok {
+			t.Fatalf("userErr should be a NoResultsError")
+		}
+	})
+}
+
+// activeSessionToken sets an active session for the user in the test database
+// controller and returns a token issued for it
+func activeSessionToken(tdbc *mocks.TestDbController, userId string) string {
+	tdbc.SetSessionDoc(dbController.SessionDocument{
+		Id:         "abc",
+		UserId:     userId,
+		CreatedAt:  time.Now().Unix(),
+		LastSeenAt: time.Now().Unix(),
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+	})
+
+	token, _ := authCrypto.GenerateSessionJWT(dbController.UserDocument{Id: userId, Username: "user"}, "", nil, "abc")
+
+	return token
+}
+
+func Test_DeleteUser(t *testing.T) {
+	adminClaims := &authCrypto.JWTClaims{Admin: true, StandardClaims: jwt.StandardClaims{Subject: "456"}}
+
+	t.Run("Admins can delete other users", func(t *testing.T) {
+		resetEnvVariables()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		if deleteErr := ac.DeleteUser("123", "", adminClaims, mocks.MakeTestContext()); deleteErr != nil {
+			t.Fatalf(fmt.Sprint("deleteErr should be nil. Current error: ", deleteErr.Error()))
+		}
+	})
+
+	t.Run("Deleting a user rejects their existing tokens", func(t *testing.T) {
+		resetEnvVariables()
+		mocks.PrepTestRSAKeys()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		token := activeSessionToken(&tdbc, "123")
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		if _, validateErr := ac.ValidateToken(token); validateErr != nil {
+			t.Fatalf(fmt.Sprint("the token should be accepted before the delete. Current error: ", validateErr.Error()))
+		}
+
+		if deleteErr := ac.DeleteUser("123", "", adminClaims, mocks.MakeTestContext()); deleteErr != nil {
+			t.Fatalf(fmt.Sprint("deleteErr should be nil. Current error: ", deleteErr.Error()))
+		}
+
+		if _, validateErr := ac.ValidateToken(token); validateErr == nil {
+			t.Fatalf("the deleted user's token should be rejected")
+		}
+	})
+
+	t.Run("If we provide an improper nonce, we should receive a NonceError", func(t *testing.T) {
+		resetEnvVariables()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetNonceDocErr(authUtils.NewNonceError("test error"))
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		deleteErr := ac.DeleteUser("123", "", adminClaims, mocks.MakeTestContext())
+
+		if _, ok := deleteErr.(authUtils.NonceError); !ok {
+			t.Fatalf("deleteErr should be a NonceError")
+		}
+	})
+
+	t.Run("If we are not an admin, DeleteUser will return an UnauthorizedError", func(t *testing.T) {
+		resetEnvVariables()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		claims := &authCrypto.JWTClaims{StandardClaims: jwt.StandardClaims{Subject: "123"}}
+		deleteErr := ac.DeleteUser("123", "", claims, mocks.MakeTestContext())
+
+		if _, ok := deleteErr.(authServer.UnauthorizedError); !ok {
+			t.Fatalf("deleteErr should be an UnauthorizedError")
+		}
+	})
+
+	t.Run("Admins cannot delete themselves", func(t *testing.T) {
+		resetEnvVariables()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		deleteErr := ac.DeleteUser("456", "", adminClaims, mocks.MakeTestContext())
+
+		if _, ok := deleteErr.(dbController.InvalidInputError); !ok {
+			t.Fatalf("deleteErr should be an InvalidInputError")
+		}
+	})
+
+	t.Run("If ac.DBController.DeleteUser fails, DeleteUser will return the same error", func(t *testing.T) {
+		resetEnvVariables()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetDeleteUserError(dbController.NewNoResultsError(""))
+
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		deleteErr := ac.DeleteUser("123", "", adminClaims, mocks.MakeTestContext())
+
+		if _, ok := deleteErr.(dbController.NoResultsError); !ok {
+			t.Fatalf("deleteErr should be a NoResultsError")
+		}
+	})
+}
+
 func Test_CheckNonceHash(t *testing.T) {
 	t.Run("CheckNonceHash fails if GetNonce fails", func(t *testing.T) {
 		tdbc := mocks.MakeBlankTestDbController()
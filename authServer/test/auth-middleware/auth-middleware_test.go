@@ -0,0 +1,199 @@
+package authMiddlewareTest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+	"methompson.com/auth-microservice/authServer/jwtVerifier"
+)
+
+// The test validator accepts the tokens "user", "admin", "impersonated" and
+// "scoped", reports "expired" as an expired token and fails to check
+// "unavailable".
+var testValidator = authMiddleware.ValidatorFunc(func(token string) (*jwtVerifier.JWTClaims, error) {
+	switch token {
+	case "user":
+		return &jwtVerifier.JWTClaims{Username: "user"}, nil
+	case "admin":
+		return &jwtVerifier.JWTClaims{Username: "admin", Admin: true}, nil
+	case "impersonated":
+		return &jwtVerifier.JWTClaims{Username: "impersonated", Admin: true, Actor: &jwtVerifier.ActorClaim{Subject: "1"}}, nil
+	case "scoped":
+		return &jwtVerifier.JWTClaims{Username: "scoped", Scope: "read write"}, nil
+	case "expired":
+		return nil, jwtVerifier.NewExpiredJWTError("token is expired")
+	case "unavailable":
+		return nil, errors.New("server selection timeout")
+	}
+
+	return nil, jwtVerifier.NewJWTError("invalid token")
+})
+
+func makeEngine(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+
+	handlers = append(handlers, func(ctx *gin.Context) {
+		claims, ok := authMiddleware.GetClaims(ctx)
+		if !ok {
+			ctx.String(http.StatusOK, "")
+			return
+		}
+
+		ctx.String(http.StatusOK, claims.Username)
+	})
+
+	engine.GET("/", handlers...)
+
+	return engine
+}
+
+func makeRequest(engine *gin.Engine, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/", nil)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", token)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func Test_RequireAuth(t *testing.T) {
+	engine := makeEngine(authMiddleware.RequireAuth(testValidator))
+
+	t.Run("RequireAuth stores the claims of a valid token in the context", func(t *testing.T) {
+		recorder := makeRequest(engine, "user")
+
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "user" {
+			t.Fatalf("request should succeed with the user's claims")
+		}
+	})
+
+	t.Run("RequireAuth returns a 401 response if there is no token", func(t *testing.T) {
+		recorder := makeRequest(engine, "")
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("status code should be 401")
+		}
+	})
+
+	t.Run("RequireAuth returns a 401 response for invalid tokens", func(t *testing.T) {
+		recorder := makeRequest(engine, "invalid")
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("status code should be 401")
+		}
+	})
+
+	t.Run("RequireAuth returns a 401 response for expired tokens", func(t *testing.T) {
+		recorder := makeRequest(engine, "expired")
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("status code should be 401")
+		}
+	})
+
+	t.Run("RequireAuth responds with an expired_token problem for expired tokens", func(t *testing.T) {
+		recorder := makeRequest(engine, "expired")
+
+		if recorder.Header().Get("Content-Type") != apiErrors.ContentType {
+			t.Fatalf("content type should be " + apiErrors.ContentType)
+		}
+
+		var problem apiErrors.Problem
+		if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("body should be a problem: " + err.Error())
+		}
+
+		if problem.Code != apiErrors.ExpiredToken || problem.Status != http.StatusUnauthorized || problem.Instance != "/" {
+			t.Fatalf("problem should describe an expired token")
+		}
+	})
+
+	t.Run("RequireAuth returns a 503 response without the error if the token can't be checked", func(t *testing.T) {
+		recorder := makeRequest(engine, "unavailable")
+
+		var problem apiErrors.Problem
+		json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+		if recorder.Code != http.StatusServiceUnavailable || problem.Code != apiErrors.ServiceUnavailable {
+			t.Fatalf("status code should be 503, got %d", recorder.Code)
+		}
+		if len(problem.Detail) > 0 {
+			t.Fatalf("the error should not be sent, got %s", problem.Detail)
+		}
+	})
+}
+
+func Test_RequireAdmin(t *testing.T) {
+	engine := makeEngine(authMiddleware.RequireAuth(testValidator), authMiddleware.RequireAdmin())
+
+	t.Run("RequireAdmin allows admin tokens", func(t *testing.T) {
+		recorder := makeRequest(engine, "admin")
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status code should be 200")
+		}
+	})
+
+	t.Run("RequireAdmin returns a 403 response for non-admin tokens", func(t *testing.T) {
+		recorder := makeRequest(engine, "user")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403")
+		}
+	})
+
+	t.Run("RequireAdmin returns a 403 response for impersonation tokens", func(t *testing.T) {
+		recorder := makeRequest(engine, "impersonated")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403")
+		}
+	})
+
+	t.Run("RequireAdmin returns a 401 response if RequireAuth didn't run", func(t *testing.T) {
+		recorder := makeRequest(makeEngine(authMiddleware.RequireAdmin()), "admin")
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("status code should be 401")
+		}
+	})
+}
+
+func Test_RequirePermission(t *testing.T) {
+	engine := makeEngine(authMiddleware.RequireAuth(testValidator), authMiddleware.RequirePermission("write"))
+
+	t.Run("RequirePermission allows tokens with the scope", func(t *testing.T) {
+		recorder := makeRequest(engine, "scoped")
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status code should be 200")
+		}
+	})
+
+	t.Run("RequirePermission allows admin tokens", func(t *testing.T) {
+		recorder := makeRequest(engine, "admin")
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status code should be 200")
+		}
+	})
+
+	t.Run("RequirePermission returns a 403 response for tokens without the scope", func(t *testing.T) {
+		recorder := makeRequest(engine, "user")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403")
+		}
+	})
+}
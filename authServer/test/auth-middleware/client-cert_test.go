@@ -0,0 +1,93 @@
+package authMiddlewareTest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+)
+
+// makeCertRequest makes a request with the token. verified sets whether the
+// connection presented a verified client certificate.
+func makeCertRequest(engine *gin.Engine, token string, verified bool) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", token)
+	req.TLS = &tls.ConnectionState{}
+
+	if verified {
+		req.TLS.VerifiedChains = [][]*x509.Certificate{{{}}}
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func problemCode(recorder *httptest.ResponseRecorder) string {
+	var problem apiErrors.Problem
+	json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+	return problem.Code
+}
+
+func Test_RequireClientCert(t *testing.T) {
+	engine := makeEngine(authMiddleware.RequireClientCert())
+
+	t.Run("RequireClientCert allows requests with a verified client certificate", func(t *testing.T) {
+		if recorder := makeCertRequest(engine, "", true); recorder.Code != http.StatusOK {
+			t.Fatalf("status code should be 200, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("RequireClientCert returns a 403 response without a verified client certificate", func(t *testing.T) {
+		recorder := makeCertRequest(engine, "", false)
+
+		if recorder.Code != http.StatusForbidden || problemCode(recorder) != apiErrors.ClientCertRequired {
+			t.Fatalf("expected a client_certificate_required problem, got %d %s", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("RequireClientCert returns a 403 response for requests without TLS", func(t *testing.T) {
+		recorder := makeRequest(engine, "")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403, got %d", recorder.Code)
+		}
+	})
+}
+
+func Test_RequireAdminWithClientCert(t *testing.T) {
+	engine := makeEngine(authMiddleware.RequireAuth(testValidator), authMiddleware.RequireAdminWithClientCert())
+
+	t.Run("RequireAdminWithClientCert allows admins with a verified client certificate", func(t *testing.T) {
+		recorder := makeCertRequest(engine, "admin", true)
+
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "admin" {
+			t.Fatalf("request should succeed with the admin's claims")
+		}
+	})
+
+	t.Run("RequireAdminWithClientCert rejects admins without a client certificate", func(t *testing.T) {
+		recorder := makeCertRequest(engine, "admin", false)
+
+		if recorder.Code != http.StatusForbidden || problemCode(recorder) != apiErrors.ClientCertRequired {
+			t.Fatalf("expected a client_certificate_required problem, got %d %s", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("RequireAdminWithClientCert rejects users with a client certificate", func(t *testing.T) {
+		recorder := makeCertRequest(engine, "user", true)
+
+		if recorder.Code != http.StatusForbidden || problemCode(recorder) != apiErrors.Forbidden {
+			t.Fatalf("expected a forbidden problem, got %d %s", recorder.Code, recorder.Body.String())
+		}
+	})
+}
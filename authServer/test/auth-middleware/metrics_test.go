@@ -0,0 +1,73 @@
+package authMiddlewareTest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"methompson.com/auth-microservice/authServer/authMetrics"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+)
+
+func makeMetricsRequest(path string) {
+	engine := gin.New()
+	engine.Use(authMiddleware.Metrics())
+	engine.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusAccepted)
+	})
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+// sampleCount returns the number of values observed by a histogram
+func sampleCount(t *testing.T, histogram prometheus.Observer) uint64 {
+	var metric dto.Metric
+	if writeErr := histogram.(prometheus.Metric).Write(&metric); writeErr != nil {
+		t.Fatalf("error reading histogram: %s", writeErr.Error())
+	}
+
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func Test_Metrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Metrics counts requests by route pattern and status", func(t *testing.T) {
+		counter := authMetrics.HTTPRequests.WithLabelValues(http.MethodGet, "/users/:id", "202")
+		before := testutil.ToFloat64(counter)
+
+		makeMetricsRequest("/users/123")
+		makeMetricsRequest("/users/456")
+
+		if count := testutil.ToFloat64(counter) - before; count != 2 {
+			t.Fatalf("expected 2 requests to be counted, got %v", count)
+		}
+	})
+
+	t.Run("Metrics observes the duration of requests", func(t *testing.T) {
+		histogram := authMetrics.HTTPRequestDuration.WithLabelValues(http.MethodGet, "/users/:id", "202")
+		before := sampleCount(t, histogram)
+
+		makeMetricsRequest("/users/123")
+
+		if count := sampleCount(t, histogram) - before; count != 1 {
+			t.Fatalf("expected 1 duration to be observed, got %v", count)
+		}
+	})
+
+	t.Run("Metrics labels requests that match no route as unmatched", func(t *testing.T) {
+		counter := authMetrics.HTTPRequests.WithLabelValues(http.MethodGet, authMetrics.UnmatchedRoute, "404")
+		before := testutil.ToFloat64(counter)
+
+		makeMetricsRequest("/unknown/path")
+
+		if count := testutil.ToFloat64(counter) - before; count != 1 {
+			t.Fatalf("expected 1 unmatched request to be counted, got %v", count)
+		}
+	})
+}
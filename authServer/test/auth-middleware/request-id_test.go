@@ -0,0 +1,74 @@
+package authMiddlewareTest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+)
+
+func makeRequestIdRequest(requestId string) (*httptest.ResponseRecorder, string) {
+	var stored string
+
+	engine := gin.New()
+	engine.Use(authMiddleware.RequestId())
+	engine.GET("/", func(ctx *gin.Context) {
+		stored = authMiddleware.GetRequestId(ctx)
+		ctx.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if len(requestId) > 0 {
+		req.Header.Set(authMiddleware.RequestIdHeader, requestId)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	return recorder, stored
+}
+
+func Test_RequestId(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("RequestId keeps the id sent by the client", func(t *testing.T) {
+		recorder, stored := makeRequestIdRequest("client-id-123")
+
+		if stored != "client-id-123" {
+			t.Fatalf("the client's id should be stored, got " + stored)
+		}
+		if recorder.Header().Get(authMiddleware.RequestIdHeader) != "client-id-123" {
+			t.Fatalf("the client's id should be returned")
+		}
+	})
+
+	t.Run("RequestId generates an id if the client doesn't send one", func(t *testing.T) {
+		recorder, stored := makeRequestIdRequest("")
+
+		if len(stored) != 32 {
+			t.Fatalf("a 32 character id should be generated, got " + stored)
+		}
+		if recorder.Header().Get(authMiddleware.RequestIdHeader) != stored {
+			t.Fatalf("the generated id should be returned")
+		}
+
+		_, other := makeRequestIdRequest("")
+		if other == stored {
+			t.Fatalf("every request should get a different id")
+		}
+	})
+
+	t.Run("RequestId replaces ids that are too long or contain control characters", func(t *testing.T) {
+		for _, requestId := range []string{strings.Repeat("a", 129), "abc\tdef"} {
+			_, stored := makeRequestIdRequest(requestId)
+
+			if stored == requestId || len(stored) != 32 {
+				t.Fatalf("'%s' should be replaced, got %s", requestId, stored)
+			}
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package authMiddlewareTest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+)
+
+func Test_ParseBearerToken(t *testing.T) {
+	t.Run("ParseBearerToken accepts the Bearer scheme regardless of case", func(t *testing.T) {
+		for _, header := range []string{"Bearer abc.def-ghi_jkl", "bearer abc.def-ghi_jkl", "BEARER  abc.def-ghi_jkl"} {
+			token, ok := authMiddleware.ParseBearerToken(header)
+
+			if !ok || token != "abc.def-ghi_jkl" {
+				t.Fatalf("'" + header + "' should be parsed")
+			}
+		}
+	})
+
+	t.Run("ParseBearerToken rejects other schemes and malformed tokens", func(t *testing.T) {
+		for _, header := range []string{"Basic abc", "abc", "Bearer", "Bearer abc def", "Bearer a=b"} {
+			if _, ok := authMiddleware.ParseBearerToken(header); ok {
+				t.Fatalf("'" + header + "' should not be parsed")
+			}
+		}
+	})
+}
+
+func Test_ParseTokenSources(t *testing.T) {
+	t.Run("ParseTokenSources parses a list of sources", func(t *testing.T) {
+		sources, err := authMiddleware.ParseTokenSources("header, Cookie")
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		if len(sources) != 2 || sources[0] != authMiddleware.HeaderSource || sources[1] != authMiddleware.CookieSource {
+			t.Fatalf("sources were not parsed correctly")
+		}
+	})
+
+	t.Run("ParseTokenSources returns the default sources for an empty string", func(t *testing.T) {
+		sources, _ := authMiddleware.ParseTokenSources("")
+
+		if len(sources) != len(authMiddleware.DefaultOptions().Sources) {
+			t.Fatalf("sources should be the default sources")
+		}
+	})
+
+	t.Run("ParseTokenSources rejects unknown sources", func(t *testing.T) {
+		if _, err := authMiddleware.ParseTokenSources("header,query"); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+}
+
+func makeContext(method string, configure func(req *http.Request)) *gin.Context {
+	req, _ := http.NewRequest(method, "/", nil)
+	configure(req)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = req
+
+	return ctx
+}
+
+func Test_ExtractToken(t *testing.T) {
+	t.Run("ExtractToken only accepts raw tokens if the legacy header source is enabled", func(t *testing.T) {
+		ctx := makeContext("GET", func(req *http.Request) {
+			req.Header.Set("Authorization", "user")
+		})
+
+		headerOnly := authMiddleware.NewAuthenticator(testValidator, authMiddleware.Options{
+			Sources: []authMiddleware.TokenSource{authMiddleware.HeaderSource},
+		})
+		if _, err := headerOnly.ExtractToken(ctx); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+
+		legacy := authMiddleware.NewAuthenticator(testValidator, authMiddleware.DefaultOptions())
+		if token, err := legacy.ExtractToken(ctx); err != nil || token != "user" {
+			t.Fatalf("token should be extracted from the legacy header")
+		}
+	})
+
+	cookieAuthenticator := authMiddleware.NewAuthenticator(testValidator, authMiddleware.Options{
+		Sources: []authMiddleware.TokenSource{authMiddleware.CookieSource},
+	})
+
+	t.Run("ExtractToken ignores cookies unless the cookie source is enabled", func(t *testing.T) {
+		ctx := makeContext("GET", func(req *http.Request) {
+			req.AddCookie(&http.Cookie{Name: "auth_token", Value: "user"})
+		})
+
+		authenticator := authMiddleware.NewAuthenticator(testValidator, authMiddleware.DefaultOptions())
+		if _, err := authenticator.ExtractToken(ctx); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+
+	t.Run("ExtractToken accepts cookie tokens for safe methods without a CSRF token", func(t *testing.T) {
+		ctx := makeContext("GET", func(req *http.Request) {
+			req.AddCookie(&http.Cookie{Name: "auth_token", Value: "user"})
+		})
+
+		if token, err := cookieAuthenticator.ExtractToken(ctx); err != nil || token != "user" {
+			t.Fatalf("token should be extracted from the cookie")
+		}
+	})
+
+	t.Run("ExtractToken returns a CSRFError for unsafe methods without a matching CSRF token", func(t *testing.T) {
+		ctx := makeContext("POST", func(req *http.Request) {
+			req.AddCookie(&http.Cookie{Name: "auth_token", Value: "user"})
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "abc"})
+			req.Header.Set("X-CSRF-Token", "def")
+		})
+
+		_, err := cookieAuthenticator.ExtractToken(ctx)
+		if _, ok := err.(authMiddleware.CSRFError); !ok {
+			t.Fatalf("err should be a CSRFError")
+		}
+	})
+
+	t.Run("ExtractToken accepts cookie tokens for unsafe methods with a matching CSRF token", func(t *testing.T) {
+		ctx := makeContext("POST", func(req *http.Request) {
+			req.AddCookie(&http.Cookie{Name: "auth_token", Value: "user"})
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "abc"})
+			req.Header.Set("X-CSRF-Token", "abc")
+		})
+
+		if token, err := cookieAuthenticator.ExtractToken(ctx); err != nil || token != "user" {
+			t.Fatalf("token should be extracted from the cookie")
+		}
+	})
+}
+
+func Test_SetTokenCookies(t *testing.T) {
+	t.Run("SetTokenCookies sets an HttpOnly token cookie and a readable CSRF cookie", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request, _ = http.NewRequest("POST", "/login", nil)
+
+		authenticator := authMiddleware.NewAuthenticator(testValidator, authMiddleware.DefaultOptions())
+		if err := authenticator.SetTokenCookies(ctx, "user", 60); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		cookies := recorder.Result().Cookies()
+		if len(cookies) != 2 {
+			t.Fatalf("two cookies should be set")
+		}
+
+		for _, cookie := range cookies {
+			if cookie.SameSite != http.SameSiteStrictMode || !cookie.Secure {
+				t.Fatalf("cookies should be Secure and SameSite=Strict")
+			}
+
+			if cookie.Name == "auth_token" && (!cookie.HttpOnly || cookie.Value != "user") {
+				t.Fatalf("the token cookie should be HttpOnly")
+			}
+
+			if cookie.Name == "csrf_token" && (cookie.HttpOnly || len(cookie.Value) == 0) {
+				t.Fatalf("the CSRF cookie should be readable")
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package authMiddlewareTest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+)
+
+// makeTracedRequest sends a request through the Tracing middleware and returns
+// the span context seen by the route
+func makeTracedRequest(path string, traceparent string, status int) trace.SpanContext {
+	var routeSpan trace.SpanContext
+
+	engine := gin.New()
+	engine.Use(authMiddleware.Tracing())
+	engine.GET("/users/:id", func(ctx *gin.Context) {
+		routeSpan = trace.SpanContextFromContext(ctx.Request.Context())
+		ctx.Status(status)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if len(traceparent) > 0 {
+		req.Header.Set("traceparent", traceparent)
+	}
+
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	return routeSpan
+}
+
+func Test_Tracing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	lastSpan := func() sdktrace.ReadOnlySpan {
+		spans := recorder.Ended()
+		return spans[len(spans)-1]
+	}
+
+	t.Run("Tracing starts a span named after the route and stores it in the request", func(t *testing.T) {
+		routeSpan := makeTracedRequest("/users/123", "", http.StatusOK)
+
+		span := lastSpan()
+		if span.Name() != "GET /users/:id" {
+			t.Fatalf("expected the span to be named GET /users/:id, got %s", span.Name())
+		}
+
+		if !routeSpan.IsValid() || routeSpan.SpanID() != span.SpanContext().SpanID() {
+			t.Fatalf("expected the route to see the request's span")
+		}
+
+		if span.SpanKind() != trace.SpanKindServer {
+			t.Fatalf("expected a server span, got %s", span.SpanKind())
+		}
+	})
+
+	t.Run("Tracing continues the trace in the traceparent header", func(t *testing.T) {
+		traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		makeTracedRequest("/users/123", traceparent, http.StatusOK)
+
+		span := lastSpan()
+		if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("expected the trace id from the traceparent header, got %s", span.SpanContext().TraceID())
+		}
+
+		if span.Parent().SpanID().String() != "00f067aa0ba902b7" || !span.Parent().IsRemote() {
+			t.Fatalf("expected the span's parent to be the caller's span")
+		}
+	})
+
+	t.Run("Tracing marks server errors", func(t *testing.T) {
+		makeTracedRequest("/users/123", "", http.StatusInternalServerError)
+
+		if lastSpan().Status().Code != codes.Error {
+			t.Fatalf("expected a 500 response to set an error status")
+		}
+
+		makeTracedRequest("/users/123", "", http.StatusNotFound)
+
+		if lastSpan().Status().Code == codes.Error {
+			t.Fatalf("expected a 404 response not to set an error status")
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package authTLSTest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authTLS"
+	"methompson.com/auth-microservice/authServer/constants"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func makeCertificates(t *testing.T) *mocks.TestCertificates {
+	certs, certsErr := mocks.MakeTestCertificates(t.TempDir())
+	if certsErr != nil {
+		t.Fatalf("the certificates should be generated: " + certsErr.Error())
+	}
+
+	return certs
+}
+
+// serveTLS accepts connections with the reloader's configuration and completes
+// their handshakes. It returns the listener's address.
+func serveTLS(t *testing.T, reloader *authTLS.CertificateReloader) string {
+	listener, listenErr := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	if listenErr != nil {
+		t.Fatalf("err should be nil: " + listenErr.Error())
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// dial connects to address and returns the serial number of the server's
+// certificate
+func dial(address string, config *tls.Config) (int64, error) {
+	conn, dialErr := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", address, config)
+	if dialErr != nil {
+		return 0, dialErr
+	}
+	defer conn.Close()
+
+	// With TLS 1.3, a rejected client certificate is only reported on read
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, readErr := conn.Read(make([]byte, 1)); readErr != nil && !errors.Is(readErr, io.EOF) {
+		return 0, readErr
+	}
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func Test_GetTLSOptions(t *testing.T) {
+	setEnv := func(t *testing.T, values map[string]string) {
+		for _, name := range []string{constants.TLS_CERT_FILE, constants.TLS_KEY_FILE, constants.TLS_CLIENT_CA_FILE, constants.TLS_CLIENT_AUTH, constants.TLS_ADMIN_CLIENT_CERT, constants.TLS_RELOAD_INTERVAL} {
+			t.Setenv(name, values[name])
+		}
+	}
+
+	t.Run("TLS is disabled by default", func(t *testing.T) {
+		setEnv(t, map[string]string{})
+
+		options, err := authTLS.GetTLSOptions()
+		if err != nil || options.Enabled() {
+			t.Fatalf("TLS should be disabled, got %v %v", options, err)
+		}
+		if options.ClientAuth != authTLS.ClientAuthOptional || options.ReloadInterval != 10*time.Second {
+			t.Fatalf("unexpected defaults: %v", options)
+		}
+	})
+
+	t.Run("GetTLSOptions reads the options from the environment", func(t *testing.T) {
+		setEnv(t, map[string]string{
+			constants.TLS_CERT_FILE:         "cert.pem",
+			constants.TLS_KEY_FILE:          "key.pem",
+			constants.TLS_CLIENT_CA_FILE:    "ca.pem",
+			constants.TLS_CLIENT_AUTH:       "require",
+			constants.TLS_ADMIN_CLIENT_CERT: "true",
+			constants.TLS_RELOAD_INTERVAL:   "1m",
+		})
+
+		options, err := authTLS.GetTLSOptions()
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		if !options.Enabled() || options.ClientCAFile != "ca.pem" || options.ClientAuth != authTLS.ClientAuthRequire || !options.RequireAdminClientCert || options.ReloadInterval != time.Minute {
+			t.Fatalf("unexpected options: %v", options)
+		}
+	})
+
+	t.Run("GetTLSOptions returns an error for invalid combinations", func(t *testing.T) {
+		invalid := []map[string]string{
+			{constants.TLS_CERT_FILE: "cert.pem"},
+			{constants.TLS_KEY_FILE: "key.pem"},
+			{constants.TLS_CLIENT_CA_FILE: "ca.pem"},
+			{constants.TLS_CERT_FILE: "cert.pem", constants.TLS_KEY_FILE: "key.pem", constants.TLS_CLIENT_AUTH: "always"},
+			{constants.TLS_CERT_FILE: "cert.pem", constants.TLS_KEY_FILE: "key.pem", constants.TLS_ADMIN_CLIENT_CERT: "true"},
+			{constants.TLS_CERT_FILE: "cert.pem", constants.TLS_KEY_FILE: "key.pem", constants.TLS_RELOAD_INTERVAL: "often"},
+		}
+
+		for _, values := range invalid {
+			setEnv(t, values)
+
+			if _, err := authTLS.GetTLSOptions(); err == nil {
+				t.Fatalf("%v should be invalid", values)
+			}
+		}
+	})
+}
+
+func Test_CertificateReloader(t *testing.T) {
+	t.Run("NewCertificateReloader returns an error if the files can't be loaded", func(t *testing.T) {
+		certs := makeCertificates(t)
+
+		_, err := authTLS.NewCertificateReloader(authTLS.TLSOptions{CertFile: certs.ServerCertFile, KeyFile: certs.CAFile})
+		if err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+
+	t.Run("Connections are served with the certificate", func(t *testing.T) {
+		certs := makeCertificates(t)
+
+		reloader, err := authTLS.NewCertificateReloader(authTLS.TLSOptions{CertFile: certs.ServerCertFile, KeyFile: certs.ServerKeyFile})
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		serial, dialErr := dial(serveTLS(t, reloader), &tls.Config{RootCAs: certs.CAPool})
+		if dialErr != nil || serial != certs.ServerSerial {
+			t.Fatalf("the server's certificate should be served, got %d %v", serial, dialErr)
+		}
+	})
+
+	t.Run("Watch reloads the certificate when its files change", func(t *testing.T) {
+		certs := makeCertificates(t)
+
+		reloader, _ := authTLS.NewCertificateReloader(authTLS.TLSOptions{
+			CertFile:       certs.ServerCertFile,
+			KeyFile:        certs.ServerKeyFile,
+			ReloadInterval: 5 * time.Millisecond,
+		})
+		address := serveTLS(t, reloader)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		reloaded := make(chan error, 1)
+		go reloader.Watch(ctx, func(err error) { reloaded <- err })
+
+		if err := certs.RenewServerCertificate(); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		// A reload between the writes of the certificate and the key fails, and
+		// is retried at the next check
+		for reloadErr := errors.New("not reloaded"); reloadErr != nil; {
+			select {
+			case reloadErr = <-reloaded:
+			case <-time.After(time.Second):
+				t.Fatalf("the certificate should be reloaded")
+			}
+		}
+
+		serial, dialErr := dial(address, &tls.Config{RootCAs: certs.CAPool})
+		if dialErr != nil || serial != certs.ServerSerial {
+			t.Fatalf("the new certificate should be served, got %d %v", serial, dialErr)
+		}
+	})
+
+	t.Run("A failed reload keeps the previous certificate", func(t *testing.T) {
+		certs := makeCertificates(t)
+
+		reloader, _ := authTLS.NewCertificateReloader(authTLS.TLSOptions{CertFile: certs.ServerCertFile, KeyFile: certs.ServerKeyFile})
+		before := reloader.Certificate()
+
+		os.WriteFile(certs.ServerKeyFile, []byte("half written"), 0600)
+
+		if err := reloader.Reload(); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+		if reloader.Certificate() != before {
+			t.Fatalf("the previous certificate should be kept")
+		}
+	})
+}
+
+func Test_ClientAuthentication(t *testing.T) {
+	certs := makeCertificates(t)
+
+	makeReloader := func(clientAuth string) *authTLS.CertificateReloader {
+		reloader, err := authTLS.NewCertificateReloader(authTLS.TLSOptions{
+			CertFile:     certs.ServerCertFile,
+			KeyFile:      certs.ServerKeyFile,
+			ClientCAFile: certs.CAFile,
+			ClientAuth:   clientAuth,
+		})
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		return reloader
+	}
+
+	withCert := &tls.Config{RootCAs: certs.CAPool, Certificates: []tls.Certificate{certs.Client}}
+	withoutCert := &tls.Config{RootCAs: certs.CAPool}
+
+	t.Run("Clients may connect without a certificate with optional client authentication", func(t *testing.T) {
+		address := serveTLS(t, makeReloader(authTLS.ClientAuthOptional))
+
+		if _, err := dial(address, withoutCert); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		if _, err := dial(address, withCert); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("Clients without a certificate are rejected when client authentication is required", func(t *testing.T) {
+		address := serveTLS(t, makeReloader(authTLS.ClientAuthRequire))
+
+		if _, err := dial(address, withoutCert); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+		if _, err := dial(address, withCert); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+}
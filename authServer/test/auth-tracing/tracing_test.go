@@ -0,0 +1,151 @@
+package authTracingTest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// recordSpans sets a global TracerProvider that records every span
+func recordSpans() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	return recorder
+}
+
+func Test_GetTracingOptions(t *testing.T) {
+	t.Run("GetTracingOptions disables tracing by default", func(t *testing.T) {
+		t.Setenv(constants.TRACING_EXPORTER, "")
+		t.Setenv(constants.TRACING_SAMPLE_RATIO, "")
+		t.Setenv(constants.TRACING_SERVICE_NAME, "")
+
+		options, optionsErr := authTracing.GetTracingOptions()
+		if optionsErr != nil {
+			t.Fatalf("GetTracingOptions should succeed, got %s", optionsErr.Error())
+		}
+
+		if options.Exporter != authTracing.ExporterNone || options.SampleRatio != 1 || options.ServiceName != "auth-microservice" {
+			t.Fatalf("unexpected default options: %+v", options)
+		}
+	})
+
+	t.Run("GetTracingOptions requires a file for the file exporter", func(t *testing.T) {
+		t.Setenv(constants.TRACING_EXPORTER, authTracing.ExporterFile)
+		t.Setenv(constants.TRACING_FILE, "")
+
+		if _, optionsErr := authTracing.GetTracingOptions(); optionsErr == nil {
+			t.Fatalf("GetTracingOptions should fail without TRACING_FILE")
+		}
+	})
+
+	t.Run("GetTracingOptions rejects unknown exporters and invalid sample ratios", func(t *testing.T) {
+		t.Setenv(constants.TRACING_EXPORTER, "zipkin")
+		if _, optionsErr := authTracing.GetTracingOptions(); optionsErr == nil {
+			t.Fatalf("GetTracingOptions should reject an unknown exporter")
+		}
+
+		t.Setenv(constants.TRACING_EXPORTER, authTracing.ExporterStdout)
+		for _, ratio := range []string{"abc", "-0.1", "1.5"} {
+			t.Setenv(constants.TRACING_SAMPLE_RATIO, ratio)
+			if _, optionsErr := authTracing.GetTracingOptions(); optionsErr == nil {
+				t.Fatalf("GetTracingOptions should reject the sample ratio %s", ratio)
+			}
+		}
+	})
+}
+
+func Test_ConfigureTracing(t *testing.T) {
+	t.Run("The file exporter appends spans to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.json")
+
+		shutdown, configureErr := authTracing.ConfigureTracing(authTracing.TracingOptions{
+			Exporter:    authTracing.ExporterFile,
+			FilePath:    path,
+			SampleRatio: 1,
+			ServiceName: "test-service",
+		})
+		if configureErr != nil {
+			t.Fatalf("ConfigureTracing should succeed, got %s", configureErr.Error())
+		}
+
+		_, span := authTracing.Tracer().Start(context.Background(), "test span")
+		span.End()
+
+		if shutdownErr := shutdown(context.Background()); shutdownErr != nil {
+			t.Fatalf("shutdown should succeed, got %s", shutdownErr.Error())
+		}
+
+		contents, readErr := os.ReadFile(path)
+		if readErr != nil {
+			t.Fatalf("error reading the trace file: %s", readErr.Error())
+		}
+
+		var exported map[string]interface{}
+		if unmarshalErr := json.NewDecoder(bytes.NewReader(contents)).Decode(&exported); unmarshalErr != nil {
+			t.Fatalf("the trace file should hold JSON spans: %s", unmarshalErr.Error())
+		}
+
+		if exported["Name"] != "test span" {
+			t.Fatalf("expected the exported span to be named test span, got %v", exported["Name"])
+		}
+
+		if !strings.Contains(string(contents), "test-service") {
+			t.Fatalf("expected the span to include the service name")
+		}
+	})
+}
+
+func Test_StartDBOperation(t *testing.T) {
+	recorder := recordSpans()
+
+	t.Run("StartDBOperation doesn't start a trace of its own", func(t *testing.T) {
+		before := len(recorder.Ended())
+
+		authTracing.StartDBOperation(context.Background(), "GetUserById")(nil)
+
+		if len(recorder.Ended()) != before {
+			t.Fatalf("expected no span outside of a trace")
+		}
+	})
+
+	t.Run("StartDBOperation records a child span with the operation's error", func(t *testing.T) {
+		ctx, parent := authTracing.Tracer().Start(context.Background(), "request")
+
+		authTracing.StartDBOperation(ctx, "GetUserById")(errors.New("connection lost"))
+		parent.End()
+
+		spans := recorder.Ended()
+		var dbSpan sdktrace.ReadOnlySpan
+		for _, span := range spans {
+			if span.Name() == "DatabaseController.GetUserById" {
+				dbSpan = span
+			}
+		}
+
+		if dbSpan == nil {
+			t.Fatalf("expected a DatabaseController.GetUserById span")
+		}
+
+		if dbSpan.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("expected the database span to be a child of the request span")
+		}
+
+		if dbSpan.Status().Code != codes.Error {
+			t.Fatalf("expected the database span to have an error status")
+		}
+	})
+}
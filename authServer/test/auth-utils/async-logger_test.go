@@ -0,0 +1,348 @@
+package authUtilsTest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"methompson.com/auth-microservice/authServer/authMetrics"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// blockingLogger records logs. While blocked, writes wait until unblock is
+// called, so that the AsyncLogger's queue fills up.
+type blockingLogger struct {
+	mutex    sync.Mutex
+	messages []string
+	batches  []int
+	err      error
+	blocked  chan struct{}
+	closed   bool
+}
+
+func newBlockingLogger() *blockingLogger {
+	return &blockingLogger{blocked: make(chan struct{})}
+}
+
+func (bl *blockingLogger) unblock() { close(bl.blocked) }
+
+func (bl *blockingLogger) AddRequestLog(log *authUtils.RequestLogData) error {
+	return bl.AddInfoLog(&authUtils.InfoLogData{Message: log.Path})
+}
+
+func (bl *blockingLogger) AddInfoLog(log *authUtils.InfoLogData) error {
+	<-bl.blocked
+
+	bl.mutex.Lock()
+	defer bl.mutex.Unlock()
+
+	if bl.err != nil {
+		return bl.err
+	}
+
+	bl.messages = append(bl.messages, log.Message)
+
+	return nil
+}
+
+func (bl *blockingLogger) Close() error {
+	bl.closed = true
+	return nil
+}
+
+// blockingBatchLogger also writes logs in batches
+type blockingBatchLogger struct {
+	*blockingLogger
+}
+
+func (bbl blockingBatchLogger) AddLogs(entries []authUtils.LogEntry) error {
+	<-bbl.blocked
+
+	bbl.mutex.Lock()
+	defer bbl.mutex.Unlock()
+
+	bbl.batches = append(bbl.batches, len(entries))
+	for _, entry := range entries {
+		bbl.messages = append(bbl.messages, entry.Info.Message)
+	}
+
+	return nil
+}
+
+func addMessages(logger authUtils.AuthLogger, messages ...string) {
+	for _, message := range messages {
+		logger.AddInfoLog(&authUtils.InfoLogData{Type: "info", Message: message})
+	}
+}
+
+// waitForPending waits until the workers have taken logs from the queue
+func waitForPending(al *authUtils.AsyncLogger, pending int) {
+	for i := 0; i < 100 && al.Stats().Pending != pending; i++ {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_AsyncLogger(t *testing.T) {
+	t.Run("AsyncLogger writes queued logs in order and flushes them on Close", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{})
+
+		addMessages(al, "a", "b", "c")
+		bl.unblock()
+
+		if err := al.Close(); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		if len(bl.messages) != 3 || bl.messages[0] != "a" || bl.messages[2] != "c" {
+			t.Fatalf("every log should be written in order, got %v", bl.messages)
+		}
+		if !bl.closed {
+			t.Fatalf("the wrapped logger should be closed")
+		}
+		if stats := al.Stats(); stats.Written != 3 || stats.Dropped != 0 || stats.Pending != 0 {
+			t.Fatalf("unexpected stats: %v", stats)
+		}
+		if err := al.AddInfoLog(&authUtils.InfoLogData{}); err == nil {
+			t.Fatalf("logs added after Close should return an error")
+		}
+	})
+
+	t.Run("AsyncLogger drops the newest logs when the queue is full", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{QueueSize: 2, BatchSize: 1})
+
+		// The worker takes the first log and waits on it
+		addMessages(al, "a")
+		waitForPending(al, 0)
+
+		addMessages(al, "b", "c", "d")
+		bl.unblock()
+		al.Close()
+
+		if len(bl.messages) != 3 || bl.messages[2] != "c" {
+			t.Fatalf("d should be dropped, got %v", bl.messages)
+		}
+		if stats := al.Stats(); stats.Dropped != 1 || stats.Written != 3 {
+			t.Fatalf("unexpected stats: %v", stats)
+		}
+	})
+
+	t.Run("AsyncLogger drops the oldest logs when the queue is full with DropOldest", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{QueueSize: 2, BatchSize: 1, DropPolicy: authUtils.DropOldest})
+
+		addMessages(al, "a")
+		waitForPending(al, 0)
+
+		addMessages(al, "b", "c", "d")
+		bl.unblock()
+		al.Close()
+
+		if len(bl.messages) != 3 || bl.messages[1] != "c" || bl.messages[2] != "d" {
+			t.Fatalf("b should be dropped, got %v", bl.messages)
+		}
+		if stats := al.Stats(); stats.Dropped != 1 {
+			t.Fatalf("unexpected stats: %v", stats)
+		}
+	})
+
+	t.Run("AsyncLogger doesn't drop audit logs to make room for other logs", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{QueueSize: 1, BatchSize: 1, DropPolicy: authUtils.DropOldest})
+
+		addMessages(al, "a")
+		waitForPending(al, 0)
+
+		if err := al.AddInfoLog(&authUtils.InfoLogData{Type: "audit", Message: "audit"}); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		addMessages(al, "b", "c")
+
+		bl.unblock()
+		al.Close()
+
+		if len(bl.messages) != 3 || bl.messages[1] != "audit" || bl.messages[2] != "c" {
+			t.Fatalf("only b should be dropped, got %v", bl.messages)
+		}
+		if stats := al.Stats(); stats.Dropped != 1 {
+			t.Fatalf("unexpected stats: %v", stats)
+		}
+	})
+
+	t.Run("AsyncLogger skips stored audit logs with SkipAudit", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{SkipAudit: true})
+
+		al.AddInfoLog(&authUtils.InfoLogData{Type: authUtils.AuditLogType, Message: "audit"})
+		al.AddInfoLog(&authUtils.InfoLogData{Type: authUtils.AuditUnstoredLogType, Message: "unstored"})
+		addMessages(al, "a")
+
+		bl.unblock()
+		al.Close()
+
+		if len(bl.messages) != 2 || bl.messages[0] != "unstored" || bl.messages[1] != "a" {
+			t.Fatalf("only the stored audit log should be skipped, got %v", bl.messages)
+		}
+	})
+
+	t.Run("AsyncLogger waits up to AuditWait for room in the audit queue", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{QueueSize: 1, BatchSize: 1, AuditWait: 50 * time.Millisecond, Name: "audit-wait"})
+
+		droppedCounter := authMetrics.LogsDropped.WithLabelValues("audit-wait")
+		droppedBefore := testutil.ToFloat64(droppedCounter)
+
+		addAudit := func(message string) error {
+			return al.AddInfoLog(&authUtils.InfoLogData{Type: "audit", Message: message})
+		}
+
+		// The worker takes the first audit log and waits on it, the second fills
+		// the audit queue
+		addAudit("a")
+		waitForPending(al, 0)
+		addAudit("b")
+
+		if err := addAudit("c"); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+
+		added := make(chan error)
+		go func() { added <- addAudit("d") }()
+
+		bl.unblock()
+		if err := <-added; err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		al.Close()
+
+		if len(bl.messages) != 3 || bl.messages[2] != "d" || al.Stats().Dropped != 1 {
+			t.Fatalf("only c should be dropped, got %v", bl.messages)
+		}
+		if dropped := testutil.ToFloat64(droppedCounter) - droppedBefore; dropped != 1 {
+			t.Fatalf("the dropped log should be counted, got %v", dropped)
+		}
+	})
+
+	t.Run("AsyncLogger writes batches to a BatchLogger", func(t *testing.T) {
+		bbl := blockingBatchLogger{newBlockingLogger()}
+		al := authUtils.NewAsyncLogger(bbl, authUtils.AsyncLoggerOptions{BatchSize: 3})
+
+		addMessages(al, "a")
+		waitForPending(al, 0)
+
+		addMessages(al, "b", "c", "d", "e")
+		bbl.unblock()
+		al.Close()
+
+		if len(bbl.batches) != 3 || bbl.batches[0] != 1 || bbl.batches[1] != 3 || bbl.batches[2] != 1 {
+			t.Fatalf("the logs should be written in batches of up to 3, got %v", bbl.batches)
+		}
+		if len(bbl.messages) != 5 || bbl.messages[4] != "e" {
+			t.Fatalf("every log should be written, got %v", bbl.messages)
+		}
+	})
+
+	t.Run("AsyncLogger counts logs the wrapped logger fails to write", func(t *testing.T) {
+		bl := newBlockingLogger()
+		bl.err = errors.New("unavailable")
+		bl.unblock()
+
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{})
+		addMessages(al, "a", "b")
+		al.Close()
+
+		if stats := al.Stats(); stats.Failed != 2 || stats.Written != 0 {
+			t.Fatalf("unexpected stats: %v", stats)
+		}
+	})
+
+	t.Run("CheckHealth reports a full queue, a failed write and a closed logger", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{QueueSize: 1, BatchSize: 1})
+
+		if err := al.CheckHealth(); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		// The worker takes the first log and waits on it, the second fills the queue
+		addMessages(al, "a")
+		waitForPending(al, 0)
+		addMessages(al, "b")
+
+		if err := al.CheckHealth(); err == nil {
+			t.Fatalf("a full queue should be reported")
+		}
+
+		bl.mutex.Lock()
+		bl.err = errors.New("unavailable")
+		bl.mutex.Unlock()
+		bl.unblock()
+
+		for i := 0; i < 100 && al.Stats().Failed != 2; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		if err := al.CheckHealth(); err == nil {
+			t.Fatalf("the failed write should be reported")
+		}
+
+		al.Close()
+
+		if err := al.CheckHealth(); err == nil {
+			t.Fatalf("a closed logger should be reported")
+		}
+	})
+
+	t.Run("Close returns an error if the logs aren't written before FlushTimeout", func(t *testing.T) {
+		bl := newBlockingLogger()
+		al := authUtils.NewAsyncLogger(bl, authUtils.AsyncLoggerOptions{FlushTimeout: 10 * time.Millisecond})
+
+		addMessages(al, "a")
+
+		if err := al.Close(); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+
+		bl.unblock()
+	})
+}
+
+func Test_GetAsyncLoggerOptions(t *testing.T) {
+	t.Run("GetAsyncLoggerOptions reads the options from the environment", func(t *testing.T) {
+		t.Setenv(constants.LOG_QUEUE_SIZE, "50")
+		t.Setenv(constants.LOG_QUEUE_WORKERS, "2")
+		t.Setenv(constants.LOG_BATCH_SIZE, "10")
+		t.Setenv(constants.LOG_DROP_POLICY, "oldest")
+
+		options, err := authUtils.GetAsyncLoggerOptions()
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		expected := authUtils.AsyncLoggerOptions{QueueSize: 50, Workers: 2, BatchSize: 10, DropPolicy: authUtils.DropOldest}
+		if options != expected {
+			t.Fatalf("unexpected options: %v", options)
+		}
+	})
+
+	t.Run("GetAsyncLoggerOptions returns an error for invalid values", func(t *testing.T) {
+		t.Setenv(constants.LOG_DROP_POLICY, "random")
+
+		if _, err := authUtils.GetAsyncLoggerOptions(); err == nil {
+			t.Fatalf("the drop policy should be rejected")
+		}
+
+		t.Setenv(constants.LOG_DROP_POLICY, "")
+		t.Setenv(constants.LOG_QUEUE_SIZE, "0")
+
+		if _, err := authUtils.GetAsyncLoggerOptions(); err == nil {
+			t.Fatalf("the queue size should be rejected")
+		}
+	})
+}
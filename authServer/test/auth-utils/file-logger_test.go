@@ -0,0 +1,230 @@
+package authUtilsTest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+func addInfoLogs(t *testing.T, fl *authUtils.FileLogger, count int) {
+	for i := 0; i < count; i++ {
+		if err := fl.AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Type: "info", Message: "a log message"}); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		// Rotated files are named after the millisecond they were rotated in
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		t.Fatalf("the file should open: " + openErr.Error())
+	}
+	defer file.Close()
+
+	var scanner *bufio.Scanner
+
+	if strings.HasSuffix(path, ".gz") {
+		reader, gzipErr := gzip.NewReader(file)
+		if gzipErr != nil {
+			t.Fatalf("the file should be gzip compressed: " + gzipErr.Error())
+		}
+		scanner = bufio.NewScanner(reader)
+	} else {
+		scanner = bufio.NewScanner(file)
+	}
+
+	lines := 0
+	for scanner.Scan() {
+		lines++
+	}
+
+	return lines
+}
+
+func Test_FileLogger(t *testing.T) {
+	t.Run("FileLogger rotates the file once it reaches MaxSize", func(t *testing.T) {
+		dir := t.TempDir()
+		fl, err := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{MaxSize: 1})
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		addInfoLogs(t, fl, 3)
+		fl.Close()
+
+		rotated, _ := fl.RotatedFiles()
+		if len(rotated) != 2 {
+			t.Fatalf("two files should be rotated, got %v", rotated)
+		}
+
+		for _, path := range append(rotated, filepath.Join(dir, "logs.log")) {
+			if lines := countLines(t, path); lines != 1 {
+				t.Fatalf("%s should have one line, got %d", path, lines)
+			}
+		}
+	})
+
+	t.Run("FileLogger rotates the file once RotationInterval passes", func(t *testing.T) {
+		dir := t.TempDir()
+		fl, _ := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{RotationInterval: time.Hour})
+
+		addInfoLogs(t, fl, 2)
+
+		if rotated, _ := fl.RotatedFiles(); len(rotated) != 0 {
+			t.Fatalf("no files should be rotated yet, got %v", rotated)
+		}
+
+		fl.Options.RotationInterval = time.Millisecond
+		addInfoLogs(t, fl, 1)
+		fl.Close()
+
+		if rotated, _ := fl.RotatedFiles(); len(rotated) != 1 || countLines(t, rotated[0]) != 2 {
+			t.Fatalf("the file should be rotated, got %v", rotated)
+		}
+	})
+
+	t.Run("FileLogger compresses rotated files", func(t *testing.T) {
+		dir := t.TempDir()
+		fl, _ := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{MaxSize: 1, Compress: true})
+
+		addInfoLogs(t, fl, 2)
+		fl.Close()
+
+		rotated, _ := fl.RotatedFiles()
+		if len(rotated) != 1 || !strings.HasSuffix(rotated[0], ".log.gz") {
+			t.Fatalf("the rotated file should be compressed, got %v", rotated)
+		}
+		if countLines(t, rotated[0]) != 1 {
+			t.Fatalf("the compressed file should hold the rotated log")
+		}
+	})
+
+	t.Run("FileLogger keeps at most MaxFiles rotated files", func(t *testing.T) {
+		dir := t.TempDir()
+		fl, _ := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{MaxSize: 1, MaxFiles: 2})
+
+		addInfoLogs(t, fl, 5)
+		fl.Close()
+
+		if rotated, _ := fl.RotatedFiles(); len(rotated) != 2 {
+			t.Fatalf("two rotated files should be kept, got %v", rotated)
+		}
+	})
+
+	t.Run("FileLogger removes rotated files older than MaxAge", func(t *testing.T) {
+		dir := t.TempDir()
+		old := filepath.Join(dir, "logs-20000101T000000.000.log")
+		os.WriteFile(old, []byte("{}\n"), 0644)
+		os.Chtimes(old, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+
+		fl, _ := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{MaxSize: 1, MaxAge: 24 * time.Hour})
+
+		addInfoLogs(t, fl, 2)
+		fl.Close()
+
+		rotated, _ := fl.RotatedFiles()
+		if len(rotated) != 1 || rotated[0] == old {
+			t.Fatalf("only the new rotated file should be kept, got %v", rotated)
+		}
+	})
+
+	t.Run("Reopen opens a new file after the log file is moved", func(t *testing.T) {
+		dir := t.TempDir()
+		fl, _ := authUtils.MakeNewFileLogger(dir, "logs.log", authUtils.FileLoggerOptions{})
+
+		addInfoLogs(t, fl, 1)
+		os.Rename(filepath.Join(dir, "logs.log"), filepath.Join(dir, "logs.log.1"))
+
+		if err := fl.Reopen(); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		addInfoLogs(t, fl, 1)
+		fl.Close()
+
+		if countLines(t, filepath.Join(dir, "logs.log")) != 1 || countLines(t, filepath.Join(dir, "logs.log.1")) != 1 {
+			t.Fatalf("each file should hold one log")
+		}
+	})
+
+	t.Run("Logs written after Close return an error", func(t *testing.T) {
+		fl, _ := authUtils.MakeNewFileLogger(t.TempDir(), "logs.log", authUtils.FileLoggerOptions{})
+		fl.Close()
+
+		if err := fl.AddInfoLog(&authUtils.InfoLogData{Type: "info"}); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+
+	t.Run("CheckHealth returns an error once the file is closed", func(t *testing.T) {
+		fl, _ := authUtils.MakeNewFileLogger(t.TempDir(), "logs.log", authUtils.FileLoggerOptions{})
+
+		if err := fl.CheckHealth(); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		fl.Close()
+
+		if err := fl.CheckHealth(); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+}
+
+func Test_GetFileLoggerOptions(t *testing.T) {
+	t.Run("GetFileLoggerOptions reads the options from the environment", func(t *testing.T) {
+		t.Setenv(constants.FILE_LOGGING_MAX_SIZE, "10")
+		t.Setenv(constants.FILE_LOGGING_ROTATION_INTERVAL, "24h")
+		t.Setenv(constants.FILE_LOGGING_COMPRESS, "true")
+		t.Setenv(constants.FILE_LOGGING_MAX_FILES, "7")
+		t.Setenv(constants.FILE_LOGGING_MAX_AGE, "168h")
+
+		options, err := authUtils.GetFileLoggerOptions()
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		expected := authUtils.FileLoggerOptions{
+			MaxSize:          10 * 1024 * 1024,
+			RotationInterval: 24 * time.Hour,
+			Compress:         true,
+			MaxFiles:         7,
+			MaxAge:           168 * time.Hour,
+		}
+		if options != expected {
+			t.Fatalf("unexpected options: %v", options)
+		}
+	})
+
+	t.Run("GetFileLoggerOptions returns an error for invalid values", func(t *testing.T) {
+		invalid := map[string]string{
+			constants.FILE_LOGGING_MAX_SIZE:          "-1",
+			constants.FILE_LOGGING_ROTATION_INTERVAL: "1 day",
+			constants.FILE_LOGGING_COMPRESS:          "gzip",
+			constants.FILE_LOGGING_MAX_FILES:         "all",
+			constants.FILE_LOGGING_MAX_AGE:           "-1h",
+		}
+
+		for name, value := range invalid {
+			t.Setenv(name, value)
+
+			if _, err := authUtils.GetFileLoggerOptions(); err == nil {
+				t.Fatalf("%s=%s should be rejected", name, value)
+			}
+
+			os.Unsetenv(name)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package authUtilsTest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// logCollector is a test server that fails the first failures requests with
+// the status
+type logCollector struct {
+	mutex    sync.Mutex
+	requests int
+	lines    []map[string]interface{}
+	headers  []http.Header
+	failures int
+	status   int
+}
+
+func (lc *logCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+
+	lc.requests++
+	lc.headers = append(lc.headers, r.Header)
+
+	if lc.requests <= lc.failures {
+		w.WriteHeader(lc.status)
+		return
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		json.Unmarshal(scanner.Bytes(), &line)
+		lc.lines = append(lc.lines, line)
+	}
+}
+
+func makeHTTPLogger(url string, maxRetries int) *authUtils.HTTPLogger {
+	return authUtils.MakeNewHTTPLogger(authUtils.HTTPLoggerOptions{
+		URL:            url,
+		Authorization:  "Bearer abc",
+		MaxRetries:     maxRetries,
+		InitialBackoff: time.Millisecond,
+	})
+}
+
+func Test_HTTPLogger(t *testing.T) {
+	t.Run("HTTPLogger posts logs as JSON lines", func(t *testing.T) {
+		collector := &logCollector{}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		hl := makeHTTPLogger(server.URL, 0)
+		err := hl.AddLogs([]authUtils.LogEntry{
+			{Info: &authUtils.InfoLogData{Timestamp: time.Now(), Level: "INFO", Type: "log", Message: "a"}},
+			{Request: &authUtils.RequestLogData{Timestamp: time.Now(), Type: "request", RequestId: "abc"}},
+		})
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		if collector.requests != 1 || len(collector.lines) != 2 {
+			t.Fatalf("both logs should be posted in one request, got %d requests and %v", collector.requests, collector.lines)
+		}
+		if collector.lines[0]["msg"] != "a" || collector.lines[1]["requestId"] != "abc" {
+			t.Fatalf("unexpected lines: %v", collector.lines)
+		}
+		if collector.headers[0].Get("Authorization") != "Bearer abc" || collector.headers[0].Get("Content-Type") != "application/x-ndjson" {
+			t.Fatalf("unexpected headers: %v", collector.headers[0])
+		}
+	})
+
+	t.Run("HTTPLogger retries server errors", func(t *testing.T) {
+		collector := &logCollector{failures: 2, status: http.StatusServiceUnavailable}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		err := makeHTTPLogger(server.URL, 3).AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Type: "log", Message: "a"})
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		if collector.requests != 3 || len(collector.lines) != 1 {
+			t.Fatalf("the log should be posted on the third attempt, got %d requests", collector.requests)
+		}
+	})
+
+	t.Run("HTTPLogger returns an error once the retries run out", func(t *testing.T) {
+		collector := &logCollector{failures: 10, status: http.StatusTooManyRequests}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		err := makeHTTPLogger(server.URL, 2).AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Type: "log"})
+
+		if err == nil {
+			t.Fatalf("err should not be nil")
+		}
+		if collector.requests != 3 {
+			t.Fatalf("the request should be retried twice, got %d requests", collector.requests)
+		}
+	})
+
+	t.Run("HTTPLogger doesn't retry client errors", func(t *testing.T) {
+		collector := &logCollector{failures: 10, status: http.StatusBadRequest}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		err := makeHTTPLogger(server.URL, 3).AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Type: "log"})
+
+		if err == nil || collector.requests != 1 {
+			t.Fatalf("the request should fail without retrying, got %d requests", collector.requests)
+		}
+	})
+
+	t.Run("HTTPLogger retries when the server can't be reached", func(t *testing.T) {
+		server := httptest.NewServer(&logCollector{})
+		server.Close()
+
+		start := time.Now()
+		err := makeHTTPLogger(server.URL, 2).AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Type: "log"})
+
+		if err == nil {
+			t.Fatalf("err should not be nil")
+		}
+		// Backoff of 1ms and 2ms
+		if time.Since(start) < 3*time.Millisecond {
+			t.Fatalf("the logger should back off between retries")
+		}
+	})
+
+	t.Run("Close stops the retries", func(t *testing.T) {
+		collector := &logCollector{failures: 10, status: http.StatusServiceUnavailable}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		hl := authUtils.MakeNewHTTPLogger(authUtils.HTTPLoggerOptions{URL: server.URL, MaxRetries: 3, InitialBackoff: time.Hour})
+
+		added := make(chan error)
+		go func() { added <- hl.AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Type: "log"}) }()
+
+		// The first request fails and the logger waits to retry it
+		time.Sleep(10 * time.Millisecond)
+		hl.Close()
+
+		select {
+		case err := <-added:
+			if err == nil {
+				t.Fatalf("err should not be nil")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Close should stop the backoff")
+		}
+	})
+}
+
+func Test_GetHTTPLoggerOptions(t *testing.T) {
+	t.Run("GetHTTPLoggerOptions reads the options from the environment", func(t *testing.T) {
+		t.Setenv(constants.HTTP_LOGGING_URL, "https://logs.example.com/ingest")
+		t.Setenv(constants.HTTP_LOGGING_AUTHORIZATION, "Bearer abc")
+		t.Setenv(constants.HTTP_LOGGING_MAX_RETRIES, "0")
+
+		options, err := authUtils.GetHTTPLoggerOptions()
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		if options.URL != "https://logs.example.com/ingest" || options.Authorization != "Bearer abc" || options.MaxRetries != 0 {
+			t.Fatalf("unexpected options: %v", options)
+		}
+	})
+
+	t.Run("GetHTTPLoggerOptions defaults to 3 retries", func(t *testing.T) {
+		t.Setenv(constants.HTTP_LOGGING_URL, "http://localhost:9000")
+		t.Setenv(constants.HTTP_LOGGING_MAX_RETRIES, "")
+
+		if options, _ := authUtils.GetHTTPLoggerOptions(); options.MaxRetries != 3 {
+			t.Fatalf("unexpected options: %v", options)
+		}
+	})
+
+	t.Run("GetHTTPLoggerOptions returns an error for invalid values", func(t *testing.T) {
+		for _, url := range []string{"", "logs.example.com", "ftp://logs.example.com"} {
+			t.Setenv(constants.HTTP_LOGGING_URL, url)
+
+			if _, err := authUtils.GetHTTPLoggerOptions(); err == nil {
+				t.Fatalf("'%s' should be rejected", url)
+			}
+		}
+
+		t.Setenv(constants.HTTP_LOGGING_URL, "http://localhost:9000")
+		t.Setenv(constants.HTTP_LOGGING_MAX_RETRIES, "-1")
+
+		if _, err := authUtils.GetHTTPLoggerOptions(); err == nil {
+			t.Fatalf("the retries should be rejected")
+		}
+	})
+}
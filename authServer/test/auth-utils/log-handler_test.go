@@ -0,0 +1,166 @@
+package authUtilsTest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authUtils"
+)
+
+type testLogger struct {
+	infoLogs []authUtils.InfoLogData
+}
+
+func (tl *testLogger) AddRequestLog(log *authUtils.RequestLogData) error { return nil }
+func (tl *testLogger) AddInfoLog(log *authUtils.InfoLogData) error {
+	tl.infoLogs = append(tl.infoLogs, *log)
+	return nil
+}
+
+func makeTestLogger(level slog.Level) (*slog.Logger, *testLogger) {
+	tl := &testLogger{}
+	var logger authUtils.AuthLogger = tl
+
+	return slog.New(authUtils.NewLogHandler([]*authUtils.AuthLogger{&logger}, level)), tl
+}
+
+func Test_ParseLogLevel(t *testing.T) {
+	t.Run("ParseLogLevel parses level names regardless of case", func(t *testing.T) {
+		expected := map[string]slog.Level{
+			"":      slog.LevelInfo,
+			"debug": slog.LevelDebug,
+			"INFO":  slog.LevelInfo,
+			"Warn":  slog.LevelWarn,
+			"error": slog.LevelError,
+		}
+
+		for str, level := range expected {
+			parsed, err := authUtils.ParseLogLevel(str)
+
+			if err != nil {
+				t.Fatalf("'" + str + "' should be parsed: " + err.Error())
+			}
+			if parsed != level {
+				t.Fatalf("'%s' should be parsed as %s, got %s", str, level, parsed)
+			}
+		}
+	})
+
+	t.Run("ParseLogLevel returns an error for unknown levels", func(t *testing.T) {
+		if _, err := authUtils.ParseLogLevel("verbose"); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+}
+
+func Test_LogHandler(t *testing.T) {
+	t.Run("LogHandler writes records with their level, type and attributes", func(t *testing.T) {
+		logger, tl := makeTestLogger(slog.LevelInfo)
+
+		logger.With("component", "test").WithGroup("request").Warn("slow request", "type", "timing", "latency", 5, "error", errors.New("timeout"))
+
+		if len(tl.infoLogs) != 1 {
+			t.Fatalf("one log should be written, got %d", len(tl.infoLogs))
+		}
+
+		log := tl.infoLogs[0]
+
+		if log.Level != "WARN" || log.Message != "slow request" {
+			t.Fatalf("unexpected log: %v", log)
+		}
+		// The type attribute is only special at the top level
+		if log.Type != "log" {
+			t.Fatalf("type should be log, got %s", log.Type)
+		}
+		if log.Attributes["component"] != "test" || log.Attributes["request.type"] != "timing" || log.Attributes["request.latency"] != int64(5) {
+			t.Fatalf("unexpected attributes: %v", log.Attributes)
+		}
+		if log.Attributes["request.error"] != "timeout" {
+			t.Fatalf("errors should be written as their messages: %v", log.Attributes)
+		}
+	})
+
+	t.Run("LogHandler sets the type from a top level type attribute", func(t *testing.T) {
+		logger, tl := makeTestLogger(slog.LevelInfo)
+
+		logger.Info("maintenance", "type", "maintenance")
+
+		if len(tl.infoLogs) != 1 || tl.infoLogs[0].Type != "maintenance" || tl.infoLogs[0].Attributes != nil {
+			t.Fatalf("unexpected logs: %v", tl.infoLogs)
+		}
+	})
+
+	t.Run("LogHandler skips records below its level", func(t *testing.T) {
+		logger, tl := makeTestLogger(slog.LevelWarn)
+
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Error("error")
+
+		if len(tl.infoLogs) != 1 || tl.infoLogs[0].Message != "error" {
+			t.Fatalf("only the error should be written: %v", tl.infoLogs)
+		}
+	})
+}
+
+func Test_WriteInfoLog(t *testing.T) {
+	t.Run("WriteInfoLog writes a JSON line with the log's timestamp, level and attributes", func(t *testing.T) {
+		var buffer bytes.Buffer
+		timestamp := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		err := authUtils.WriteInfoLog(authUtils.NewJSONLogHandler(&buffer), &authUtils.InfoLogData{
+			Timestamp:  timestamp,
+			Level:      "DEBUG",
+			Type:       "info",
+			Message:    "hello",
+			Attributes: map[string]interface{}{"user": "abc"},
+		})
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		var line map[string]interface{}
+		if unmarshalErr := json.Unmarshal(buffer.Bytes(), &line); unmarshalErr != nil {
+			t.Fatalf("the log should be JSON: " + buffer.String())
+		}
+
+		if line["time"] != timestamp.Format(time.RFC3339) || line["level"] != "DEBUG" || line["msg"] != "hello" || line["type"] != "info" || line["user"] != "abc" {
+			t.Fatalf("unexpected log: " + buffer.String())
+		}
+	})
+}
+
+func Test_WriteRequestLog(t *testing.T) {
+	t.Run("WriteRequestLog sets the level from the status code", func(t *testing.T) {
+		expected := map[int]string{200: "INFO", 404: "WARN", 500: "ERROR"}
+
+		for statusCode, level := range expected {
+			var buffer bytes.Buffer
+
+			err := authUtils.WriteRequestLog(authUtils.NewJSONLogHandler(&buffer), &authUtils.RequestLogData{
+				Timestamp:  time.Now(),
+				Type:       "request",
+				RequestId:  "abc",
+				StatusCode: statusCode,
+			})
+
+			if err != nil {
+				t.Fatalf("err should be nil: " + err.Error())
+			}
+
+			var line map[string]interface{}
+			if unmarshalErr := json.Unmarshal(buffer.Bytes(), &line); unmarshalErr != nil {
+				t.Fatalf("the log should be JSON: " + buffer.String())
+			}
+
+			if line["level"] != level || line["requestId"] != "abc" {
+				t.Fatalf("unexpected log for %d: %s", statusCode, buffer.String())
+			}
+		}
+	})
+}
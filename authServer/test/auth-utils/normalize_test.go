@@ -0,0 +1,54 @@
+package authUtilsTest
+
+import (
+	"testing"
+
+	"methompson.com/auth-microservice/authServer/authUtils"
+)
+
+func Test_NormalizeIdentifier(t *testing.T) {
+	t.Run("NormalizeIdentifier will trim and case fold a value", func(t *testing.T) {
+		result := authUtils.NormalizeIdentifier("  Alice\t")
+
+		if result != "alice" {
+			t.Fatalf("result should be 'alice', it's '" + result + "'")
+		}
+	})
+
+	t.Run("NormalizeIdentifier will convert compatibility characters using NFKC", func(t *testing.T) {
+		// Fullwidth Latin letters
+		result := authUtils.NormalizeIdentifier("ＡＬＩＣＥ")
+
+		if result != "alice" {
+			t.Fatalf("result should be 'alice', it's '" + result + "'")
+		}
+	})
+
+	t.Run("NormalizeIdentifier will treat composed and decomposed characters as the same value", func(t *testing.T) {
+		composed := authUtils.NormalizeIdentifier("José")
+		decomposed := authUtils.NormalizeIdentifier("JOSÉ")
+
+		if composed != decomposed {
+			t.Fatalf("composed and decomposed values should match")
+		}
+	})
+
+	t.Run("NormalizeIdentifier will case fold characters that lowercasing misses", func(t *testing.T) {
+		result := authUtils.NormalizeIdentifier("STRASSE")
+		folded := authUtils.NormalizeIdentifier("straße")
+
+		if result != folded {
+			t.Fatalf("'" + result + "' and '" + folded + "' should match")
+		}
+	})
+}
+
+func Test_NormalizeEmail(t *testing.T) {
+	t.Run("NormalizeEmail will return the normalized version of an email address", func(t *testing.T) {
+		result := authUtils.NormalizeEmail(" Alice@Example.COM ")
+
+		if result != "alice@example.com" {
+			t.Fatalf("result should be 'alice@example.com', it's '" + result + "'")
+		}
+	})
+}
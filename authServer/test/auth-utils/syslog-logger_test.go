@@ -0,0 +1,209 @@
+package authUtilsTest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// An RFC 5424 header followed by a JSON message
+var syslogMessagePattern = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\d+) (\S+) - (\{.*\})$`)
+
+func checkSyslogMessage(t *testing.T, message string, priority int, msgId string) map[string]interface{} {
+	matches := syslogMessagePattern.FindStringSubmatch(message)
+	if matches == nil {
+		t.Fatalf("the message should be an RFC 5424 message: " + message)
+	}
+
+	if matches[1] != strconv.Itoa(priority) {
+		t.Fatalf("the priority should be %d: %s", priority, message)
+	}
+	if _, timeErr := time.Parse(time.RFC3339Nano, matches[2]); timeErr != nil {
+		t.Fatalf("the timestamp should be RFC 3339: %s", message)
+	}
+	if matches[4] != "test-app" || matches[6] != msgId {
+		t.Fatalf("unexpected app name or message id: %s", message)
+	}
+
+	var line map[string]interface{}
+	if unmarshalErr := json.Unmarshal([]byte(matches[7]), &line); unmarshalErr != nil {
+		t.Fatalf("the message should be a JSON line: " + message)
+	}
+
+	return line
+}
+
+func Test_SyslogLogger(t *testing.T) {
+	t.Run("SyslogLogger sends RFC 5424 messages over UDP", func(t *testing.T) {
+		conn, _ := net.ListenPacket("udp", "127.0.0.1:0")
+		defer conn.Close()
+
+		sl, err := authUtils.MakeNewSyslogLogger(authUtils.SyslogOptions{Network: "udp", Address: conn.LocalAddr().String(), Facility: 16, AppName: "test-app"})
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		defer sl.Close()
+
+		sl.AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Level: "WARN", Type: "log", Message: "slow"})
+
+		buffer := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, readErr := conn.ReadFrom(buffer)
+		if readErr != nil {
+			t.Fatalf("a message should be received: " + readErr.Error())
+		}
+
+		// local0 (16) * 8 + warning (4)
+		line := checkSyslogMessage(t, string(buffer[:n]), 132, "log")
+		if line["msg"] != "slow" {
+			t.Fatalf("unexpected message: %v", line)
+		}
+	})
+
+	t.Run("SyslogLogger frames messages over TCP and reconnects", func(t *testing.T) {
+		listener, _ := net.Listen("tcp", "127.0.0.1:0")
+		defer listener.Close()
+
+		received := make(chan string, 10)
+		go func() {
+			for {
+				conn, acceptErr := listener.Accept()
+				if acceptErr != nil {
+					return
+				}
+
+				// Every connection is closed after one message
+				reader := bufio.NewReader(conn)
+				length, _ := reader.ReadString(' ')
+				size, _ := strconv.Atoi(strings.TrimSpace(length))
+				message := make([]byte, size)
+				reader.Read(message)
+				received <- string(message)
+				conn.Close()
+			}
+		}()
+
+		sl, _ := authUtils.MakeNewSyslogLogger(authUtils.SyslogOptions{Network: "tcp", Address: listener.Addr().String(), Facility: 16, AppName: "test-app"})
+		defer sl.Close()
+
+		sl.AddRequestLog(&authUtils.RequestLogData{Timestamp: time.Now(), Type: "request", StatusCode: 500, RequestId: "abc"})
+
+		// local0 (16) * 8 + error (3)
+		line := checkSyslogMessage(t, <-received, 131, "request")
+		if line["requestId"] != "abc" {
+			t.Fatalf("unexpected message: %v", line)
+		}
+
+		// The server has closed the connection, which may only be noticed on a
+		// later write
+		time.Sleep(10 * time.Millisecond)
+		for i := 0; i < 3; i++ {
+			sl.AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Level: "INFO", Type: "audit", Message: "again"})
+		}
+
+		select {
+		case message := <-received:
+			checkSyslogMessage(t, message, 134, "audit")
+		case <-time.After(time.Second):
+			t.Fatalf("the logger should reconnect")
+		}
+	})
+
+	t.Run("SyslogLogger sends messages to a unix datagram socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.sock")
+		conn, listenErr := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		if listenErr != nil {
+			t.Skip("unix datagram sockets aren't supported: " + listenErr.Error())
+		}
+		defer conn.Close()
+
+		sl, err := authUtils.MakeNewSyslogLogger(authUtils.SyslogOptions{Network: "unix", Address: path, Facility: 4, AppName: "test-app"})
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		defer sl.Close()
+
+		sl.AddInfoLog(&authUtils.InfoLogData{Timestamp: time.Now(), Level: "DEBUG", Type: "log", Message: "debug"})
+
+		buffer := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, readErr := conn.ReadFrom(buffer)
+		if readErr != nil {
+			t.Fatalf("a message should be received: " + readErr.Error())
+		}
+
+		// auth (4) * 8 + debug (7)
+		checkSyslogMessage(t, string(buffer[:n]), 39, "log")
+	})
+
+	t.Run("SyslogLogger returns an error if the server can't be reached", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.sock")
+
+		sl, err := authUtils.MakeNewSyslogLogger(authUtils.SyslogOptions{Network: "unix", Address: path})
+		if err == nil || sl == nil {
+			t.Fatalf("the logger should be returned with an error")
+		}
+
+		if logErr := sl.AddInfoLog(&authUtils.InfoLogData{Type: "log"}); logErr == nil {
+			t.Fatalf("logs should return an error")
+		}
+	})
+}
+
+func Test_GetSyslogOptions(t *testing.T) {
+	t.Run("GetSyslogOptions reads the options from the environment", func(t *testing.T) {
+		t.Setenv(constants.SYSLOG_ADDRESS, "tcp://logs.example.com:601")
+		t.Setenv(constants.SYSLOG_FACILITY, "AUTH")
+		t.Setenv(constants.SYSLOG_APP_NAME, "auth")
+
+		options, err := authUtils.GetSyslogOptions()
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		expected := authUtils.SyslogOptions{Network: "tcp", Address: "logs.example.com:601", Facility: 4, AppName: "auth"}
+		if options != expected {
+			t.Fatalf("unexpected options: %v", options)
+		}
+	})
+
+	t.Run("GetSyslogOptions defaults to the local0 facility", func(t *testing.T) {
+		t.Setenv(constants.SYSLOG_ADDRESS, "unix:///dev/log")
+		os.Unsetenv(constants.SYSLOG_FACILITY)
+		os.Unsetenv(constants.SYSLOG_APP_NAME)
+
+		options, _ := authUtils.GetSyslogOptions()
+
+		if options.Network != "unix" || options.Address != "/dev/log" || options.Facility != 16 || options.AppName != "auth-microservice" {
+			t.Fatalf("unexpected options: %v", options)
+		}
+	})
+
+	t.Run("GetSyslogOptions returns an error for invalid values", func(t *testing.T) {
+		for _, address := range []string{"", "localhost:514", "http://localhost", "udp://", "unix://"} {
+			t.Setenv(constants.SYSLOG_ADDRESS, address)
+
+			if _, err := authUtils.GetSyslogOptions(); err == nil {
+				t.Fatalf("'%s' should be rejected", address)
+			}
+		}
+
+		t.Setenv(constants.SYSLOG_ADDRESS, "udp://localhost:514")
+		t.Setenv(constants.SYSLOG_FACILITY, "mail")
+
+		if _, err := authUtils.GetSyslogOptions(); err == nil {
+			t.Fatalf("the facility should be rejected")
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package authServerMocks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TestCertificates are a CA and the certificates it issued to a server on
+// 127.0.0.1 and to a client. The CA and the server's certificate and key are
+// written as PEM files.
+type TestCertificates struct {
+	CAFile         string
+	ServerCertFile string
+	ServerKeyFile  string
+	// The serial number of the current server certificate
+	ServerSerial int64
+	// Trusts the CA
+	CAPool *x509.CertPool
+	// The client's certificate, for tls.Config.Certificates
+	Client tls.Certificate
+
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+// MakeTestCertificates generates a CA, a server certificate and a client
+// certificate and writes the CA and the server's files to dir
+func MakeTestCertificates(dir string) (*TestCertificates, error) {
+	caKey, caKeyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if caKeyErr != nil {
+		return nil, caKeyErr
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	caDer, caErr := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if caErr != nil {
+		return nil, caErr
+	}
+
+	caCert, _ := x509.ParseCertificate(caDer)
+
+	tc := &TestCertificates{
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		ServerCertFile: filepath.Join(dir, "server.pem"),
+		ServerKeyFile:  filepath.Join(dir, "server-key.pem"),
+		CAPool:         x509.NewCertPool(),
+		caCert:         caCert,
+		caKey:          caKey,
+		serial:         1,
+	}
+	tc.CAPool.AddCert(caCert)
+
+	if writeErr := os.WriteFile(tc.CAFile, pemBlock("CERTIFICATE", caDer), 0600); writeErr != nil {
+		return nil, writeErr
+	}
+
+	if serverErr := tc.RenewServerCertificate(); serverErr != nil {
+		return nil, serverErr
+	}
+
+	clientCert, clientKey, clientErr := tc.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test Client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if clientErr != nil {
+		return nil, clientErr
+	}
+
+	client, pairErr := tls.X509KeyPair(clientCert, clientKey)
+	if pairErr != nil {
+		return nil, pairErr
+	}
+	tc.Client = client
+
+	return tc, nil
+}
+
+// RenewServerCertificate issues a new server certificate and overwrites the
+// server's files with it. The files' modification time is moved forward, so
+// that the change is noticed even on file systems with coarse timestamps.
+func (tc *TestCertificates) RenewServerCertificate() error {
+	cert, key, issueErr := tc.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if issueErr != nil {
+		return issueErr
+	}
+
+	tc.ServerSerial = tc.serial
+	modTime := time.Now().Add(time.Duration(tc.serial) * time.Second)
+
+	for file, contents := range map[string][]byte{tc.ServerCertFile: cert, tc.ServerKeyFile: key} {
+		if writeErr := os.WriteFile(file, contents, 0600); writeErr != nil {
+			return writeErr
+		}
+		if chtimesErr := os.Chtimes(file, modTime, modTime); chtimesErr != nil {
+			return chtimesErr
+		}
+	}
+
+	return nil
+}
+
+// issue returns a PEM encoded certificate and key, signed by the CA, for the
+// template
+func (tc *TestCertificates) issue(template *x509.Certificate) ([]byte, []byte, error) {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		return nil, nil, keyErr
+	}
+
+	tc.serial++
+	template.SerialNumber = big.NewInt(tc.serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+
+	der, certErr := x509.CreateCertificate(rand.Reader, template, tc.caCert, &key.PublicKey, tc.caKey)
+	if certErr != nil {
+		return nil, nil, certErr
+	}
+
+	keyDer, marshalErr := x509.MarshalECPrivateKey(key)
+	if marshalErr != nil {
+		return nil, nil, marshalErr
+	}
+
+	return pemBlock("CERTIFICATE", der), pemBlock("EC PRIVATE KEY", keyDer), nil
+}
+
+func pemBlock(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
@@ -8,28 +8,63 @@ import (
 )
 
 type TestDbController struct {
-	initDbErr          error
-	userDoc            dbc.FullUserDocument
+	initDbErr error
+	pingErr   error
+	// userDoc is a pointer so that TestDbController values remain comparable
+	userDoc            *dbc.FullUserDocument
 	userDocErr         error
 	nonceDoc           dbc.NonceDocument
 	nonceDocErr        error
 	addNonceErr        error
 	removeOldNoncesErr error
+	nonceCount         int64
+	nonceCountErr      error
 	hashedPass         string
 	editUserErr        error
+	deleteUserErr      error
+	sessionDoc         *dbc.SessionDocument
+	sessionDocErr      error
+	addSessionErr      error
+	revokeSessionErr   error
+	// auditEvents is a pointer so that the events added through value receivers
+	// can be read by the test
+	auditEvents    *[]au.AuditEvent
+	auditEventsErr error
+	// auditFailures is the number of calls to AddAuditEvent that fail before
+	// events are stored again
+	auditFailures   *int
+	lastAuditFilter *dbc.AuditEventFilter
+	logs            *[]dbc.LogDocument
+	logsErr         error
+	lastLogFilter   *dbc.LogFilter
 }
 
 func MakeBlankTestDbController() TestDbController {
 	return TestDbController{
 		initDbErr:          nil,
-		userDoc:            dbc.FullUserDocument{},
+		pingErr:            nil,
+		userDoc:            &dbc.FullUserDocument{},
 		userDocErr:         nil,
 		nonceDoc:           dbc.NonceDocument{},
 		nonceDocErr:        nil,
 		addNonceErr:        nil,
 		removeOldNoncesErr: nil,
+		nonceCount:         0,
+		nonceCountErr:      nil,
 		hashedPass:         "",
 		editUserErr:        nil,
+		deleteUserErr:      nil,
+		sessionDoc:         &dbc.SessionDocument{},
+		sessionDocErr:      nil,
+		addSessionErr:      nil,
+		revokeSessionErr:   nil,
+		auditEvents:        &[]au.AuditEvent{},
+		auditEventsErr:     nil,
+		auditFailures:      new(int),
+		lastAuditFilter:    &dbc.AuditEventFilter{},
+		logs:               &[]dbc.LogDocument{},
+		logsErr:            nil,
+		lastLogFilter:      &dbc.LogFilter{},
 	}
 }
 
@@ -37,12 +72,16 @@ func (tdc TestDbController) InitDatabase() error {
 	return tdc.initDbErr
 }
 
+func (tdc TestDbController) Ping() error {
+	return tdc.pingErr
+}
+
 func (tdc TestDbController) GetUserByUsername(username string) (dbc.FullUserDocument, error) {
-	return tdc.userDoc, tdc.userDocErr
+	return *tdc.userDoc, tdc.userDocErr
 }
 
 func (tdc TestDbController) GetUserById(id string) (dbc.FullUserDocument, error) {
-	return tdc.userDoc, tdc.userDocErr
+	return *tdc.userDoc, tdc.userDocErr
 }
 
 func (tdc TestDbController) GetNonce(hashedNonce string, remoteAddress string, exp int64) (dbc.NonceDocument, error) {
@@ -57,6 +96,10 @@ func (tdc TestDbController) RemoveOldNonces(exp int64) error {
 	return tdc.removeOldNoncesErr
 }
 
+func (tdc TestDbController) CountNonces() (int64, error) {
+	return tdc.nonceCount, tdc.nonceCountErr
+}
+
 func (tdc TestDbController) AddRequestLog(log *au.RequestLogData) error {
 	return errors.New("Unimplemented")
 }
@@ -77,11 +120,138 @@ func (tdc TestDbController) EditUserPassword(userId string, passwordHash string)
 	return tdc.editUserErr
 }
 
-func (tdc *TestDbController) SetInitDbErr(err error)                  { tdc.initDbErr = err }
-func (tdc *TestDbController) SetUserDoc(userDoc dbc.FullUserDocument) { tdc.userDoc = userDoc }
-func (tdc *TestDbController) SetUserDocErr(err error)                 { tdc.userDocErr = err }
-func (tdc *TestDbController) SetNonceDoc(nonceDoc dbc.NonceDocument)  { tdc.nonceDoc = nonceDoc }
-func (tdc *TestDbController) SetNonceDocErr(err error)                { tdc.nonceDocErr = err }
-func (tdc *TestDbController) SetAddNonceErr(err error)                { tdc.addNonceErr = err }
-func (tdc *TestDbController) SetRemoveOldNoncesErr(err error)         { tdc.removeOldNoncesErr = err }
-func (tdc *TestDbController) SetEditUserError(err error)              { tdc.editUserErr = err }
+func (tdc TestDbController) DeleteUser(userId string) error {
+	return tdc.deleteUserErr
+}
+
+func (tdc TestDbController) AddSession(session dbc.SessionDocument) error {
+	return tdc.addSessionErr
+}
+
+func (tdc TestDbController) GetSession(sessionId string) (dbc.SessionDocument, error) {
+	return *tdc.sessionDoc, tdc.sessionDocErr
+}
+
+// GetUserSessions returns the session set with SetSessionDoc, if it has an id
+func (tdc TestDbController) GetUserSessions(userId string) ([]dbc.SessionDocument, error) {
+	sessions := make([]dbc.SessionDocument, 0)
+	if len(tdc.sessionDoc.Id) > 0 {
+		sessions = append(sessions, *tdc.sessionDoc)
+	}
+
+	return sessions, tdc.sessionDocErr
+}
+
+func (tdc TestDbController) UpdateSessionLastSeen(sessionId string, lastSeen int64) error {
+	return nil
+}
+
+func (tdc TestDbController) RevokeSession(sessionId string, revokedAt int64) error {
+	return tdc.revokeSessionErr
+}
+
+// RevokeUserSessions revokes the session set with SetSessionDoc if it belongs
+// to the user
+func (tdc TestDbController) RevokeUserSessions(userId string, revokedAt int64) error {
+	if tdc.revokeSessionErr != nil {
+		return tdc.revokeSessionErr
+	}
+
+	if tdc.sessionDoc.UserId == userId && !tdc.sessionDoc.Revoked() {
+		tdc.sessionDoc.RevokedAt = revokedAt
+	}
+
+	return nil
+}
+
+func (tdc TestDbController) RemoveExpiredSessions(now int64) error {
+	return nil
+}
+
+// AddAuditEvent stores the event. Like the unique index on sequence, it rejects
+// an event whose sequence number is already stored.
+func (tdc TestDbController) AddAuditEvent(event *au.AuditEvent) error {
+	if tdc.auditEventsErr != nil {
+		return tdc.auditEventsErr
+	}
+
+	if *tdc.auditFailures > 0 {
+		*tdc.auditFailures--
+		return dbc.NewDBError("database error")
+	}
+
+	for _, stored := range *tdc.auditEvents {
+		if stored.Sequence == event.Sequence {
+			return dbc.NewDuplicateEntryError("duplicate sequence")
+		}
+	}
+
+	*tdc.auditEvents = append(*tdc.auditEvents, *event)
+	return nil
+}
+
+// GetAuditEvents returns the events added, newest first, up to the filter's
+// limit. The other filters are ignored. The filter is kept so that it can be
+// read with LastAuditFilter.
+func (tdc TestDbController) GetAuditEvents(filter dbc.AuditEventFilter) ([]au.AuditEvent, error) {
+	*tdc.lastAuditFilter = filter
+
+	events := make([]au.AuditEvent, 0)
+	for i := len(*tdc.auditEvents) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && int64(len(events)) == filter.Limit {
+			break
+		}
+
+		events = append(events, (*tdc.auditEvents)[i])
+	}
+
+	return events, tdc.auditEventsErr
+}
+
+// LastAuditFilter returns the filter last passed to GetAuditEvents
+func (tdc TestDbController) LastAuditFilter() dbc.AuditEventFilter { return *tdc.lastAuditFilter }
+
+// AuditEvents returns the events added with AddAuditEvent
+func (tdc TestDbController) AuditEvents() []au.AuditEvent { return *tdc.auditEvents }
+
+// GetLogs returns the logs set with SetLogs, applying the filter's offset and
+// limit. The other filters are ignored. The filter is kept so that it can be
+// read with LastLogFilter.
+func (tdc TestDbController) GetLogs(filter dbc.LogFilter) ([]dbc.LogDocument, error) {
+	*tdc.lastLogFilter = filter
+
+	logs := make([]dbc.LogDocument, 0)
+	for i := filter.Offset; i < int64(len(*tdc.logs)); i++ {
+		if filter.Limit > 0 && int64(len(logs)) == filter.Limit {
+			break
+		}
+
+		logs = append(logs, (*tdc.logs)[i])
+	}
+
+	return logs, tdc.logsErr
+}
+
+// LastLogFilter returns the filter last passed to GetLogs
+func (tdc TestDbController) LastLogFilter() dbc.LogFilter { return *tdc.lastLogFilter }
+
+func (tdc *TestDbController) SetInitDbErr(err error)                    { tdc.initDbErr = err }
+func (tdc *TestDbController) SetPingErr(err error)                      { tdc.pingErr = err }
+func (tdc *TestDbController) SetUserDoc(userDoc dbc.FullUserDocument)   { tdc.userDoc = &userDoc }
+func (tdc *TestDbController) SetUserDocErr(err error)                   { tdc.userDocErr = err }
+func (tdc *TestDbController) SetNonceDoc(nonceDoc dbc.NonceDocument)    { tdc.nonceDoc = nonceDoc }
+func (tdc *TestDbController) SetNonceDocErr(err error)                  { tdc.nonceDocErr = err }
+func (tdc *TestDbController) SetAddNonceErr(err error)                  { tdc.addNonceErr = err }
+func (tdc *TestDbController) SetRemoveOldNoncesErr(err error)           { tdc.removeOldNoncesErr = err }
+func (tdc *TestDbController) SetNonceCount(count int64)                 { tdc.nonceCount = count }
+func (tdc *TestDbController) SetNonceCountErr(err error)                { tdc.nonceCountErr = err }
+func (tdc *TestDbController) SetEditUserError(err error)                { tdc.editUserErr = err }
+func (tdc *TestDbController) SetDeleteUserError(err error)              { tdc.deleteUserErr = err }
+func (tdc *TestDbController) SetSessionDoc(session dbc.SessionDocument) { tdc.sessionDoc = &session }
+func (tdc *TestDbController) SetSessionDocErr(err error)                { tdc.sessionDocErr = err }
+func (tdc *TestDbController) SetAddSessionErr(err error)                { tdc.addSessionErr = err }
+func (tdc *TestDbController) SetRevokeSessionErr(err error)             { tdc.revokeSessionErr = err }
+func (tdc *TestDbController) SetAuditEventsErr(err error)               { tdc.auditEventsErr = err }
+func (tdc *TestDbController) SetAuditFailures(failures int)             { *tdc.auditFailures = failures }
+func (tdc *TestDbController) SetLogs(logs []dbc.LogDocument)            { tdc.logs = &logs }
+func (tdc *TestDbController) SetLogsErr(err error)                      { tdc.logsErr = err }
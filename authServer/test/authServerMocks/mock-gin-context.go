@@ -1,15 +1,15 @@
 package authServerMocks
 
 import (
-	// "net/http/httptest"
 	"net/http"
+	"net/http/httptest"
 
 	"github.com/gin-gonic/gin"
 )
 
 func MakeTestContext() *gin.Context {
 	req, _ := http.NewRequest("POST", "/login", nil)
-	ctx := &gin.Context{}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
 	ctx.Request = req
 
 	return ctx
@@ -0,0 +1,115 @@
+package authServerTest
+
+import (
+	"os"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_ParseCustomClaimsMapping(t *testing.T) {
+	t.Run("ParseCustomClaimsMapping parses attribute names and renamed attributes", func(t *testing.T) {
+		mapping, err := authCrypto.ParseCustomClaimsMapping("displayName:name, locale")
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		if len(mapping) != 2 || mapping["displayName"] != "name" || mapping["locale"] != "locale" {
+			t.Fatalf("mapping was not parsed correctly")
+		}
+	})
+
+	t.Run("ParseCustomClaimsMapping returns an empty mapping for an empty string", func(t *testing.T) {
+		mapping, err := authCrypto.ParseCustomClaimsMapping("")
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		if len(mapping) != 0 {
+			t.Fatalf("mapping should be empty")
+		}
+	})
+
+	t.Run("ParseCustomClaimsMapping rejects claims that conflict with reserved claims", func(t *testing.T) {
+		for _, str := range []string{"displayName:sub", "displayName:admin", "exp"} {
+			if _, err := authCrypto.ParseCustomClaimsMapping(str); err == nil {
+				t.Fatalf("err should not be nil for '" + str + "'")
+			}
+		}
+	})
+
+	t.Run("ParseCustomClaimsMapping rejects malformed entries", func(t *testing.T) {
+		for _, str := range []string{"a:b:c", ":name", "displayName:"} {
+			if _, err := authCrypto.ParseCustomClaimsMapping(str); err == nil {
+				t.Fatalf("err should not be nil for '" + str + "'")
+			}
+		}
+	})
+}
+
+func Test_CustomClaims(t *testing.T) {
+	t.Run("GenerateJWT copies the mapped attributes into the JWT", func(t *testing.T) {
+		mocks.PrepTestRSAKeys()
+		os.Setenv(constants.JWT_CUSTOM_CLAIMS, "displayName:name,locale")
+		defer os.Unsetenv(constants.JWT_CUSTOM_CLAIMS)
+
+		token, tokenErr := authCrypto.GenerateJWT(dbController.UserDocument{
+			Id:       "123",
+			Username: "alice",
+			Attributes: map[string]interface{}{
+				"displayName": "Alice Example",
+				"locale":      "en-US",
+				"metadata":    map[string]interface{}{"myApp": true},
+			},
+		})
+
+		if tokenErr != nil {
+			t.Fatalf("tokenErr should be nil: " + tokenErr.Error())
+		}
+
+		claims, claimsErr := authCrypto.ValidateJWT(token)
+
+		if claimsErr != nil {
+			t.Fatalf("claimsErr should be nil: " + claimsErr.Error())
+		}
+
+		if claims.Subject != "123" || claims.Username != "alice" {
+			t.Fatalf("regular claims were not decoded correctly")
+		}
+
+		if claims.Custom["name"] != "Alice Example" || claims.Custom["locale"] != "en-US" {
+			t.Fatalf("custom claims were not decoded correctly")
+		}
+
+		if _, ok := claims.Custom["metadata"]; ok {
+			t.Fatalf("unmapped attributes should not be copied into the JWT")
+		}
+	})
+
+	t.Run("GenerateJWT does not add custom claims if no mapping is configured", func(t *testing.T) {
+		mocks.PrepTestRSAKeys()
+		os.Unsetenv(constants.JWT_CUSTOM_CLAIMS)
+
+		token, _ := authCrypto.GenerateJWT(dbController.UserDocument{
+			Id: "123",
+			Attributes: map[string]interface{}{
+				"displayName": "Alice Example",
+			},
+		})
+
+		claims, claimsErr := authCrypto.ValidateJWT(token)
+
+		if claimsErr != nil {
+			t.Fatalf("claimsErr should be nil: " + claimsErr.Error())
+		}
+
+		if len(claims.Custom) != 0 {
+			t.Fatalf("claims.Custom should be empty")
+		}
+	})
+}
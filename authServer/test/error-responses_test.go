@@ -0,0 +1,68 @@
+package authServerTest
+
+import (
+	"net/http"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+func Test_ErrorToProblem(t *testing.T) {
+	t.Run("ErrorToProblem maps every error type to its error code", func(t *testing.T) {
+		cases := map[string]error{
+			apiErrors.InvalidNonce:       authUtils.NewNonceError("bad nonce"),
+			apiErrors.InvalidCredentials: authServer.NewLoginError("bad password"),
+			apiErrors.Forbidden:          authServer.NewUnauthorizedError("not allowed"),
+			apiErrors.ExpiredToken:       authCrypto.NewExpiredJWTError("expired"),
+			apiErrors.InvalidToken:       authCrypto.NewJWTError("invalid"),
+			apiErrors.NotFound:           dbController.NewNoResultsError("no results"),
+			apiErrors.DuplicateEntry:     dbController.NewDuplicateEntryError("duplicate"),
+			apiErrors.InvalidInput:       dbController.NewInvalidInputError("invalid"),
+			apiErrors.DatabaseError:      dbController.NewDBError("db error"),
+			apiErrors.CryptoKeyError:     authCrypto.NewCryptoKeyError("key error"),
+			apiErrors.HashError:          authServer.NewHashError("hash error"),
+		}
+
+		for code, err := range cases {
+			problem := authServer.ErrorToProblem(err)
+
+			if problem.Code != code {
+				t.Fatalf("expected " + code + ", got " + problem.Code)
+			}
+
+			if problem.Type != apiErrors.TypePrefix+code || problem.Status != apiErrors.Catalog[code].Status {
+				t.Fatalf("problem for " + code + " doesn't match the catalog")
+			}
+		}
+	})
+
+	t.Run("ErrorToProblem maps unknown errors to internal_error", func(t *testing.T) {
+		problem := authServer.ErrorToProblem(http.ErrBodyNotAllowed)
+
+		if problem.Code != apiErrors.InternalError || problem.Status != http.StatusInternalServerError {
+			t.Fatalf("unknown errors should be internal errors")
+		}
+	})
+
+	t.Run("ErrorToProblem doesn't expose the messages of server errors", func(t *testing.T) {
+		problem := authServer.ErrorToProblem(dbController.NewDBError("connection string with password"))
+
+		if len(problem.Detail) != 0 {
+			t.Fatalf("detail should be empty")
+		}
+	})
+}
+
+func Test_Catalog(t *testing.T) {
+	t.Run("Every catalog entry is keyed by its own code", func(t *testing.T) {
+		for code, entry := range apiErrors.Catalog {
+			if entry.Code != code || entry.Status == 0 || len(entry.Title) == 0 {
+				t.Fatalf("catalog entry " + code + " is incomplete")
+			}
+		}
+	})
+}
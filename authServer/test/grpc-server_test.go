@@ -0,0 +1,187 @@
+package authServerTest
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authPb"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+// makeGRPCClient serves the AuthService over an in-memory connection
+func makeGRPCClient(t *testing.T, tdbc mocks.TestDbController) authPb.AuthServiceClient {
+	var passedController dbController.DatabaseController = tdbc
+	ac := authServer.InitController(&passedController)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := authServer.NewGRPCServer(&ac)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, connErr := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if connErr != nil {
+		t.Fatalf("could not connect: " + connErr.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return authPb.NewAuthServiceClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// errorCode returns the reason of the ErrorInfo detail of a status error
+func errorCode(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+
+	return ""
+}
+
+func Test_GRPCServer(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	password := "password"
+	hash, _ := authUtils.HashPassword(password)
+
+	tdbc := mocks.MakeBlankTestDbController()
+	tdbc.SetUserDoc(dbController.FullUserDocument{
+		Id:           "123",
+		Username:     "user",
+		Enabled:      true,
+		PasswordHash: hash,
+	})
+	client := makeGRPCClient(t, tdbc)
+
+	userToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "user"})
+
+	t.Run("GetPublicKey returns the public key", func(t *testing.T) {
+		res, err := client.GetPublicKey(context.Background(), &authPb.GetPublicKeyRequest{})
+
+		if err != nil || res.PublicKey != os.Getenv(constants.RSA_PUBLIC_KEY) {
+			t.Fatalf("the public key should be returned")
+		}
+	})
+
+	t.Run("GetNonce returns a nonce", func(t *testing.T) {
+		res, err := client.GetNonce(context.Background(), &authPb.GetNonceRequest{})
+
+		if err != nil || len(res.Nonce) == 0 {
+			t.Fatalf("a nonce should be returned")
+		}
+	})
+
+	t.Run("Login returns a token", func(t *testing.T) {
+		res, err := client.Login(context.Background(), &authPb.LoginRequest{
+			Username: "user",
+			Password: password,
+			Nonce:    "YWJj",
+		})
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		claims, claimsErr := authCrypto.ValidateJWT(res.Token)
+		if claimsErr != nil || claims.Subject != "123" {
+			t.Fatalf("a valid token should be returned")
+		}
+	})
+
+	t.Run("Login returns InvalidArgument for missing values", func(t *testing.T) {
+		_, err := client.Login(context.Background(), &authPb.LoginRequest{Username: "user"})
+
+		if status.Code(err) != codes.InvalidArgument || errorCode(err) != apiErrors.InvalidRequestBody {
+			t.Fatalf("missing values should be rejected")
+		}
+	})
+
+	t.Run("Login uses the same error mapping as the HTTP routes", func(t *testing.T) {
+		_, err := client.Login(context.Background(), &authPb.LoginRequest{
+			Username: "user",
+			Password: "wrong",
+			Nonce:    "YWJj",
+		})
+
+		if status.Code(err) != codes.Unauthenticated || errorCode(err) != apiErrors.InvalidCredentials {
+			t.Fatalf("a wrong password should return invalid_credentials")
+		}
+	})
+
+	t.Run("EditUser requires a token", func(t *testing.T) {
+		_, err := client.EditUser(context.Background(), &authPb.EditUserRequest{Id: "123", Nonce: "YWJj"})
+
+		if status.Code(err) != codes.Unauthenticated || errorCode(err) != apiErrors.InvalidToken {
+			t.Fatalf("requests without a token should be rejected")
+		}
+	})
+
+	t.Run("EditUser succeeds with a valid token", func(t *testing.T) {
+		username := "user"
+		_, err := client.EditUser(withToken(userToken), &authPb.EditUserRequest{Id: "123", Username: &username, Nonce: "YWJj"})
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("EditUser doesn't let users change their own access", func(t *testing.T) {
+		admin := true
+		_, err := client.EditUser(withToken(userToken), &authPb.EditUserRequest{Id: "123", Admin: &admin, Nonce: "YWJj"})
+
+		if status.Code(err) != codes.PermissionDenied || errorCode(err) != apiErrors.Forbidden {
+			t.Fatalf("users should not be able to make themselves admins, got %v", err)
+		}
+	})
+
+	t.Run("EditUserPassword uses the token's claims", func(t *testing.T) {
+		_, err := client.EditUserPassword(withToken(userToken), &authPb.EditUserPasswordRequest{
+			Id:          "789",
+			NewPassword: "password",
+			Nonce:       "YWJj",
+		})
+
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("changing another user's password should be forbidden")
+		}
+	})
+
+	t.Run("AddUser requires an admin token", func(t *testing.T) {
+		_, err := client.AddUser(withToken(userToken), &authPb.AddUserRequest{
+			Username: "new",
+			Email:    "new@example.com",
+			Password: "password",
+			Nonce:    "YWJj",
+		})
+
+		if status.Code(err) != codes.PermissionDenied || errorCode(err) != apiErrors.Forbidden {
+			t.Fatalf("non-admins should not be able to add users")
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package authServerTest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+// unhealthyLogger is a logger that reports that it can't write logs
+type unhealthyLogger struct{}
+
+func (ul unhealthyLogger) AddRequestLog(log *authUtils.RequestLogData) error { return nil }
+func (ul unhealthyLogger) AddInfoLog(log *authUtils.InfoLogData) error       { return nil }
+func (ul unhealthyLogger) CheckHealth() error                                { return errors.New("disk full") }
+
+func getHealth(t *testing.T, as *authServer.AuthServer, path string) (int, authServer.HealthResponse) {
+	recorder := httptest.NewRecorder()
+	as.GinEngine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var response authServer.HealthResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("the response should be a HealthResponse: %s", err.Error())
+	}
+
+	return recorder.Code, response
+}
+
+func Test_HealthRoutes(t *testing.T) {
+	mocks.PrepTestRSAKeys()
+
+	t.Run("GET /healthz and GET / report that the service is alive", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetPingErr(dbController.NewDBError("connection refused"))
+		as := makeTestServer(tdbc)
+
+		for _, path := range []string{"/healthz", "/"} {
+			code, response := getHealth(t, as, path)
+
+			if code != 200 || response.Status != authServer.HealthOK || len(response.Checks) != 0 {
+				t.Fatalf("%s should return 200 without checks, got %d %v", path, code, response)
+			}
+		}
+	})
+
+	t.Run("GET /readyz returns 200 when every check passes", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+
+		code, response := getHealth(t, as, "/readyz")
+
+		if code != 200 || response.Status != authServer.HealthOK {
+			t.Fatalf("expected 200 and status ok, got %d %v", code, response)
+		}
+
+		for _, name := range []string{"database", "signingKeys", "loggers"} {
+			check, ok := response.Checks[name]
+			if !ok || check.Status != authServer.HealthOK || check.LatencyMs < 0 {
+				t.Fatalf("the %s check should pass, got %v", name, check)
+			}
+		}
+	})
+
+	t.Run("GET /readyz returns 503 when the database can't be reached", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetPingErr(dbController.NewDBError("dial tcp 10.0.0.5:27017: connection refused"))
+		as := makeTestServer(tdbc)
+
+		code, response := getHealth(t, as, "/readyz")
+
+		if code != 503 || response.Status != authServer.HealthUnavailable {
+			t.Fatalf("expected 503 and status unavailable, got %d %v", code, response)
+		}
+
+		database := response.Checks["database"]
+		if database.Status != authServer.HealthFailed || database.Error != "database is unreachable" {
+			t.Fatalf("the database check should fail without the database's address, got %v", database)
+		}
+
+		if response.Checks["signingKeys"].Status != authServer.HealthOK {
+			t.Fatalf("the other checks should still pass, got %v", response.Checks)
+		}
+	})
+
+	t.Run("GET /readyz returns 503 when the signing keys aren't available", func(t *testing.T) {
+		t.Setenv(constants.RSA_PRIVATE_KEY, "")
+
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+
+		code, response := getHealth(t, as, "/readyz")
+
+		if code != 503 || response.Checks["signingKeys"].Status != authServer.HealthFailed {
+			t.Fatalf("the signingKeys check should fail, got %d %v", code, response)
+		}
+	})
+
+	t.Run("GET /readyz returns 503 when a logger can't write logs", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+
+		var logger authUtils.AuthLogger = unhealthyLogger{}
+		as.AuthController.AddLogger(&logger)
+
+		code, response := getHealth(t, as, "/readyz")
+
+		loggers := response.Checks["loggers"]
+		if code != 503 || loggers.Status != authServer.HealthFailed || loggers.Error != "logger 0 can't write logs" {
+			t.Fatalf("the loggers check should fail, got %d %v", code, response)
+		}
+	})
+
+	t.Run("GET /readyz names the failing logger without its error", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+
+		asyncLogger := authUtils.NewAsyncLogger(unhealthyLogger{}, authUtils.AsyncLoggerOptions{Name: "file"})
+		defer asyncLogger.Close()
+
+		var logger authUtils.AuthLogger = asyncLogger
+		as.AuthController.AddLogger(&logger)
+
+		_, response := getHealth(t, as, "/readyz")
+
+		if loggers := response.Checks["loggers"]; loggers.Error != "file logger can't write logs" {
+			t.Fatalf("only the logger's name should be returned, got %s", loggers.Error)
+		}
+	})
+}
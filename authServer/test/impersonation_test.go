@@ -0,0 +1,165 @@
+package authServerTest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+// testLogger keeps the info logs it receives
+type testLogger struct {
+	infoLogs []authUtils.InfoLogData
+}
+
+func (tl *testLogger) AddRequestLog(log *authUtils.RequestLogData) error { return nil }
+func (tl *testLogger) AddInfoLog(log *authUtils.InfoLogData) error {
+	tl.infoLogs = append(tl.infoLogs, *log)
+	return nil
+}
+
+func Test_Impersonation(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	tdbc := mocks.MakeBlankTestDbController()
+	tdbc.SetUserDoc(dbController.FullUserDocument{
+		Id:       "456",
+		Username: "target",
+		Admin:    true,
+		Enabled:  true,
+	})
+
+	adminToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "admin", Admin: true})
+	adminClaims, _ := authCrypto.ValidateJWT(adminToken)
+
+	impersonate := func(as *authServer.AuthServer, token string) (int, string) {
+		req, _ := http.NewRequest("POST", "/users/456/impersonate", bytes.NewBufferString(`{"nonce": "YWJj"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		var response authServer.TokenResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		return recorder.Code, response.Token
+	}
+
+	t.Run("Impersonation tokens identify the admin in the act claim", func(t *testing.T) {
+		status, token := impersonate(makeTestServer(tdbc), adminToken)
+		claims, err := authCrypto.ValidateJWT(token)
+
+		if status != http.StatusOK || err != nil {
+			t.Fatalf("a valid token should be returned, got %d", status)
+		}
+		if claims.Subject != "456" || claims.Actor == nil || claims.Actor.Subject != "123" {
+			t.Fatalf("the token should be for the user with the admin as the actor")
+		}
+	})
+
+	t.Run("Impersonation tokens don't have admin rights or a long lifetime", func(t *testing.T) {
+		_, token := impersonate(makeTestServer(tdbc), adminToken)
+		claims, _ := authCrypto.ValidateJWT(token)
+
+		if claims.Admin {
+			t.Fatalf("the token shouldn't be an admin token")
+		}
+		if claims.ExpiresAt-claims.IssuedAt > int64(constants.IMPERSONATION_JWT_EXPIRATION.Seconds()) {
+			t.Fatalf("the token should be short lived")
+		}
+	})
+
+	t.Run("Impersonation tokens have a session that can be revoked", func(t *testing.T) {
+		_, token := impersonate(makeTestServer(tdbc), adminToken)
+		claims, _ := authCrypto.ValidateJWT(token)
+
+		if len(claims.SessionId) == 0 {
+			t.Fatalf("the token should have a session id")
+		}
+
+		revoked := mocks.MakeBlankTestDbController()
+		revoked.SetSessionDoc(dbController.SessionDocument{Id: claims.SessionId, UserId: "456", RevokedAt: time.Now().Unix()})
+
+		if _, err := makeTestServer(revoked).AuthController.ValidateToken(token); err == nil {
+			t.Fatalf("the token should be rejected once its session is revoked")
+		}
+	})
+
+	t.Run("Impersonation tokens without a session are rejected", func(t *testing.T) {
+		token, _ := authCrypto.GenerateImpersonationJWT(dbController.UserDocument{Id: "456"}, authCrypto.ActorClaim{Subject: "123"}, "")
+
+		if _, err := makeTestServer(tdbc).AuthController.ValidateToken(token); err == nil {
+			t.Fatalf("the token should be rejected")
+		}
+	})
+
+	t.Run("Impersonation tokens can't impersonate other users", func(t *testing.T) {
+		as := makeTestServer(tdbc)
+		_, token := impersonate(as, adminToken)
+
+		status, _ := impersonate(as, token)
+		if status != http.StatusForbidden {
+			t.Fatalf("status code should be 403, got %d", status)
+		}
+	})
+
+	t.Run("Impersonations are written to the loggers", func(t *testing.T) {
+		as := makeTestServer(tdbc)
+		logger := &testLogger{}
+		var authLogger authUtils.AuthLogger = logger
+		as.AuthController.AddLogger(&authLogger)
+
+		impersonate(as, adminToken)
+
+		if len(logger.infoLogs) != 1 || logger.infoLogs[0].Type != "audit" {
+			t.Fatalf("an audit log should be written")
+		}
+		event, _ := logger.infoLogs[0].Attributes["event"].(authUtils.AuditEvent)
+		if event.Action != authUtils.AuditImpersonate || event.ActorId != "123" || event.TargetId != "456" {
+			t.Fatalf("the log should identify the admin and the user, got %v", logger.infoLogs[0].Attributes)
+		}
+	})
+
+	t.Run("Admins can't impersonate themselves", func(t *testing.T) {
+		as := makeTestServer(tdbc)
+
+		_, err := as.AuthController.ImpersonateUser("123", "YWJj", adminClaims, mocks.MakeTestContext())
+		if _, ok := err.(dbController.InvalidInputError); !ok {
+			t.Fatalf("an InvalidInputError should be returned, got %T", err)
+		}
+	})
+
+	t.Run("Impersonation tokens can't change passwords", func(t *testing.T) {
+		as := makeTestServer(tdbc)
+		claims := &authCrypto.JWTClaims{Actor: &authCrypto.ActorClaim{Subject: "123"}}
+		claims.Subject = "456"
+
+		err := as.AuthController.EditUserPassword(&authServer.EditPasswordBody{Id: "456", Nonce: "YWJj"}, claims, mocks.MakeTestContext())
+		if _, ok := err.(authServer.UnauthorizedError); !ok {
+			t.Fatalf("an UnauthorizedError should be returned, got %T", err)
+		}
+	})
+
+	t.Run("Impersonation tokens can't change enabled or admin", func(t *testing.T) {
+		as := makeTestServer(tdbc)
+		claims := &authCrypto.JWTClaims{Actor: &authCrypto.ActorClaim{Subject: "123"}}
+		claims.Subject = "456"
+		admin := true
+
+		err := as.AuthController.EditUser(&authServer.EditUserBody{Id: "456", Nonce: "YWJj", Admin: &admin}, claims, mocks.MakeTestContext())
+		if _, ok := err.(authServer.UnauthorizedError); !ok {
+			t.Fatalf("an UnauthorizedError should be returned, got %T", err)
+		}
+	})
+}
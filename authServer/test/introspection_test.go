@@ -0,0 +1,94 @@
+package authServerTest
+
+import (
+	"os"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_ParseIntrospectionClients(t *testing.T) {
+	t.Run("ParseIntrospectionClients parses a list of clients", func(t *testing.T) {
+		clients, err := authServer.ParseIntrospectionClients("gateway:ABC, legacy:def")
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		if len(clients) != 2 || clients["gateway"] != "abc" || clients["legacy"] != "def" {
+			t.Fatalf("clients were not parsed correctly")
+		}
+	})
+
+	t.Run("ParseIntrospectionClients rejects malformed entries", func(t *testing.T) {
+		for _, str := range []string{"gateway", "gateway:", ":abc", "a:b:c"} {
+			if _, err := authServer.ParseIntrospectionClients(str); err == nil {
+				t.Fatalf("err should not be nil for '" + str + "'")
+			}
+		}
+	})
+}
+
+func Test_CheckIntrospectionClient(t *testing.T) {
+	os.Setenv(constants.INTROSPECTION_CLIENTS, "gateway:"+authUtils.HashString("secret"))
+	defer os.Unsetenv(constants.INTROSPECTION_CLIENTS)
+
+	t.Run("CheckIntrospectionClient returns true for a registered client and the correct secret", func(t *testing.T) {
+		if !authServer.CheckIntrospectionClient("gateway", "secret") {
+			t.Fatalf("result should be true")
+		}
+	})
+
+	t.Run("CheckIntrospectionClient returns false for the wrong secret", func(t *testing.T) {
+		if authServer.CheckIntrospectionClient("gateway", "wrong") {
+			t.Fatalf("result should be false")
+		}
+	})
+
+	t.Run("CheckIntrospectionClient returns false for unknown clients", func(t *testing.T) {
+		if authServer.CheckIntrospectionClient("unknown", "secret") {
+			t.Fatalf("result should be false")
+		}
+	})
+}
+
+func Test_IntrospectToken(t *testing.T) {
+	t.Run("IntrospectToken returns the claims of an active token", func(t *testing.T) {
+		mocks.PrepTestRSAKeys()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		token, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "alice"})
+
+		response := ac.IntrospectToken(token)
+
+		if response["active"] != true {
+			t.Fatalf("token should be active")
+		}
+
+		if response["sub"] != "123" || response["username"] != "alice" {
+			t.Fatalf("response should contain the token's claims")
+		}
+	})
+
+	t.Run("IntrospectToken only returns active false for an invalid token", func(t *testing.T) {
+		mocks.PrepTestRSAKeys()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		response := ac.IntrospectToken("not a token")
+
+		if response["active"] != false || len(response) != 1 {
+			t.Fatalf("response should only contain active false")
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package authServerTest
+
+import (
+	"os"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_CheckTokenRequest(t *testing.T) {
+	os.Setenv(constants.JWT_AUDIENCES, "my-app, my-other-app")
+	os.Setenv(constants.JWT_SCOPES, "read,write")
+	defer os.Unsetenv(constants.JWT_AUDIENCES)
+	defer os.Unsetenv(constants.JWT_SCOPES)
+
+	t.Run("CheckTokenRequest accepts configured audiences and scopes", func(t *testing.T) {
+		if err := authCrypto.CheckTokenRequest("my-other-app", []string{"read", "write"}); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("CheckTokenRequest accepts an empty audience", func(t *testing.T) {
+		if err := authCrypto.CheckTokenRequest("", nil); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("CheckTokenRequest rejects unknown audiences", func(t *testing.T) {
+		err := authCrypto.CheckTokenRequest("another-app", nil)
+
+		if _, ok := err.(dbController.InvalidInputError); !ok {
+			t.Fatalf("err should be an InvalidInputError")
+		}
+	})
+
+	t.Run("CheckTokenRequest rejects unknown scopes", func(t *testing.T) {
+		err := authCrypto.CheckTokenRequest("my-app", []string{"read", "admin"})
+
+		if _, ok := err.(dbController.InvalidInputError); !ok {
+			t.Fatalf("err should be an InvalidInputError")
+		}
+	})
+}
+
+func Test_ParseClockSkew(t *testing.T) {
+	t.Run("ParseClockSkew returns the default clock skew for an empty string", func(t *testing.T) {
+		skew, err := authCrypto.ParseClockSkew("")
+
+		if err != nil || skew != constants.JWT_DEFAULT_CLOCK_SKEW {
+			t.Fatalf("skew should be the default clock skew")
+		}
+	})
+
+	t.Run("ParseClockSkew rejects invalid and negative durations", func(t *testing.T) {
+		for _, str := range []string{"abc", "-5s"} {
+			if _, err := authCrypto.ParseClockSkew(str); err == nil {
+				t.Fatalf("err should not be nil for '" + str + "'")
+			}
+		}
+	})
+}
+
+func Test_GenerateScopedJWT(t *testing.T) {
+	t.Run("GenerateScopedJWT issues a token for the configured issuer and requested audience and scopes", func(t *testing.T) {
+		mocks.PrepTestRSAKeys()
+		os.Setenv(constants.JWT_ISSUER, "auth")
+		os.Setenv(constants.JWT_AUDIENCES, "my-app,my-other-app")
+		defer os.Unsetenv(constants.JWT_ISSUER)
+		defer os.Unsetenv(constants.JWT_AUDIENCES)
+
+		token, tokenErr := authCrypto.GenerateScopedJWT(dbController.UserDocument{Id: "123"}, "my-other-app", []string{"read"})
+		if tokenErr != nil {
+			t.Fatalf("tokenErr should be nil: " + tokenErr.Error())
+		}
+
+		claims, claimsErr := authCrypto.ValidateJWT(token)
+		if claimsErr != nil {
+			t.Fatalf("claimsErr should be nil: " + claimsErr.Error())
+		}
+
+		if claims.Issuer != "auth" || claims.Audience != "my-other-app" || claims.Scope != "read" {
+			t.Fatalf("claims do not match the request")
+		}
+
+		if claims.IssuedAt == 0 || claims.NotBefore == 0 {
+			t.Fatalf("iat and nbf should be set")
+		}
+	})
+
+	t.Run("GenerateScopedJWT uses the first audience by default", func(t *testing.T) {
+		mocks.PrepTestRSAKeys()
+		os.Setenv(constants.JWT_AUDIENCES, "my-app,my-other-app")
+		defer os.Unsetenv(constants.JWT_AUDIENCES)
+
+		token, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123"})
+		claims, claimsErr := authCrypto.ValidateJWT(token)
+
+		if claimsErr != nil {
+			t.Fatalf("claimsErr should be nil: " + claimsErr.Error())
+		}
+
+		if claims.Audience != "my-app" {
+			t.Fatalf("audience should be the default audience")
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package jwtVerifierTest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"methompson.com/auth-microservice/authServer/jwtVerifier"
+)
+
+var now = time.Unix(1600000000, 0)
+
+func makeKey(t *testing.T) *rsa.PrivateKey {
+	key, keyErr := rsa.GenerateKey(rand.Reader, 2048)
+	if keyErr != nil {
+		t.Fatalf("keyErr should be nil: " + keyErr.Error())
+	}
+
+	return key
+}
+
+func makeToken(t *testing.T, key *rsa.PrivateKey, claims jwtVerifier.JWTClaims) string {
+	token, tokenErr := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if tokenErr != nil {
+		t.Fatalf("tokenErr should be nil: " + tokenErr.Error())
+	}
+
+	return token
+}
+
+func makeClaims() jwtVerifier.JWTClaims {
+	return jwtVerifier.JWTClaims{
+		Username: "alice",
+		Scope:    "read write",
+		StandardClaims: jwt.StandardClaims{
+			Audience:  "my-app",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+			Issuer:    "auth",
+			NotBefore: now.Unix(),
+			Subject:   "123",
+		},
+	}
+}
+
+func makeVerifier(t *testing.T, key *rsa.PrivateKey) *jwtVerifier.Verifier {
+	verifier, verifierErr := jwtVerifier.NewVerifier(jwtVerifier.Options{
+		PublicKey: &key.PublicKey,
+		Issuer:    "auth",
+		Audiences: []string{"my-app", "my-other-app"},
+		ClockSkew: 30 * time.Second,
+		Now:       func() time.Time { return now },
+	})
+
+	if verifierErr != nil {
+		t.Fatalf("verifierErr should be nil: " + verifierErr.Error())
+	}
+
+	return verifier
+}
+
+func Test_NewVerifier(t *testing.T) {
+	t.Run("NewVerifier returns an error if no public key is provided", func(t *testing.T) {
+		_, err := jwtVerifier.NewVerifier(jwtVerifier.Options{})
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+}
+
+func Test_Verify(t *testing.T) {
+	key := makeKey(t)
+
+	t.Run("Verify returns the claims of a valid token", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims, err := verifier.Verify(makeToken(t, key, makeClaims()))
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		if claims.Subject != "123" || claims.Username != "alice" {
+			t.Fatalf("claims were not decoded correctly")
+		}
+
+		if !claims.HasScope("write") || claims.HasScope("admin") {
+			t.Fatalf("scopes were not decoded correctly")
+		}
+	})
+
+	t.Run("Verify returns a JWTError if the token was signed with a different key", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		_, err := verifier.Verify(makeToken(t, makeKey(t), makeClaims()))
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+
+	t.Run("Verify returns a JWTError if the token isn't signed with RSA", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, makeClaims()).SignedString([]byte("secret"))
+
+		_, err := verifier.Verify(token)
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+
+	t.Run("Verify returns an ExpiredJWTError if the token expired longer ago than the clock skew", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.ExpiresAt = now.Add(-time.Minute).Unix()
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if _, ok := err.(jwtVerifier.ExpiredJWTError); !ok {
+			t.Fatalf("err should be an ExpiredJWTError")
+		}
+	})
+
+	t.Run("Verify accepts a token that expired within the clock skew", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.ExpiresAt = now.Add(-10 * time.Second).Unix()
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("Verify returns a JWTError if the token has no expiration", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.ExpiresAt = 0
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+
+	t.Run("Verify returns a JWTError if the token is not valid yet", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.NotBefore = now.Add(time.Minute).Unix()
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+
+	t.Run("Verify returns a JWTError if the token was issued in the future", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.IssuedAt = now.Add(time.Minute).Unix()
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+
+	t.Run("Verify returns a JWTError if the issuer doesn't match", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.Issuer = "someone-else"
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+
+	t.Run("Verify returns a JWTError if the audience isn't accepted", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.Audience = "another-app"
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if _, ok := err.(jwtVerifier.JWTError); !ok {
+			t.Fatalf("err should be a JWTError")
+		}
+	})
+
+	t.Run("Verify accepts any of the configured audiences", func(t *testing.T) {
+		verifier := makeVerifier(t, key)
+
+		claims := makeClaims()
+		claims.Audience = "my-other-app"
+
+		_, err := verifier.Verify(makeToken(t, key, claims))
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package authServerTest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func makeTestLogs(count int) []dbController.LogDocument {
+	logs := make([]dbController.LogDocument, 0, count)
+	for i := 0; i < count; i++ {
+		logs = append(logs, dbController.LogDocument{Timestamp: time.Now(), Type: "request", Path: "/login", StatusCode: 200})
+	}
+
+	return logs
+}
+
+func Test_GetLogs(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	adminToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "admin", Admin: true})
+
+	request := func(as *authServer.AuthServer, token string, query string) (*httptest.ResponseRecorder, authServer.LogsResponse) {
+		req, _ := http.NewRequest("GET", "/logs"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		var response authServer.LogsResponse
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		return recorder, response
+	}
+
+	t.Run("GET /logs passes the filters to the database", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetLogs(makeTestLogs(1))
+		as := makeTestServer(tdbc)
+
+		recorder, response := request(as, adminToken, "?type=request&status=404&path=/login&ip=10.0.0.1&since=2021-01-01T00:00:00Z&until=2021-02-01T00:00:00Z")
+
+		if recorder.Code != http.StatusOK || len(response.Logs) != 1 {
+			t.Fatalf("the logs should be returned, got %d", recorder.Code)
+		}
+
+		filter := tdbc.LastLogFilter()
+		if filter.Type != "request" || filter.StatusCode != 404 || filter.Path != "/login" || filter.ClientIP != "10.0.0.1" {
+			t.Fatalf("the filters should be passed to the database: %v", filter)
+		}
+		if filter.Since.Month() != time.January || filter.Until.Month() != time.February {
+			t.Fatalf("the time range should be passed to the database: %v", filter)
+		}
+	})
+
+	t.Run("GET /logs returns pages of logs", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetLogs(makeTestLogs(5))
+		as := makeTestServer(tdbc)
+
+		_, first := request(as, adminToken, "?limit=2")
+		if len(first.Logs) != 2 || first.Offset != 0 || first.Limit != 2 || !first.HasMore {
+			t.Fatalf("the first page should be returned: %v", first)
+		}
+
+		_, last := request(as, adminToken, "?limit=2&offset=4")
+		if len(last.Logs) != 1 || last.Offset != 4 || last.HasMore {
+			t.Fatalf("the last page should be returned: %v", last)
+		}
+	})
+
+	t.Run("GET /logs limits the number of logs", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		as := makeTestServer(tdbc)
+
+		_, response := request(as, adminToken, "")
+		if response.Limit != 100 || tdbc.LastLogFilter().Limit != 101 {
+			t.Fatalf("the default limit should be used, and one more log requested")
+		}
+
+		_, response = request(as, adminToken, "?limit=100000")
+		if response.Limit != 1000 {
+			t.Fatalf("the maximum limit should be used")
+		}
+	})
+
+	t.Run("GET /logs rejects invalid filters", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+
+		for _, query := range []string{"?since=yesterday", "?status=ok", "?offset=first"} {
+			recorder, _ := request(as, adminToken, query)
+			if recorder.Code != http.StatusBadRequest {
+				t.Fatalf("%s should return 400, got %d", query, recorder.Code)
+			}
+		}
+	})
+
+	t.Run("GET /logs returns database errors", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetLogsErr(dbController.NewDBError("database error"))
+		as := makeTestServer(tdbc)
+
+		recorder, _ := request(as, adminToken, "")
+		if recorder.Code != http.StatusInternalServerError {
+			t.Fatalf("status code should be 500, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("GET /logs requires an admin token", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+		userToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "456", Username: "user"})
+
+		recorder, _ := request(as, userToken, "")
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403, got %d", recorder.Code)
+		}
+	})
+}
+
+func Test_ParseLogRetention(t *testing.T) {
+	t.Run("ParseLogRetention parses a list of types and durations", func(t *testing.T) {
+		retention, err := dbController.ParseLogRetention("request:720h, default:24h")
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		now := time.Now()
+
+		if expiresAt, ok := retention.ExpiresAt("request", now); !ok || !expiresAt.Equal(now.Add(720*time.Hour)) {
+			t.Fatalf("request logs should expire after 720h")
+		}
+		if expiresAt, ok := retention.ExpiresAt("audit", now); !ok || !expiresAt.Equal(now.Add(24*time.Hour)) {
+			t.Fatalf("other logs should use the default")
+		}
+	})
+
+	t.Run("Logs are kept forever without a retention or a default", func(t *testing.T) {
+		retention, _ := dbController.ParseLogRetention("request:720h")
+
+		if _, ok := retention.ExpiresAt("audit", time.Now()); ok {
+			t.Fatalf("audit logs shouldn't expire")
+		}
+	})
+
+	t.Run("ParseLogRetention returns an error for invalid values", func(t *testing.T) {
+		for _, str := range []string{"request", "request:forever", ":24h", "request:-1h", "request:1h:2h"} {
+			if _, err := dbController.ParseLogRetention(str); err == nil {
+				t.Fatalf("'%s' should be rejected", str)
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package authServerTest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_MeRoutes(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	tdbc := mocks.MakeBlankTestDbController()
+	tdbc.SetUserDoc(dbController.FullUserDocument{
+		Id:       "123",
+		Username: "user",
+		Email:    "new@example.com",
+		Enabled:  true,
+	})
+	as := makeTestServer(tdbc)
+
+	// The token's claims are older than the stored user
+	token, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "user", Email: "old@example.com"})
+	claims, _ := authCrypto.ValidateJWT(token)
+
+	request := func(method string, token string, body string) (int, authServer.MeResponse) {
+		req, _ := http.NewRequest(method, "/me", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		var me authServer.MeResponse
+		json.Unmarshal(recorder.Body.Bytes(), &me)
+
+		return recorder.Code, me
+	}
+
+	t.Run("GET /me returns the stored user rather than the token's claims", func(t *testing.T) {
+		status, me := request("GET", token, "")
+
+		if status != http.StatusOK || me.User.Id != "123" || me.User.Email != "new@example.com" {
+			t.Fatalf("the stored user should be returned")
+		}
+	})
+
+	t.Run("GET /me returns the token's metadata", func(t *testing.T) {
+		_, me := request("GET", token, "")
+
+		if me.Token.ExpiresAt != claims.ExpiresAt || me.Token.IssuedAt != claims.IssuedAt {
+			t.Fatalf("the token's times should be returned")
+		}
+	})
+
+	t.Run("GET /me requires a token", func(t *testing.T) {
+		status, _ := request("GET", "", "")
+
+		if status != http.StatusUnauthorized {
+			t.Fatalf("status code should be 401")
+		}
+	})
+
+	t.Run("PATCH /me edits the caller without an id in the body", func(t *testing.T) {
+		status, me := request("PATCH", token, `{"nonce": "YWJj", "attributes": {"displayName": "User"}}`)
+
+		if status != http.StatusOK || me.User.Id != "123" {
+			t.Fatalf("status code should be 200, got %d", status)
+		}
+	})
+
+	t.Run("PatchMeBody can't change enabled or admin", func(t *testing.T) {
+		var body authServer.PatchMeBody
+		json.Unmarshal([]byte(`{"nonce": "YWJj", "admin": true, "enabled": false}`), &body)
+
+		editBody := body.EditUserBody("123")
+		if editBody.Admin != nil || editBody.Enabled != nil || editBody.Id != "123" {
+			t.Fatalf("only profile fields should be passed to EditUser")
+		}
+	})
+}
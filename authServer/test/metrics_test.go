@@ -0,0 +1,172 @@
+package authServerTest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authMetrics"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+// countOperation returns a function that counts the operations it observes and
+// records the last error it was called with
+func countOperation(counts map[string]int, lastErr *error) dbController.OperationObserver {
+	return func(ctx context.Context, operation string) func(err error) {
+		counts[operation]++
+
+		return func(err error) {
+			*lastErr = err
+		}
+	}
+}
+
+func Test_MetricsRoute(t *testing.T) {
+	t.Run("GET /metrics returns the metrics in the Prometheus exposition format", func(t *testing.T) {
+		as := makeTestServer(mocks.MakeBlankTestDbController())
+
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		if recorder.Code != 200 {
+			t.Fatalf("expected status 200, got %d", recorder.Code)
+		}
+
+		if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+			t.Fatalf("expected a text/plain response, got %s", contentType)
+		}
+
+		for _, name := range []string{"auth_nonces", "auth_nonce_cleanout_last_success_timestamp_seconds", "go_goroutines"} {
+			if !strings.Contains(recorder.Body.String(), "\n"+name+" ") {
+				t.Fatalf("expected the response to include %s", name)
+			}
+		}
+	})
+}
+
+func Test_LoginMetrics(t *testing.T) {
+	login := func(tdbc mocks.TestDbController) {
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		ac.LogUserIn(authServer.LoginBody{Username: "test", Password: "test", Nonce: "MQ=="}, mocks.MakeTestContext())
+	}
+
+	t.Run("Failed logins are counted by error code", func(t *testing.T) {
+		counter := authMetrics.Logins.WithLabelValues("invalid_nonce")
+		before := testutil.ToFloat64(counter)
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetNonceDocErr(authUtils.NewNonceError(""))
+		login(tdbc)
+
+		if count := testutil.ToFloat64(counter) - before; count != 1 {
+			t.Fatalf("expected 1 invalid_nonce login, got %v", count)
+		}
+	})
+
+	t.Run("Logins with an unknown username are counted as unknown_user", func(t *testing.T) {
+		counter := authMetrics.Logins.WithLabelValues("unknown_user")
+		before := testutil.ToFloat64(counter)
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDocErr(dbController.NewNoResultsError(""))
+		login(tdbc)
+
+		if count := testutil.ToFloat64(counter) - before; count != 1 {
+			t.Fatalf("expected 1 unknown_user login, got %v", count)
+		}
+	})
+}
+
+func Test_NonceMetrics(t *testing.T) {
+	t.Run("RemoveOldNonces records a successful cleanout and the number of nonces", func(t *testing.T) {
+		counter := authMetrics.NonceCleanouts.WithLabelValues(authMetrics.CleanoutSuccess)
+		before := testutil.ToFloat64(counter)
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetNonceCount(42)
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		if removeErr := ac.RemoveOldNonces(); removeErr != nil {
+			t.Fatalf("RemoveOldNonces should succeed, got %s", removeErr.Error())
+		}
+
+		if count := testutil.ToFloat64(counter) - before; count != 1 {
+			t.Fatalf("expected 1 successful cleanout, got %v", count)
+		}
+
+		if nonces := testutil.ToFloat64(authMetrics.Nonces); nonces != 42 {
+			t.Fatalf("expected 42 nonces, got %v", nonces)
+		}
+
+		if testutil.ToFloat64(authMetrics.NonceCleanoutLastSuccess) == 0 {
+			t.Fatalf("expected the time of the last successful cleanout to be set")
+		}
+	})
+
+	t.Run("RemoveOldNonces records a failed cleanout", func(t *testing.T) {
+		counter := authMetrics.NonceCleanouts.WithLabelValues(authMetrics.CleanoutFailure)
+		before := testutil.ToFloat64(counter)
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetRemoveOldNoncesErr(dbController.NewDBError(""))
+		var passedController dbController.DatabaseController = tdbc
+		ac := authServer.InitController(&passedController)
+
+		if removeErr := ac.RemoveOldNonces(); removeErr == nil {
+			t.Fatalf("RemoveOldNonces should fail")
+		}
+
+		if count := testutil.ToFloat64(counter) - before; count != 1 {
+			t.Fatalf("expected 1 failed cleanout, got %v", count)
+		}
+	})
+}
+
+func Test_InstrumentedController(t *testing.T) {
+	t.Run("InstrumentedController observes every operation and its error", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetUserDocErr(dbController.NewDBError("connection lost"))
+
+		counts := make(map[string]int)
+		var lastErr error
+		ic := dbController.NewInstrumentedController(tdbc, countOperation(counts, &lastErr))
+
+		ic.GetUserById("123")
+		if counts["GetUserById"] != 1 {
+			t.Fatalf("expected GetUserById to be observed once, got %d", counts["GetUserById"])
+		}
+		if _, ok := lastErr.(dbController.DBError); !ok {
+			t.Fatalf("expected the observer to receive the DBError, got %v", lastErr)
+		}
+
+		ic.RemoveOldNonces(0)
+		if counts["RemoveOldNonces"] != 1 || lastErr != nil {
+			t.Fatalf("expected RemoveOldNonces to be observed without an error")
+		}
+	})
+
+	t.Run("InstrumentedController writes logs one at a time to a controller that can't batch them", func(t *testing.T) {
+		counts := make(map[string]int)
+		var lastErr error
+		ic := dbController.NewInstrumentedController(mocks.MakeBlankTestDbController(), countOperation(counts, &lastErr))
+
+		ic.AddLogs([]authUtils.LogEntry{
+			{Request: &authUtils.RequestLogData{Type: "request"}},
+			{Info: &authUtils.InfoLogData{Type: "log"}},
+		})
+
+		if counts["AddRequestLog"] != 1 || counts["AddInfoLog"] != 1 || counts["AddLogs"] != 0 {
+			t.Fatalf("expected each log to be written separately, got %v", counts)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package openApiTest
+
+import (
+	"testing"
+
+	"methompson.com/auth-microservice/authServer/openApi"
+)
+
+type testBody struct {
+	Name    string            `json:"name" binding:"required"`
+	Count   int               `json:"count"`
+	Enabled *bool             `json:"enabled"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Hidden  string            `json:"-"`
+	private string
+}
+
+func Test_SchemaFor(t *testing.T) {
+	schema := openApi.SchemaFor(testBody{})
+
+	t.Run("SchemaFor uses json tag names and skips hidden fields", func(t *testing.T) {
+		if len(schema.Properties) != 5 {
+			t.Fatalf("schema should have 5 properties")
+		}
+
+		if _, ok := schema.Properties["Hidden"]; ok {
+			t.Fatalf("fields tagged json:\"-\" should be skipped")
+		}
+	})
+
+	t.Run("SchemaFor maps Go types to schema types", func(t *testing.T) {
+		if schema.Properties["count"].Type != "integer" ||
+			schema.Properties["enabled"].Type != "boolean" ||
+			schema.Properties["tags"].Items.Type != "string" ||
+			schema.Properties["labels"].AdditionalProperties.Type != "string" {
+			t.Fatalf("types were not mapped correctly")
+		}
+	})
+
+	t.Run("SchemaFor marks binding:\"required\" fields as required and non-empty", func(t *testing.T) {
+		if len(schema.Required) != 1 || schema.Required[0] != "name" || schema.Properties["name"].MinLength != 1 {
+			t.Fatalf("name should be required")
+		}
+	})
+
+	t.Run("SchemaFor makes pointer fields nullable", func(t *testing.T) {
+		if !schema.Properties["enabled"].Nullable || schema.Properties["count"].Nullable {
+			t.Fatalf("only pointer fields should be nullable")
+		}
+	})
+}
+
+func Test_Validate(t *testing.T) {
+	doc := openApi.NewDocument(openApi.Info{Title: "test", Version: "1"})
+	ref := doc.AddSchema("testBody", testBody{})
+
+	t.Run("Validate accepts matching values", func(t *testing.T) {
+		value := map[string]interface{}{
+			"name":    "name",
+			"count":   float64(3),
+			"enabled": nil,
+			"tags":    []interface{}{"a"},
+			"labels":  map[string]interface{}{"a": "b"},
+			"unknown": true,
+		}
+
+		if err := doc.Validate(ref, value); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("Validate names the invalid value", func(t *testing.T) {
+		cases := map[string]map[string]interface{}{
+			"name is required":          {},
+			"count must be an integer":  {"name": "a", "count": 1.5},
+			"tags[1] must be a string":  {"name": "a", "tags": []interface{}{"a", 1.0}},
+			"labels.a must be a string": {"name": "a", "labels": map[string]interface{}{"a": true}},
+			"count must not be null":    {"name": "a", "count": nil},
+		}
+
+		for expected, value := range cases {
+			err := doc.Validate(ref, value)
+
+			if err == nil || err.Error() != expected {
+				t.Fatalf("expected '" + expected + "'")
+			}
+		}
+	})
+}
+
+func Test_ToOpenAPIPath(t *testing.T) {
+	t.Run("ToOpenAPIPath converts Gin path parameters", func(t *testing.T) {
+		if openApi.ToOpenAPIPath("/users/:id/password") != "/users/{id}/password" {
+			t.Fatalf("path was not converted")
+		}
+	})
+
+	t.Run("AddOperation describes path parameters", func(t *testing.T) {
+		doc := openApi.NewDocument(openApi.Info{Title: "test", Version: "1"})
+		doc.AddOperation("GET", "/users/:id", &openApi.Operation{OperationID: "getUser"})
+
+		op, ok := doc.Operation("GET", "/users/:id")
+		if !ok || len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" {
+			t.Fatalf("the id parameter should be described")
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package authServerTest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/dbController"
+	"methompson.com/auth-microservice/authServer/openApi"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+// makeTestServer returns an AuthServer with all routes set, backed by tdbc
+func makeTestServer(tdbc mocks.TestDbController) *authServer.AuthServer {
+	gin.SetMode(gin.TestMode)
+
+	var passedController dbController.DatabaseController = tdbc
+
+	as := &authServer.AuthServer{
+		AuthController: authServer.InitController(&passedController),
+		GinEngine:      gin.New(),
+	}
+	as.SetRoutes()
+
+	return as
+}
+
+func Test_OpenAPISpec(t *testing.T) {
+	as := makeTestServer(mocks.MakeBlankTestDbController())
+	spec := authServer.OpenAPISpec()
+
+	t.Run("Every route is described in the OpenAPI spec", func(t *testing.T) {
+		for _, route := range as.GinEngine.Routes() {
+			if _, ok := spec.Operation(route.Method, route.Path); !ok {
+				t.Fatalf(route.Method + " " + route.Path + " is missing from the OpenAPI spec")
+			}
+		}
+	})
+
+	t.Run("Every operation in the OpenAPI spec has a route", func(t *testing.T) {
+		routes := make(map[string]bool)
+		for _, route := range as.GinEngine.Routes() {
+			routes[route.Method+" "+openApi.ToOpenAPIPath(route.Path)] = true
+		}
+
+		spec.Operations(func(method string, path string, op *openApi.Operation) {
+			if !routes[method+" "+path] {
+				t.Fatalf(method + " " + path + " is in the OpenAPI spec, but has no route")
+			}
+		})
+	})
+
+	t.Run("Every schema reference in the OpenAPI spec can be resolved", func(t *testing.T) {
+		spec.Operations(func(method string, path string, op *openApi.Operation) {
+			if op.RequestBody == nil {
+				return
+			}
+
+			for contentType, media := range op.RequestBody.Content {
+				if spec.Resolve(media.Schema) == nil {
+					t.Fatalf(method + " " + path + " has an unknown " + contentType + " schema")
+				}
+			}
+		})
+	})
+
+	t.Run("The spec's required fields match the body structs", func(t *testing.T) {
+		required := spec.Components.Schemas["LoginBody"].Required
+		expected := []string{"username", "password", "nonce"}
+
+		if len(required) != len(expected) {
+			t.Fatalf("LoginBody should have 3 required fields")
+		}
+
+		for i, name := range expected {
+			if required[i] != name {
+				t.Fatalf("expected " + name + " to be required")
+			}
+		}
+	})
+
+	t.Run("GET /openapi.json serves the spec", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/openapi.json", nil)
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		var doc openApi.Document
+		if err := json.Unmarshal(recorder.Body.Bytes(), &doc); err != nil {
+			t.Fatalf("body should be a JSON document: " + err.Error())
+		}
+
+		if recorder.Code != http.StatusOK || doc.OpenAPI != openApi.Version || len(doc.Paths) == 0 {
+			t.Fatalf("the OpenAPI document was not served correctly")
+		}
+	})
+}
+
+func Test_ValidateRequests(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	as := makeTestServer(mocks.MakeBlankTestDbController())
+	adminToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "admin", Admin: true})
+
+	request := func(method string, path string, token string, body string) (int, apiErrors.Problem) {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		var problem apiErrors.Problem
+		json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+		return recorder.Code, problem
+	}
+
+	post := func(path string, body string) (int, apiErrors.Problem) {
+		return request("POST", path, adminToken, body)
+	}
+
+	t.Run("Bodies missing required fields are rejected", func(t *testing.T) {
+		status, problem := post("/login", `{"username": "user", "nonce": "abc"}`)
+
+		if status != http.StatusBadRequest || problem.Code != apiErrors.InvalidRequestBody || problem.Detail != "password is required" {
+			t.Fatalf("the missing password should be reported, got: " + problem.Detail)
+		}
+	})
+
+	t.Run("Fields with the wrong type are rejected", func(t *testing.T) {
+		status, problem := post("/login", `{"username": 5, "password": "pass", "nonce": "abc"}`)
+
+		if status != http.StatusBadRequest || problem.Detail != "username must be a string" {
+			t.Fatalf("the invalid username should be reported, got: " + problem.Detail)
+		}
+	})
+
+	t.Run("Empty required strings are rejected", func(t *testing.T) {
+		status, problem := post("/login", `{"username": "", "password": "pass", "nonce": "abc"}`)
+
+		if status != http.StatusBadRequest || problem.Detail != "username must not be empty" {
+			t.Fatalf("the empty username should be reported, got: " + problem.Detail)
+		}
+	})
+
+	t.Run("Invalid JSON is rejected", func(t *testing.T) {
+		status, problem := post("/login", `{"username":`)
+
+		if status != http.StatusBadRequest || problem.Code != apiErrors.InvalidRequestBody {
+			t.Fatalf("invalid JSON should be rejected")
+		}
+	})
+
+	t.Run("Attributes are validated against the attribute schema", func(t *testing.T) {
+		status, problem := post("/edit-user", `{"id": "1", "nonce": "abc", "attributes": {"displayName": 5}}`)
+
+		if status != http.StatusBadRequest || problem.Detail != "attributes.displayName must be a string" {
+			t.Fatalf("the invalid attribute should be reported, got: " + problem.Detail)
+		}
+	})
+
+	t.Run("Valid bodies reach the route", func(t *testing.T) {
+		status, problem := post("/edit-user", `{"id": "1", "nonce": "abc", "attributes": {"displayName": null}}`)
+
+		if status == http.StatusBadRequest && problem.Code == apiErrors.InvalidRequestBody {
+			t.Fatalf("the request should reach the route, got: " + problem.Detail)
+		}
+	})
+
+	t.Run("Unauthenticated requests are rejected before their bodies are validated", func(t *testing.T) {
+		for _, path := range []string{"/add-user", "/edit-user", "/v1/users"} {
+			status, problem := request("POST", path, "", `{"attributes": 5}`)
+
+			if status != http.StatusUnauthorized {
+				t.Fatalf(path + " should respond with 401, got " + problem.Code)
+			}
+		}
+	})
+
+	t.Run("Every operation with a request body validates it", func(t *testing.T) {
+		authServer.OpenAPISpec().Operations(func(method string, path string, op *openApi.Operation) {
+			if op.RequestBody == nil {
+				return
+			}
+
+			ginPath := strings.NewReplacer("{id}", "456", "{sessionId}", "abc").Replace(path)
+			status, problem := request(strings.ToUpper(method), ginPath, adminToken, `[]`)
+
+			// The routes reject the body too, but with a detail of their own
+			if status != http.StatusBadRequest || problem.Code != apiErrors.InvalidRequestBody || problem.Detail == "missing required values" {
+				t.Fatalf(method + " " + path + " should validate its body, got " + problem.Code + " " + problem.Detail)
+			}
+		})
+	})
+}
@@ -0,0 +1,203 @@
+package authServerTest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_Sessions(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	user := dbController.UserDocument{Id: "123", Username: "user"}
+	token, _ := authCrypto.GenerateSessionJWT(user, "", nil, "abc")
+	claims, _ := authCrypto.ValidateJWT(token)
+
+	activeSession := func() dbController.SessionDocument {
+		return dbController.SessionDocument{
+			Id:         "abc",
+			UserId:     "123",
+			CreatedAt:  time.Now().Unix(),
+			LastSeenAt: time.Now().Unix(),
+			ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	t.Run("Tokens for an active session are accepted", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(activeSession())
+		as := makeTestServer(tdbc)
+
+		validated, err := as.AuthController.ValidateToken(token)
+		if err != nil || validated.SessionId != "abc" {
+			t.Fatalf("the token should be accepted, got %v", err)
+		}
+	})
+
+	t.Run("Tokens for a revoked session are rejected", func(t *testing.T) {
+		session := activeSession()
+		session.RevokedAt = time.Now().Unix()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(session)
+		as := makeTestServer(tdbc)
+
+		_, err := as.AuthController.ValidateToken(token)
+		if _, ok := err.(authCrypto.JWTError); !ok {
+			t.Fatalf("a JWTError should be returned, got %T", err)
+		}
+	})
+
+	t.Run("Tokens for a missing session are rejected", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDocErr(dbController.NewNoResultsError(""))
+		as := makeTestServer(tdbc)
+
+		_, err := as.AuthController.ValidateToken(token)
+		if _, ok := err.(authCrypto.JWTError); !ok {
+			t.Fatalf("a JWTError should be returned, got %T", err)
+		}
+	})
+
+	t.Run("Requests are rejected with a 503 if the session can't be read", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDocErr(dbController.NewDBError("connection refused by mongodb://db:27017"))
+		as := makeTestServer(tdbc)
+
+		req, _ := http.NewRequest("GET", "/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status code should be 503, got %d", recorder.Code)
+		}
+		if strings.Contains(recorder.Body.String(), "mongodb") {
+			t.Fatalf("the database error should not be sent")
+		}
+	})
+
+	t.Run("Tokens without a session id are accepted", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDocErr(dbController.NewNoResultsError(""))
+		as := makeTestServer(tdbc)
+
+		legacyToken, _ := authCrypto.GenerateJWT(user)
+		if _, err := as.AuthController.ValidateToken(legacyToken); err != nil {
+			t.Fatalf("the token should be accepted, got %v", err)
+		}
+	})
+
+	t.Run("Introspecting a token for a revoked session returns active false", func(t *testing.T) {
+		session := activeSession()
+		session.RevokedAt = time.Now().Unix()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(session)
+		as := makeTestServer(tdbc)
+
+		result := as.AuthController.IntrospectToken(token)
+		if result["active"] != false {
+			t.Fatalf("the token should be inactive")
+		}
+	})
+
+	t.Run("GetSessions doesn't return expired or revoked sessions", func(t *testing.T) {
+		expired := activeSession()
+		expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(expired)
+		as := makeTestServer(tdbc)
+
+		sessions, err := as.AuthController.GetSessions("123", claims)
+		if err != nil || len(sessions) != 0 {
+			t.Fatalf("no sessions should be returned")
+		}
+	})
+
+	t.Run("GetSessions can't return another user's sessions", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(activeSession())
+		as := makeTestServer(tdbc)
+
+		_, err := as.AuthController.GetSessions("456", claims)
+		if _, ok := err.(authServer.UnauthorizedError); !ok {
+			t.Fatalf("an UnauthorizedError should be returned, got %T", err)
+		}
+	})
+
+	t.Run("RevokeSession reports another user's session as missing", func(t *testing.T) {
+		session := activeSession()
+		session.UserId = "456"
+
+		adminClaims := *claims
+		adminClaims.Admin = true
+
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(session)
+		as := makeTestServer(tdbc)
+
+		err := as.AuthController.RevokeSession("123", "abc", "YWJj", &adminClaims, mocks.MakeTestContext())
+		if _, ok := err.(dbController.NoResultsError); !ok {
+			t.Fatalf("a NoResultsError should be returned, got %T", err)
+		}
+	})
+
+	t.Run("GET /sessions marks the caller's session as current", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(activeSession())
+		as := makeTestServer(tdbc)
+
+		req, _ := http.NewRequest("GET", "/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		var sessions []authServer.SessionResponse
+		json.Unmarshal(recorder.Body.Bytes(), &sessions)
+
+		if recorder.Code != http.StatusOK || len(sessions) != 1 || !sessions[0].Current {
+			t.Fatalf("the current session should be returned, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("DELETE /sessions/:sessionId requires a nonce", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(activeSession())
+		as := makeTestServer(tdbc)
+
+		req, _ := http.NewRequest("DELETE", "/sessions/abc", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("status code should be 400, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("DELETE /sessions/:sessionId revokes the caller's session", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		tdbc.SetSessionDoc(activeSession())
+		as := makeTestServer(tdbc)
+
+		req, _ := http.NewRequest("DELETE", "/sessions/abc?nonce=YWJj", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("status code should be 204, got %d", recorder.Code)
+		}
+	})
+}
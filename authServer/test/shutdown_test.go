@@ -0,0 +1,153 @@
+package authServerTest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+// closingLogger records whether it was closed
+type closingLogger struct{ closed bool }
+
+func (cl *closingLogger) AddRequestLog(log *authUtils.RequestLogData) error { return nil }
+func (cl *closingLogger) AddInfoLog(log *authUtils.InfoLogData) error       { return nil }
+func (cl *closingLogger) Close() error {
+	cl.closed = true
+	return nil
+}
+
+// serveSlowRoute serves a server whose GET /slow route responds once release is
+// closed. started receives a value once a request reaches the route.
+func serveSlowRoute(t *testing.T) (*authServer.AuthServer, string, <-chan error, chan struct{}, chan struct{}) {
+	gin.SetMode(gin.TestMode)
+
+	var passedController dbController.DatabaseController = mocks.MakeBlankTestDbController()
+	as := &authServer.AuthServer{
+		AuthController: authServer.InitController(&passedController),
+		GinEngine:      gin.New(),
+	}
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	as.GinEngine.GET("/slow", func(ctx *gin.Context) {
+		started <- struct{}{}
+		<-release
+		ctx.String(http.StatusOK, "done")
+	})
+
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatalf("err should be nil: " + listenErr.Error())
+	}
+
+	return as, "http://" + listener.Addr().String() + "/slow", as.Serve(listener), started, release
+}
+
+func Test_Shutdown(t *testing.T) {
+	t.Run("Shutdown waits for in-flight requests to finish", func(t *testing.T) {
+		as, url, served, started, release := serveSlowRoute(t)
+
+		responses := make(chan int, 1)
+		go func() {
+			res, resErr := http.Get(url)
+			if resErr != nil {
+				responses <- 0
+				return
+			}
+			res.Body.Close()
+			responses <- res.StatusCode
+		}()
+
+		<-started
+
+		shutdown := make(chan error, 1)
+		go func() { shutdown <- as.Shutdown(context.Background()) }()
+
+		select {
+		case <-shutdown:
+			t.Fatalf("Shutdown should wait for the request")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+
+		if err := <-shutdown; err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		if status := <-responses; status != http.StatusOK {
+			t.Fatalf("the request should finish, got status %d", status)
+		}
+		if err := <-served; err != nil {
+			t.Fatalf("Serve should return nil after a shutdown: " + err.Error())
+		}
+	})
+
+	t.Run("Shutdown returns an error if requests outlast the timeout", func(t *testing.T) {
+		as, url, _, started, release := serveSlowRoute(t)
+		defer close(release)
+
+		go http.Get(url)
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		if err := as.Shutdown(ctx); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+
+	t.Run("Shutdown closes the loggers", func(t *testing.T) {
+		as, _, _, _, _ := serveSlowRoute(t)
+
+		logger := &closingLogger{}
+		var authLogger authUtils.AuthLogger = logger
+		as.AuthController.AddLogger(&authLogger)
+
+		if err := as.Shutdown(context.Background()); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+		if !logger.closed {
+			t.Fatalf("the logger should be closed")
+		}
+	})
+}
+
+func Test_GetShutdownTimeout(t *testing.T) {
+	t.Run("GetShutdownTimeout defaults to 30 seconds", func(t *testing.T) {
+		t.Setenv(constants.SHUTDOWN_TIMEOUT, "")
+
+		if timeout, err := authServer.GetShutdownTimeout(); err != nil || timeout != constants.DEFAULT_SHUTDOWN_TIMEOUT {
+			t.Fatalf("expected the default timeout, got %v %v", timeout, err)
+		}
+	})
+
+	t.Run("GetShutdownTimeout reads SHUTDOWN_TIMEOUT", func(t *testing.T) {
+		t.Setenv(constants.SHUTDOWN_TIMEOUT, "10s")
+
+		if timeout, err := authServer.GetShutdownTimeout(); err != nil || timeout != 10*time.Second {
+			t.Fatalf("expected 10s, got %v %v", timeout, err)
+		}
+	})
+
+	t.Run("GetShutdownTimeout returns an error for invalid durations", func(t *testing.T) {
+		for _, value := range []string{"soon", "-5s", "0s"} {
+			t.Setenv(constants.SHUTDOWN_TIMEOUT, value)
+
+			if _, err := authServer.GetShutdownTimeout(); err == nil {
+				t.Fatalf("%s should be invalid", value)
+			}
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package authServerTest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+	"methompson.com/auth-microservice/authServer/authPb"
+	"methompson.com/auth-microservice/authServer/authTLS"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+// serveTLS serves the routes over TLS with optional client authentication.
+// Admin routes require a client certificate. It returns the server's URL.
+func serveTLS(t *testing.T, certs *mocks.TestCertificates) string {
+	t.Setenv(constants.TLS_CERT_FILE, certs.ServerCertFile)
+	t.Setenv(constants.TLS_KEY_FILE, certs.ServerKeyFile)
+	t.Setenv(constants.TLS_CLIENT_CA_FILE, certs.CAFile)
+	t.Setenv(constants.TLS_ADMIN_CLIENT_CERT, "true")
+
+	options, optionsErr := authTLS.GetTLSOptions()
+	if optionsErr != nil {
+		t.Fatalf("err should be nil: " + optionsErr.Error())
+	}
+
+	reloader, reloaderErr := authTLS.NewCertificateReloader(options)
+	if reloaderErr != nil {
+		t.Fatalf("err should be nil: " + reloaderErr.Error())
+	}
+
+	as := makeTestServer(mocks.MakeBlankTestDbController())
+	as.TLSConfig = reloader.TLSConfig()
+
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	if listenErr != nil {
+		t.Fatalf("err should be nil: " + listenErr.Error())
+	}
+
+	as.Serve(listener)
+	t.Cleanup(func() { as.Shutdown(context.Background()) })
+
+	return "https://" + listener.Addr().String()
+}
+
+func tlsClient(certs *mocks.TestCertificates, clientCerts ...tls.Certificate) *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: certs.CAPool, Certificates: clientCerts},
+		},
+	}
+}
+
+func Test_TLS(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	certs, certsErr := mocks.MakeTestCertificates(t.TempDir())
+	if certsErr != nil {
+		t.Fatalf("err should be nil: " + certsErr.Error())
+	}
+
+	adminToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "admin", Admin: true})
+
+	getAudit := func(client *http.Client, url string) (*http.Response, apiErrors.Problem) {
+		req, _ := http.NewRequest(http.MethodGet, url+"/audit", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+
+		res, resErr := client.Do(req)
+		if resErr != nil {
+			t.Fatalf("err should be nil: " + resErr.Error())
+		}
+		defer res.Body.Close()
+
+		var problem apiErrors.Problem
+		json.NewDecoder(res.Body).Decode(&problem)
+
+		return res, problem
+	}
+
+	t.Run("Requests are served over TLS without a client certificate", func(t *testing.T) {
+		url := serveTLS(t, certs)
+
+		res, resErr := tlsClient(certs).Get(url + "/healthz")
+		if resErr != nil {
+			t.Fatalf("err should be nil: " + resErr.Error())
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK || res.TLS == nil {
+			t.Fatalf("the request should succeed over TLS, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Admin routes reject admin tokens without a client certificate", func(t *testing.T) {
+		url := serveTLS(t, certs)
+
+		res, problem := getAudit(tlsClient(certs), url)
+
+		if res.StatusCode != http.StatusForbidden || problem.Code != apiErrors.ClientCertRequired {
+			t.Fatalf("expected a client_certificate_required problem, got %d %s", res.StatusCode, problem.Code)
+		}
+	})
+
+	t.Run("Admin routes accept admin tokens with a client certificate", func(t *testing.T) {
+		url := serveTLS(t, certs)
+
+		res, _ := getAudit(tlsClient(certs, certs.Client), url)
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("status code should be 200, got %d", res.StatusCode)
+		}
+	})
+
+	patchUser := func(client *http.Client, url string, id string) (*http.Response, apiErrors.Problem) {
+		req, _ := http.NewRequest(http.MethodPatch, url+"/v1/users/"+id, strings.NewReader(`{"nonce": "YWJj", "username": "user"}`))
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		res, resErr := client.Do(req)
+		if resErr != nil {
+			t.Fatalf("err should be nil: " + resErr.Error())
+		}
+		defer res.Body.Close()
+
+		var problem apiErrors.Problem
+		json.NewDecoder(res.Body).Decode(&problem)
+
+		return res, problem
+	}
+
+	t.Run("Admins editing another user need a client certificate", func(t *testing.T) {
+		url := serveTLS(t, certs)
+
+		res, problem := patchUser(tlsClient(certs), url, "456")
+		if res.StatusCode != http.StatusForbidden || problem.Code != apiErrors.ClientCertRequired {
+			t.Fatalf("expected a client_certificate_required problem, got %d %s", res.StatusCode, problem.Code)
+		}
+
+		if res, _ := patchUser(tlsClient(certs, certs.Client), url, "456"); res.StatusCode != http.StatusOK {
+			t.Fatalf("status code should be 200, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Admins editing their own account don't need a client certificate", func(t *testing.T) {
+		url := serveTLS(t, certs)
+
+		if res, _ := patchUser(tlsClient(certs), url, "123"); res.StatusCode != http.StatusOK {
+			t.Fatalf("status code should be 200, got %d", res.StatusCode)
+		}
+	})
+}
+
+func Test_ClientCertInterceptor(t *testing.T) {
+	interceptor := authServer.ClientCertInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "handled", nil }
+
+	t.Run("Admin methods are rejected without a client certificate", func(t *testing.T) {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/auth.v1.AuthService/AddUser"}, handler)
+
+		if status.Code(err) != codes.PermissionDenied || errorCode(err) != apiErrors.ClientCertRequired {
+			t.Fatalf("expected a client_certificate_required error, got %v", err)
+		}
+	})
+
+	t.Run("Other methods don't require a client certificate", func(t *testing.T) {
+		for _, method := range []string{"/auth.v1.AuthService/EditUser", "/auth.v1.AuthService/Login"} {
+			res, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+
+			if err != nil || res != "handled" {
+				t.Fatalf("%s should be handled, got %v", method, err)
+			}
+		}
+	})
+
+	// editUser calls EditUser with an admin token, running TokenInterceptor
+	// before the interceptor
+	editUser := func(id string) (interface{}, error) {
+		resetEnvVariables()
+		mocks.PrepTestRSAKeys()
+		adminToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "admin", Admin: true})
+
+		tokenInterceptor := authServer.TokenInterceptor(authMiddleware.ValidatorFunc(authCrypto.ValidateJWT))
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+adminToken))
+		info := &grpc.UnaryServerInfo{FullMethod: "/auth.v1.AuthService/EditUser"}
+
+		return tokenInterceptor(ctx, &authPb.EditUserRequest{Id: id}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, info, handler)
+		})
+	}
+
+	t.Run("Admins editing another user are rejected without a client certificate", func(t *testing.T) {
+		_, err := editUser("456")
+
+		if status.Code(err) != codes.PermissionDenied || errorCode(err) != apiErrors.ClientCertRequired {
+			t.Fatalf("expected a client_certificate_required error, got %v", err)
+		}
+	})
+
+	t.Run("Admins editing their own account don't need a client certificate", func(t *testing.T) {
+		if res, err := editUser("123"); err != nil || res != "handled" {
+			t.Fatalf("the request should be handled, got %v", err)
+		}
+	})
+}
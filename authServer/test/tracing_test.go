@@ -0,0 +1,100 @@
+package authServerTest
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_AuthControllerTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	t.Run("LogUserIn traces its database calls and password check as part of the request", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = dbController.NewInstrumentedController(tdbc, authTracing.StartDBOperation)
+		ac := authServer.InitController(&passedController)
+
+		ctx := mocks.MakeTestContext()
+		requestCtx, requestSpan := authTracing.Tracer().Start(context.Background(), "POST /login")
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+
+		// The mock user has no password hash, so the password doesn't match
+		ac.LogUserIn(authServer.LoginBody{Username: "test", Password: "test", Nonce: "MQ=="}, ctx)
+		requestSpan.End()
+
+		spans := make(map[string]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+
+		parents := map[string]string{
+			"AuthController.LogUserIn":             "POST /login",
+			"AuthController.CheckNonceValidity":    "AuthController.LogUserIn",
+			"DatabaseController.GetNonce":          "AuthController.CheckNonceValidity",
+			"DatabaseController.GetUserByUsername": "AuthController.LogUserIn",
+			"bcrypt.compare":                       "AuthController.LogUserIn",
+			"DatabaseController.AddAuditEvent":     "AuthController.LogUserIn",
+		}
+
+		for name, parentName := range parents {
+			span, ok := spans[name]
+			if !ok {
+				t.Fatalf("expected a %s span", name)
+			}
+
+			if span.Parent().SpanID() != spans[parentName].SpanContext().SpanID() {
+				t.Fatalf("expected %s to be a child of %s", name, parentName)
+			}
+		}
+
+		if spans["AuthController.LogUserIn"].Status().Description != "Password does not match" {
+			t.Fatalf("expected the login span to record the login error")
+		}
+	})
+
+	t.Run("GetUser traces its database call as part of the request", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = dbController.NewInstrumentedController(tdbc, authTracing.StartDBOperation)
+		ac := authServer.InitController(&passedController)
+
+		ctx := mocks.MakeTestContext()
+		requestCtx, requestSpan := authTracing.Tracer().Start(context.Background(), "GET /v1/users/:id")
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+
+		ac.GetUser("123", &authCrypto.JWTClaims{Admin: true}, ctx)
+		requestSpan.End()
+
+		spans := make(map[string]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+
+		getUser, dbCall := spans["AuthController.GetUser"], spans["DatabaseController.GetUserById"]
+		if getUser == nil || dbCall == nil || dbCall.Parent().SpanID() != getUser.SpanContext().SpanID() {
+			t.Fatalf("expected the database call to be a child of the GetUser span")
+		}
+	})
+
+	t.Run("Database calls outside of a trace aren't traced", func(t *testing.T) {
+		tdbc := mocks.MakeBlankTestDbController()
+		var passedController dbController.DatabaseController = dbController.NewInstrumentedController(tdbc, authTracing.StartDBOperation)
+
+		before := len(recorder.Ended())
+
+		passedController.GetUserById("123")
+
+		if len(recorder.Ended()) != before {
+			t.Fatalf("expected no spans, got %d", len(recorder.Ended())-before)
+		}
+	})
+}
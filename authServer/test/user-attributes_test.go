@@ -0,0 +1,89 @@
+package authServerTest
+
+import (
+	"strings"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+func Test_ValidateUserAttributes(t *testing.T) {
+	t.Run("ValidateUserAttributes accepts valid attributes", func(t *testing.T) {
+		err := authServer.ValidateUserAttributes(map[string]interface{}{
+			"displayName": "Alice Example",
+			"locale":      "en-US",
+			"metadata": map[string]interface{}{
+				"myApp": map[string]interface{}{"theme": "dark"},
+			},
+		}, false)
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("ValidateUserAttributes accepts a nil map", func(t *testing.T) {
+		err := authServer.ValidateUserAttributes(nil, false)
+
+		if err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+	})
+
+	t.Run("ValidateUserAttributes rejects unknown attributes", func(t *testing.T) {
+		err := authServer.ValidateUserAttributes(map[string]interface{}{
+			"favoriteColor": "blue",
+		}, false)
+
+		if _, ok := err.(dbController.InvalidInputError); !ok {
+			t.Fatalf("err should be an InvalidInputError")
+		}
+	})
+
+	t.Run("ValidateUserAttributes rejects values with the wrong type", func(t *testing.T) {
+		err := authServer.ValidateUserAttributes(map[string]interface{}{
+			"displayName": 1,
+		}, false)
+
+		if _, ok := err.(dbController.InvalidInputError); !ok {
+			t.Fatalf("err should be an InvalidInputError")
+		}
+	})
+
+	t.Run("ValidateUserAttributes rejects invalid locales", func(t *testing.T) {
+		err := authServer.ValidateUserAttributes(map[string]interface{}{
+			"locale": "not a locale",
+		}, false)
+
+		if _, ok := err.(dbController.InvalidInputError); !ok {
+			t.Fatalf("err should be an InvalidInputError")
+		}
+	})
+
+	t.Run("ValidateUserAttributes rejects metadata that is too large", func(t *testing.T) {
+		err := authServer.ValidateUserAttributes(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"myApp": strings.Repeat("a", 5000),
+			},
+		}, false)
+
+		if _, ok := err.(dbController.InvalidInputError); !ok {
+			t.Fatalf("err should be an InvalidInputError")
+		}
+	})
+
+	t.Run("ValidateUserAttributes only accepts nil values if allowNil is true", func(t *testing.T) {
+		attributes := map[string]interface{}{
+			"displayName": nil,
+		}
+
+		if err := authServer.ValidateUserAttributes(attributes, true); err != nil {
+			t.Fatalf("err should be nil: " + err.Error())
+		}
+
+		if err := authServer.ValidateUserAttributes(attributes, false); err == nil {
+			t.Fatalf("err should not be nil")
+		}
+	})
+}
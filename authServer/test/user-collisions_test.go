@@ -0,0 +1,61 @@
+package authServerTest
+
+import (
+	"testing"
+
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+func Test_FindUserCollisions(t *testing.T) {
+	t.Run("FindUserCollisions returns an empty slice if no users collide", func(t *testing.T) {
+		users := []dbController.UserDocument{
+			{Id: "1", Username: "alice", Email: "alice@example.com"},
+			{Id: "2", Username: "bob", Email: "bob@example.com"},
+		}
+
+		collisions := dbController.FindUserCollisions(users)
+
+		if len(collisions) != 0 {
+			t.Fatalf("there should be no collisions")
+		}
+	})
+
+	t.Run("FindUserCollisions groups users whose normalized usernames match", func(t *testing.T) {
+		users := []dbController.UserDocument{
+			{Id: "1", Username: "Alice", Email: "alice1@example.com"},
+			{Id: "2", Username: "alice ", Email: "alice2@example.com"},
+			{Id: "3", Username: "bob", Email: "bob@example.com"},
+		}
+
+		collisions := dbController.FindUserCollisions(users)
+
+		if len(collisions) != 1 {
+			t.Fatalf("there should be exactly one collision")
+		}
+
+		if collisions[0].Field != "username" || collisions[0].NormalizedValue != "alice" {
+			t.Fatalf("collision should be for the username 'alice'")
+		}
+
+		if len(collisions[0].Users) != 2 {
+			t.Fatalf("collision should contain two users")
+		}
+	})
+
+	t.Run("FindUserCollisions groups users whose normalized emails match", func(t *testing.T) {
+		users := []dbController.UserDocument{
+			{Id: "1", Username: "alice", Email: "Alice@Example.com"},
+			{Id: "2", Username: "alicia", Email: "alice@example.com"},
+		}
+
+		collisions := dbController.FindUserCollisions(users)
+
+		if len(collisions) != 1 {
+			t.Fatalf("there should be exactly one collision")
+		}
+
+		if collisions[0].Field != "email" {
+			t.Fatalf("collision should be for the email field")
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package authServerTest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/apiErrors"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/dbController"
+	mocks "methompson.com/auth-microservice/authServer/test/authServerMocks"
+)
+
+func Test_V1Routes(t *testing.T) {
+	resetEnvVariables()
+	mocks.PrepTestRSAKeys()
+
+	tdbc := mocks.MakeBlankTestDbController()
+	tdbc.SetUserDoc(dbController.FullUserDocument{
+		Id:           "123",
+		Username:     "user",
+		Email:        "user@example.com",
+		Enabled:      true,
+		PasswordHash: "hash",
+	})
+	as := makeTestServer(tdbc)
+
+	userToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "123", Username: "user"})
+	adminToken, _ := authCrypto.GenerateJWT(dbController.UserDocument{Id: "456", Username: "admin", Admin: true})
+
+	request := func(method string, path string, token string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		as.GinEngine.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	t.Run("GET /v1/users/:id returns the user without the password hash", func(t *testing.T) {
+		recorder := request("GET", "/v1/users/123", userToken, "")
+
+		var user authServer.UserResponse
+		json.Unmarshal(recorder.Body.Bytes(), &user)
+
+		if recorder.Code != http.StatusOK || user.Id != "123" || user.Email != "user@example.com" {
+			t.Fatalf("the user should be returned")
+		}
+
+		if bytes.Contains(recorder.Body.Bytes(), []byte("hash")) {
+			t.Fatalf("the password hash should not be returned")
+		}
+	})
+
+	t.Run("GET /v1/users/:id is forbidden for other users", func(t *testing.T) {
+		recorder := request("GET", "/v1/users/789", userToken, "")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403")
+		}
+	})
+
+	t.Run("PATCH /v1/users/:id takes the id from the path and returns the user", func(t *testing.T) {
+		recorder := request("PATCH", "/v1/users/123", userToken, `{"nonce": "YWJj", "username": "user"}`)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status code should be 200, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("PATCH /v1/users/:id is forbidden for users changing their own access", func(t *testing.T) {
+		for _, body := range []string{`{"nonce": "YWJj", "admin": true}`, `{"nonce": "YWJj", "enabled": true}`} {
+			recorder := request("PATCH", "/v1/users/123", userToken, body)
+
+			var problem apiErrors.Problem
+			json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+			if recorder.Code != http.StatusForbidden || problem.Code != apiErrors.Forbidden {
+				t.Fatalf("%s should be forbidden, got %d", body, recorder.Code)
+			}
+		}
+	})
+
+	t.Run("PATCH /v1/users/:id lets admins change a user's access", func(t *testing.T) {
+		recorder := request("PATCH", "/v1/users/123", adminToken, `{"nonce": "YWJj", "admin": true}`)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status code should be 200, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("PUT /v1/users/:id/password takes the id from the path", func(t *testing.T) {
+		recorder := request("PUT", "/v1/users/789/password", userToken, `{"nonce": "YWJj", "newPassword": "password"}`)
+
+		var problem apiErrors.Problem
+		json.Unmarshal(recorder.Body.Bytes(), &problem)
+
+		if recorder.Code != http.StatusForbidden || problem.Code != apiErrors.Forbidden {
+			t.Fatalf("changing another user's password should be forbidden")
+		}
+	})
+
+	t.Run("DELETE /v1/users/:id requires an admin", func(t *testing.T) {
+		recorder := request("DELETE", "/v1/users/789?nonce=YWJj", userToken, "")
+
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("status code should be 403")
+		}
+	})
+
+	t.Run("DELETE /v1/users/:id requires a nonce", func(t *testing.T) {
+		recorder := request("DELETE", "/v1/users/123", adminToken, "")
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("status code should be 400")
+		}
+	})
+
+	t.Run("DELETE /v1/users/:id responds with 204", func(t *testing.T) {
+		recorder := request("DELETE", "/v1/users/123?nonce=YWJj", adminToken, "")
+
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("status code should be 204, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("The legacy routes share the v1 implementation", func(t *testing.T) {
+		recorder := request("POST", "/edit-user", userToken, `{"id": "789", "nonce": "YWJj"}`)
+
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("editing another user should be forbidden")
+		}
+	})
+}
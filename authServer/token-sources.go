@@ -0,0 +1,42 @@
+package authServer
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"methompson.com/auth-microservice/authServer/authMiddleware"
+	"methompson.com/auth-microservice/authServer/constants"
+)
+
+// GetAuthenticatorOptions builds the authentication middleware's options from
+// the TOKEN_SOURCES and TOKEN_COOKIE_* environment variables. By default tokens
+// are only read from the Authorization header, and token cookies are Secure and
+// use SameSite=Strict.
+func GetAuthenticatorOptions() (authMiddleware.Options, error) {
+	options := authMiddleware.DefaultOptions()
+
+	sources, sourcesErr := authMiddleware.ParseTokenSources(os.Getenv(constants.TOKEN_SOURCES))
+	if sourcesErr != nil {
+		return options, NewEnvironmentVariableError("TOKEN_SOURCES is invalid: " + sourcesErr.Error())
+	}
+	options.Sources = sources
+
+	if cookieName := os.Getenv(constants.TOKEN_COOKIE_NAME); len(cookieName) > 0 {
+		options.CookieName = cookieName
+	}
+
+	// Cookies should only be sent over plain HTTP during local development
+	options.CookieSecure = os.Getenv(constants.TOKEN_COOKIE_SECURE) != "false"
+
+	switch strings.ToLower(os.Getenv(constants.TOKEN_COOKIE_SAMESITE)) {
+	case "", "strict":
+		options.CookieSameSite = http.SameSiteStrictMode
+	case "lax":
+		options.CookieSameSite = http.SameSiteLaxMode
+	default:
+		return options, NewEnvironmentVariableError("TOKEN_COOKIE_SAMESITE must be strict or lax")
+	}
+
+	return options, nil
+}
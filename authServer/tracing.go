@@ -0,0 +1,79 @@
+package authServer
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+
+	"methompson.com/auth-microservice/authServer/authTracing"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+// tracedRequestContext is the RequestContext passed on by an AuthController
+// operation that started a span, so that the operations it calls are traced
+// as part of its span
+type tracedRequestContext struct {
+	RequestContext
+	ctx context.Context
+}
+
+// traceContext returns the context holding a request's span. The Tracing
+// middleware stores the span in the context of Gin's request.
+func traceContext(ctx RequestContext) context.Context {
+	switch rc := ctx.(type) {
+	case tracedRequestContext:
+		return rc.ctx
+	case *gin.Context:
+		if rc.Request != nil {
+			return rc.Request.Context()
+		}
+	case grpcRequestContext:
+		if rc.ctx != nil {
+			return rc.ctx
+		}
+	}
+
+	return context.Background()
+}
+
+// startSpan starts the span of an AuthController operation. The RequestContext
+// returned should be passed to everything the operation calls.
+func (ac *AuthController) startSpan(ctx RequestContext, operation string) (RequestContext, trace.Span) {
+	spanCtx, span := authTracing.Tracer().Start(traceContext(ctx), "AuthController."+operation)
+
+	return tracedRequestContext{RequestContext: ctx, ctx: spanCtx}, span
+}
+
+// db returns the DatabaseController to use while handling a request, so that
+// its operations are traced as part of the request
+func (ac *AuthController) db(ctx RequestContext) dbController.DatabaseController {
+	return ac.dbWithContext(traceContext(ctx))
+}
+
+func (ac *AuthController) dbWithContext(ctx context.Context) dbController.DatabaseController {
+	if instrumented, ok := (*ac.DBController).(*dbController.InstrumentedController); ok {
+		return instrumented.WithContext(ctx)
+	}
+
+	return *ac.DBController
+}
+
+// hashPassword hashes a password with bcrypt in its own span
+func hashPassword(ctx RequestContext, password string) (string, error) {
+	_, span := authTracing.Tracer().Start(traceContext(ctx), "bcrypt.hash")
+
+	hash, hashErr := authUtils.HashPassword(password)
+	authTracing.EndSpan(span, hashErr)
+
+	return hash, hashErr
+}
+
+// checkPasswordHash compares a password to a bcrypt hash in its own span
+func checkPasswordHash(ctx RequestContext, password string, hash string) bool {
+	_, span := authTracing.Tracer().Start(traceContext(ctx), "bcrypt.compare")
+	defer span.End()
+
+	return authUtils.CheckPasswordHash(password, hash)
+}
@@ -2,36 +2,47 @@ package authServer
 
 import (
 	"os"
+	"time"
 
+	"methompson.com/auth-microservice/authServer/authCrypto"
 	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
 )
 
 func DebugMode() bool {
 	return os.Getenv(constants.GIN_MODE) != "release"
 }
 
+// Audience and Scopes are optional. Without an audience, the token is issued for
+// the default audience.
 type LoginBody struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Nonce    string `json:"nonce" binding:"required"`
+	Username string   `json:"username" binding:"required"`
+	Password string   `json:"password" binding:"required"`
+	Nonce    string   `json:"nonce" binding:"required"`
+	Audience string   `json:"audience"`
+	Scopes   []string `json:"scopes"`
 }
 
 type AddUserBody struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Enabled  bool   `json:"enabled"`
-	Admin    bool   `json:"admin"`
-	Nonce    string `json:"nonce" binding:"required"`
+	Username   string                 `json:"username" binding:"required"`
+	Email      string                 `json:"email" binding:"required"`
+	Password   string                 `json:"password" binding:"required"`
+	Enabled    bool                   `json:"enabled"`
+	Admin      bool                   `json:"admin"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Nonce      string                 `json:"nonce" binding:"required"`
 }
 
+// Only the attributes passed in Attributes are changed. Setting an attribute
+// to null removes it from the user.
 type EditUserBody struct {
-	Id       string  `json:"id" binding:"required"`
-	Username *string `json:"username"`
-	Email    *string `json:"email"`
-	Enabled  *bool   `json:"enabled"`
-	Admin    *bool   `json:"admin"`
-	Nonce    string  `json:"nonce" binding:"required"`
+	Id         string                 `json:"id" binding:"required"`
+	Username   *string                `json:"username"`
+	Email      *string                `json:"email"`
+	Enabled    *bool                  `json:"enabled"`
+	Admin      *bool                  `json:"admin"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Nonce      string                 `json:"nonce" binding:"required"`
 }
 
 type EditPasswordBody struct {
@@ -41,6 +52,227 @@ type EditPasswordBody struct {
 	Nonce       string `json:"nonce" binding:"required"`
 }
 
-type AuthorizationHeader struct {
-	Token string `header:"authorization" binding:"required"`
+// PatchUserBody is the body of PATCH /v1/users/:id. It's the same as
+// EditUserBody, except that the user's id is taken from the path.
+type PatchUserBody struct {
+	Username   *string                `json:"username"`
+	Email      *string                `json:"email"`
+	Enabled    *bool                  `json:"enabled"`
+	Admin      *bool                  `json:"admin"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Nonce      string                 `json:"nonce" binding:"required"`
+}
+
+func (body PatchUserBody) EditUserBody(id string) EditUserBody {
+	return EditUserBody{
+		Id:         id,
+		Username:   body.Username,
+		Email:      body.Email,
+		Enabled:    body.Enabled,
+		Admin:      body.Admin,
+		Attributes: body.Attributes,
+		Nonce:      body.Nonce,
+	}
+}
+
+// SetPasswordBody is the body of PUT /v1/users/:id/password. The user's id is
+// taken from the path.
+type SetPasswordBody struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword" binding:"required"`
+	Nonce       string `json:"nonce" binding:"required"`
+}
+
+func (body SetPasswordBody) EditPasswordBody(id string) EditPasswordBody {
+	return EditPasswordBody{
+		Id:          id,
+		OldPassword: body.OldPassword,
+		NewPassword: body.NewPassword,
+		Nonce:       body.Nonce,
+	}
+}
+
+// PatchMeBody is the body of PATCH /me. Users edit their own profile, so there's
+// no id. Enabled and Admin can't be changed this way.
+type PatchMeBody struct {
+	Username   *string                `json:"username"`
+	Email      *string                `json:"email"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Nonce      string                 `json:"nonce" binding:"required"`
+}
+
+func (body PatchMeBody) EditUserBody(id string) EditUserBody {
+	return EditUserBody{
+		Id:         id,
+		Username:   body.Username,
+		Email:      body.Email,
+		Attributes: body.Attributes,
+		Nonce:      body.Nonce,
+	}
+}
+
+// DELETE requests don't have a body, so the nonce is sent in the query string
+type NonceQuery struct {
+	Nonce string `form:"nonce" binding:"required"`
+}
+
+// UserResponse is the public representation of a user. It never includes the
+// password hash.
+type UserResponse struct {
+	Id         string                 `json:"id"`
+	Username   string                 `json:"username"`
+	Email      string                 `json:"email"`
+	Enabled    bool                   `json:"enabled"`
+	Admin      bool                   `json:"admin"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func NewUserResponse(userDoc dbController.UserDocument) UserResponse {
+	return UserResponse{
+		Id:         userDoc.Id,
+		Username:   userDoc.Username,
+		Email:      userDoc.Email,
+		Enabled:    userDoc.Enabled,
+		Admin:      userDoc.Admin,
+		Attributes: userDoc.Attributes,
+	}
+}
+
+// SessionResponse describes a login session. Times are Unix timestamps in
+// seconds. Current is true for the session of the token used to make the
+// request.
+type SessionResponse struct {
+	Id         string `json:"id"`
+	UserAgent  string `json:"userAgent"`
+	IPAddress  string `json:"ipAddress"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastSeenAt int64  `json:"lastSeenAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	Current    bool   `json:"current"`
+}
+
+func NewSessionResponse(session dbController.SessionDocument, claims *authCrypto.JWTClaims) SessionResponse {
+	return SessionResponse{
+		Id:         session.Id,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+		Current:    session.Id == claims.SessionId,
+	}
+}
+
+// TokenMetadata describes the token used to make a request. Times are Unix
+// timestamps in seconds.
+type TokenMetadata struct {
+	IssuedAt  int64    `json:"issuedAt"`
+	NotBefore int64    `json:"notBefore,omitempty"`
+	ExpiresAt int64    `json:"expiresAt"`
+	Issuer    string   `json:"issuer,omitempty"`
+	Audience  string   `json:"audience,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	// Actor is set if the token was issued to an admin impersonating the user
+	Actor *authCrypto.ActorClaim `json:"actor,omitempty"`
+}
+
+func NewTokenMetadata(claims *authCrypto.JWTClaims) TokenMetadata {
+	return TokenMetadata{
+		IssuedAt:  claims.IssuedAt,
+		NotBefore: claims.NotBefore,
+		ExpiresAt: claims.ExpiresAt,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		Scopes:    claims.Scopes(),
+		Actor:     claims.Actor,
+	}
+}
+
+// MeResponse is the response of GET /me. User is read from the database, so it
+// reflects changes made after the token was issued.
+type MeResponse struct {
+	User  UserResponse  `json:"user"`
+	Token TokenMetadata `json:"token"`
+}
+
+// ImpersonateBody is the body of POST /users/:id/impersonate
+type ImpersonateBody struct {
+	Nonce string `json:"nonce" binding:"required"`
+}
+
+// AuditQuery holds the filters of GET /audit. Since and Until are RFC 3339
+// times.
+type AuditQuery struct {
+	Actor   string    `form:"actor"`
+	Target  string    `form:"target"`
+	Action  string    `form:"action"`
+	Outcome string    `form:"outcome"`
+	Since   time.Time `form:"since"`
+	Until   time.Time `form:"until"`
+	Limit   int64     `form:"limit"`
+}
+
+func (aq AuditQuery) Filter() dbController.AuditEventFilter {
+	return dbController.AuditEventFilter{
+		ActorId:  aq.Actor,
+		TargetId: aq.Target,
+		Action:   aq.Action,
+		Outcome:  aq.Outcome,
+		Since:    aq.Since,
+		Until:    aq.Until,
+		Limit:    aq.Limit,
+	}
+}
+
+type LogsQuery struct {
+	Type     string    `form:"type"`
+	Since    time.Time `form:"since"`
+	Until    time.Time `form:"until"`
+	Status   int       `form:"status"`
+	Path     string    `form:"path"`
+	ClientIP string    `form:"ip"`
+	Offset   int64     `form:"offset"`
+	Limit    int64     `form:"limit"`
+}
+
+func (lq LogsQuery) Filter() dbController.LogFilter {
+	return dbController.LogFilter{
+		Type:       lq.Type,
+		Since:      lq.Since,
+		Until:      lq.Until,
+		StatusCode: lq.Status,
+		Path:       lq.Path,
+		ClientIP:   lq.ClientIP,
+		Offset:     lq.Offset,
+		Limit:      lq.Limit,
+	}
+}
+
+// LogsResponse is a page of logs. The next page starts at Offset + Limit.
+type LogsResponse struct {
+	Logs    []dbController.LogDocument `json:"logs"`
+	Offset  int64                      `json:"offset"`
+	Limit   int64                      `json:"limit"`
+	HasMore bool                       `json:"hasMore"`
+}
+
+// IntrospectBody is usually sent as a form, as described in RFC 7662, but JSON
+// is accepted as well.
+type IntrospectBody struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+}
+
+// HealthCheck is the result of one of the checks run by GET /readyz
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthResponse is the response of GET /healthz and GET /readyz. Only /readyz
+// runs checks.
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
 }
@@ -0,0 +1,95 @@
+package authServer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/text/language"
+
+	"methompson.com/auth-microservice/authServer/dbController"
+)
+
+const maxDisplayNameLength = 100
+const maxMetadataSize = 4096
+
+// userAttributeValidators maps every attribute a user may have to a function that
+// validates its value. Attributes not listed here are rejected. The list mirrors
+// the attributes object in the users collection's schema.
+var userAttributeValidators = map[string]func(interface{}) error{
+	"displayName": validateDisplayName,
+	"locale":      validateLocale,
+	"metadata":    validateMetadata,
+}
+
+// ValidateUserAttributes checks a map of user attributes against the user
+// attribute schema. When allowNil is true, nil values are accepted. EditUser
+// uses nil values to remove attributes from a user. The function returns an
+// InvalidInputError describing the first problem it finds.
+func ValidateUserAttributes(attributes map[string]interface{}, allowNil bool) error {
+	for key, value := range attributes {
+		validator, ok := userAttributeValidators[key]
+		if !ok {
+			return dbController.NewInvalidInputError(fmt.Sprintf("Unknown attribute '%s'", key))
+		}
+
+		if value == nil {
+			if allowNil {
+				continue
+			}
+
+			return dbController.NewInvalidInputError(fmt.Sprintf("Attribute '%s' cannot be null", key))
+		}
+
+		if validationErr := validator(value); validationErr != nil {
+			return validationErr
+		}
+	}
+
+	return nil
+}
+
+func validateDisplayName(value interface{}) error {
+	displayName, ok := value.(string)
+	if !ok {
+		return dbController.NewInvalidInputError("displayName must be a string")
+	}
+
+	if len([]rune(displayName)) > maxDisplayNameLength {
+		return dbController.NewInvalidInputError(fmt.Sprintf("displayName cannot be longer than %d characters", maxDisplayNameLength))
+	}
+
+	return nil
+}
+
+func validateLocale(value interface{}) error {
+	locale, ok := value.(string)
+	if !ok {
+		return dbController.NewInvalidInputError("locale must be a string")
+	}
+
+	if _, parseErr := language.Parse(locale); parseErr != nil {
+		return dbController.NewInvalidInputError("locale must be a valid BCP 47 language tag")
+	}
+
+	return nil
+}
+
+// Metadata can hold any JSON object, e.g. a value per application. We only limit
+// its size so that it can't grow the user document, or a JWT, without bound.
+func validateMetadata(value interface{}) error {
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return dbController.NewInvalidInputError("metadata must be an object")
+	}
+
+	metadataBytes, marshalErr := json.Marshal(metadata)
+	if marshalErr != nil {
+		return dbController.NewInvalidInputError("metadata must be valid JSON")
+	}
+
+	if len(metadataBytes) > maxMetadataSize {
+		return dbController.NewInvalidInputError(fmt.Sprintf("metadata cannot be larger than %d bytes", maxMetadataSize))
+	}
+
+	return nil
+}
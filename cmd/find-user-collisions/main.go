@@ -0,0 +1,53 @@
+// find-user-collisions is a one-off migration command that reports users whose
+// usernames or emails collide once they are normalized. Those users need to be
+// resolved by hand before the case insensitive user indexes can be built on an
+// existing database. The service builds them on startup once there are none.
+//
+// Run it from the project root so that the .env file is picked up:
+//
+//	go run ./cmd/find-user-collisions
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/dbController"
+	"methompson.com/auth-microservice/authServer/mongoDbController"
+)
+
+func main() {
+	authServer.LoadEnvVariables()
+
+	mdbController, mdbControllerErr := mongoDbController.MakeMongoDbController(constants.AUTH_DB_NAME)
+	if mdbControllerErr != nil {
+		log.Fatal(mdbControllerErr.Error())
+	}
+
+	users, usersErr := mdbController.GetAllUsers()
+	if usersErr != nil {
+		log.Fatal(usersErr.Error())
+	}
+
+	collisions := dbController.FindUserCollisions(users)
+
+	if len(collisions) == 0 {
+		fmt.Printf("Checked %d users. No collisions found.\n", len(users))
+		return
+	}
+
+	for _, collision := range collisions {
+		fmt.Printf("%s '%s' is shared by %d users:\n", collision.Field, collision.NormalizedValue, len(collision.Users))
+
+		for _, user := range collision.Users {
+			fmt.Printf("\tid: %s, username: '%s', email: '%s'\n", user.Id, user.Username, user.Email)
+		}
+	}
+
+	fmt.Printf("Checked %d users. Found %d collisions.\n", len(users), len(collisions))
+
+	os.Exit(1)
+}
@@ -0,0 +1,122 @@
+// verify-audit-log checks that the audit log hasn't been modified. It walks the
+// audit chain, recomputing every event's hash and checking the signed
+// checkpoints against the service's public key, and reports the first broken
+// link.
+//
+// Run it from the project root so that the .env file is picked up. By default
+// it verifies the audit collection in the database:
+//
+//	go run ./cmd/verify-audit-log
+//
+// Pass -file to verify the audit events in a FileLogger log file instead:
+//
+//	go run ./cmd/verify-audit-log -file logs/logs.log
+//
+// Rotated log files, including gzip compressed ones, can be verified together by
+// listing them from oldest to newest, separated by commas:
+//
+//	go run ./cmd/verify-audit-log -file logs/logs-20210601T000000.000.log.gz,logs/logs.log
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"methompson.com/auth-microservice/authServer"
+	"methompson.com/auth-microservice/authServer/authCrypto"
+	"methompson.com/auth-microservice/authServer/authUtils"
+	"methompson.com/auth-microservice/authServer/constants"
+	"methompson.com/auth-microservice/authServer/mongoDbController"
+)
+
+func main() {
+	filePath := flag.String("file", "", "FileLogger log files to verify instead of the database, separated by commas")
+	flag.Parse()
+
+	authServer.LoadEnvVariables()
+
+	publicKey, publicKeyErr := authCrypto.GetRSAPublicKey()
+	if publicKeyErr != nil {
+		log.Fatal(publicKeyErr.Error())
+	}
+
+	var events []authUtils.AuditEvent
+	var eventsErr error
+
+	// The audit collection holds the whole chain, while the log files may only
+	// hold its end
+	requireGenesis := len(*filePath) == 0
+
+	if requireGenesis {
+		events, eventsErr = readDatabase()
+	} else {
+		events, eventsErr = readFiles(strings.Split(*filePath, ","))
+	}
+
+	if eventsErr != nil {
+		log.Fatal(eventsErr.Error())
+	}
+
+	if verifyErr := authServer.VerifyAuditChain(events, publicKey, requireGenesis); verifyErr != nil {
+		fmt.Printf("Checked %d audit events. The chain is broken at %s\n", len(events), verifyErr.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("Checked %d audit events. The chain is intact.\n", len(events))
+}
+
+// readFiles reads the audit events from each file in turn
+func readFiles(filePaths []string) ([]authUtils.AuditEvent, error) {
+	events := make([]authUtils.AuditEvent, 0)
+
+	for _, filePath := range filePaths {
+		fileEvents, readErr := readFile(filePath)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		events = append(events, fileEvents...)
+	}
+
+	// With more than one LOG_QUEUE_WORKERS, lines can be written out of order
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Sequence < events[j].Sequence })
+
+	return events, nil
+}
+
+func readFile(filePath string) ([]authUtils.AuditEvent, error) {
+	file, openErr := os.Open(filePath)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	if strings.HasSuffix(filePath, ".gz") {
+		gzipReader, gzipErr := gzip.NewReader(file)
+		if gzipErr != nil {
+			return nil, gzipErr
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+
+	return authServer.ReadAuditLog(reader)
+}
+
+func readDatabase() ([]authUtils.AuditEvent, error) {
+	mdbController, mdbControllerErr := mongoDbController.MakeMongoDbController(constants.AUTH_DB_NAME)
+	if mdbControllerErr != nil {
+		return nil, mdbControllerErr
+	}
+
+	return mdbController.GetAllAuditEvents()
+}